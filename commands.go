@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+
+	"main/internal/core"
+	"main/internal/downloader"
+	"main/internal/library"
+	"main/internal/logger"
+	"main/internal/metadata"
+	"main/internal/progress"
+)
+
+// runCommand 处理子命令（如 library verify），返回 true 表示参数是子命令且已处理完毕
+func runCommand(args []string, notifier *progress.ProgressNotifier) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "library":
+		runLibraryCommand(args[1:], notifier)
+		return true
+	case "retag":
+		runRetagCommand(args[1:])
+		return true
+	case "lyrics":
+		runLyricsCommand(args[1:])
+		return true
+	case "serve":
+		runServeCommand(args[1:], notifier)
+		return true
+	}
+	return false
+}
+
+// runLibraryCommand 处理 library 子命令
+func runLibraryCommand(args []string, notifier *progress.ProgressNotifier) {
+	if len(args) != 2 || args[0] != "verify" {
+		logger.Error("用法: library verify <路径> [--repair]")
+		return
+	}
+	root := args[1]
+
+	logger.Info("🔍 正在检查曲库: %s", root)
+	findings, err := library.Audit(root, library.Default(), library.AuditOptions{
+		RequireCover:  core.Config.EmbedCover,
+		RequireLyrics: core.Config.EmbedLrc,
+		SkipDirs:      []string{core.Config.CacheFolder},
+		Progress: func(checked int, _ string) {
+			if checked%500 == 0 {
+				logger.Info("🔍 已检查 %d 个文件...", checked)
+			}
+		},
+	})
+	if err != nil {
+		logger.Error("检查失败: %v", err)
+		return
+	}
+	if len(findings) == 0 {
+		logger.Info("✅ 没有发现问题")
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, f := range findings {
+		counts[f.Problem]++
+		line := fmt.Sprintf("[%s] %s", library.ProblemNames[f.Problem], f.Path)
+		if f.Detail != "" {
+			line += " - " + f.Detail
+		}
+		logger.Warn("%s", line)
+	}
+
+	logger.Info("")
+	logger.Info("📋 共发现 %d 个问题:", len(findings))
+	for _, problem := range []string{
+		library.ProblemCorrupt,
+		library.ProblemMissingTags,
+		library.ProblemNoCover,
+		library.ProblemNoLyrics,
+		library.ProblemDuplicateISRC,
+		library.ProblemCoverOnly,
+	} {
+		if counts[problem] > 0 {
+			logger.Info("  %s: %d", library.ProblemNames[problem], counts[problem])
+		}
+	}
+
+	if !core.Repair {
+		logger.Info("使用 --repair 重新下载或补写标签以修复上述问题（ISRC 重复需要手动处理）")
+		return
+	}
+
+	if err := initDeveloperToken(); err != nil {
+		logger.Error("%v", err)
+		return
+	}
+	logger.Info("")
+	logger.Info("🔧 开始修复...")
+	fixed, failed := downloader.RepairFindings(findings, notifier)
+	logger.Info("🔧 修复完成: 成功 %d, 失败 %d", fixed, failed)
+}
+
+// runRetagCommand 处理 retag 子命令：用最新的目录元数据重写已下载文件的标签
+func runRetagCommand(args []string) {
+	if len(args) != 1 {
+		logger.Error("用法: retag <路径> [--dry-run] [--fields genre,copyright]")
+		return
+	}
+	fields, err := metadata.ParseTagFields(core.RetagFields)
+	if err != nil {
+		logger.Error("%v", err)
+		return
+	}
+	if err := initDeveloperToken(); err != nil {
+		logger.Error("%v", err)
+		return
+	}
+
+	if core.DryRun {
+		logger.Info("🏷️ 预览标签变更（--dry-run，不写入文件）: %s", args[0])
+	} else {
+		logger.Info("🏷️ 正在重写标签: %s", args[0])
+	}
+	stats, err := downloader.Retag(args[0], downloader.RetagOptions{Fields: fields, DryRun: core.DryRun})
+	if err != nil {
+		logger.Error("重写标签失败: %v", err)
+	}
+	verb := "已更新"
+	if core.DryRun {
+		verb = "待更新"
+	}
+	logger.Info("🏷️ %s %d, 无变化 %d, 跳过 %d, 失败 %d", verb, stats.Updated, stats.Unchanged, stats.Skipped, stats.Failed)
+	if stats.NFOs > 0 {
+		logger.Info("📝 已重新生成 NFO %d 个", stats.NFOs)
+	}
+}
+
+// runLyricsCommand 处理 lyrics 子命令：为已下载的曲目补充歌词，不下载音频
+func runLyricsCommand(args []string) {
+	if len(args) != 2 || args[0] != "fetch" {
+		logger.Error("用法: lyrics fetch <路径> [--force]")
+		return
+	}
+	if err := initDeveloperToken(); err != nil {
+		logger.Error("%v", err)
+		return
+	}
+
+	logger.Info("🎤 正在获取歌词: %s", args[1])
+	stats, err := downloader.FetchLyrics(args[1], downloader.LyricsFetchOptions{Force: core.Force})
+	if err != nil {
+		logger.Error("获取歌词失败: %v", err)
+	}
+	for _, path := range stats.Missing {
+		logger.Warn("[无歌词] %s", path)
+	}
+	logger.Info("🎤 已写入 %d, 已有歌词 %d, 无歌词 %d, 跳过 %d, 失败 %d", stats.Written, stats.Existing, len(stats.Missing), stats.Skipped, stats.Failed)
+}
@@ -58,7 +58,6 @@ require (
 	github.com/quic-go/quic-go v0.48.2 // indirect
 	github.com/refraction-networking/uquic v0.0.6 // indirect
 	github.com/refraction-networking/utls v1.7.0 // indirect
-	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/sky8282/bar v0.0.0 // indirect
 	github.com/sky8282/blog v0.0.0 // indirect
 	github.com/sky8282/bs4 v0.0.0 // indirect
@@ -92,14 +91,15 @@ require (
 	golang.org/x/net v0.45.0 // indirect
 	golang.org/x/sync v0.17.0 // indirect
 	golang.org/x/sys v0.37.0 // indirect
-	golang.org/x/text v0.30.0 // indirect
 	golang.org/x/tools v0.37.0 // indirect
 )
 
 require (
 	github.com/beevik/etree v1.3.0
 	github.com/olekukonko/tablewriter v0.0.5
+	github.com/rivo/uniseg v0.4.7
 	github.com/zhaarey/go-mp4tag v0.0.0-20250210094042-22578afc09bf
 	golang.org/x/term v0.36.0
+	golang.org/x/text v0.30.0
 	gopkg.in/yaml.v2 v2.2.8
 )
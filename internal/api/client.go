@@ -203,6 +203,11 @@ func CheckArtist(artistUrl string, account *structs.Account, relationship string
 
 // GetMeta retrieves metadata for an album or playlist
 func GetMeta(albumId string, account *structs.Account, storefront string) (*structs.AutoGenerated, error) {
+	return GetMetaInLanguage(albumId, account, storefront, core.Config.Language)
+}
+
+// GetMetaInLanguage retrieves metadata for an album or playlist with names localized to the given language
+func GetMetaInLanguage(albumId string, account *structs.Account, storefront string, language string) (*structs.AutoGenerated, error) {
 	var mtype string
 	var next string
 	if strings.Contains(albumId, "pl.") {
@@ -225,7 +230,7 @@ func GetMeta(albumId string, account *structs.Account, storefront string) (*stru
 	query.Set("fields[albums:albums]", "artistName,artwork,name,releaseDate,url")
 	query.Set("fields[record-labels]", "name")
 	query.Set("extend", "editorialVideo")
-	query.Set("l", core.Config.Language)
+	query.Set("l", language)
 	req.URL.RawQuery = query.Encode()
 	do, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -246,7 +251,7 @@ func GetMeta(albumId string, account *structs.Account, storefront string) (*stru
 	if len(obj.Data[0].Relationships.Tracks.Next) > 0 {
 		next = obj.Data[0].Relationships.Tracks.Next
 		for {
-			req, err := http.NewRequest("GET", fmt.Sprintf("https://amp-api.music.apple.com/%s&l=%s&include=albums", next, core.Config.Language), nil)
+			req, err := http.NewRequest("GET", fmt.Sprintf("https://amp-api.music.apple.com/%s&l=%s&include=albums", next, language), nil)
 			if err != nil {
 				return nil, err
 			}
@@ -317,6 +322,74 @@ func GetInfoFromAdam(adamId string, account *structs.Account, storefront string)
 	return nil, nil
 }
 
+// GetSongByISRC looks up a catalog song (with its albums) by ISRC, returning nil if none matches
+func GetSongByISRC(isrc string, account *structs.Account, storefront string) (*structs.SongData, error) {
+	request, err := http.NewRequest("GET", fmt.Sprintf("https://amp-api.music.apple.com/v1/catalog/%s/songs", storefront), nil)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	query.Set("filter[isrc]", isrc)
+	query.Set("include", "albums")
+	query.Set("l", core.Config.Language)
+	request.URL.RawQuery = query.Encode()
+
+	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", core.DeveloperToken))
+	request.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	request.Header.Set("Origin", "https://music.apple.com")
+
+	do, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer do.Body.Close()
+	if do.StatusCode != http.StatusOK {
+		return nil, errors.New(do.Status)
+	}
+
+	obj := new(structs.ApiResult)
+	if err := json.NewDecoder(do.Body).Decode(&obj); err != nil {
+		return nil, err
+	}
+	if len(obj.Data) == 0 {
+		return nil, nil
+	}
+	return &obj.Data[0], nil
+}
+
+// GetArtistInfo retrieves an artist's biography, genres and artwork
+func GetArtistInfo(artistId string, account *structs.Account, storefront string) (*structs.AutoGeneratedArtistInfo, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("https://amp-api.music.apple.com/v1/catalog/%s/artists/%s", storefront, artistId), nil)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	query.Set("extend", "artistBio,editorialNotes")
+	query.Set("l", core.Config.Language)
+	req.URL.RawQuery = query.Encode()
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", core.DeveloperToken))
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	req.Header.Set("Origin", "https://music.apple.com")
+
+	do, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer do.Body.Close()
+	if do.StatusCode != http.StatusOK {
+		return nil, errors.New(do.Status)
+	}
+	obj := new(structs.AutoGeneratedArtistInfo)
+	if err := json.NewDecoder(do.Body).Decode(&obj); err != nil {
+		return nil, err
+	}
+	if len(obj.Data) == 0 {
+		return nil, errors.New("artist not found")
+	}
+	return obj, nil
+}
+
 // GetMVInfoFromAdam retrieves music video data from the API
 func GetMVInfoFromAdam(adamId string, account *structs.Account, storefront string) (*structs.AutoGeneratedMusicVideo, error) {
 	request, err := http.NewRequest("GET", fmt.Sprintf("https://amp-api.music.apple.com/v1/catalog/%s/music-videos/%s", storefront, adamId), nil)
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"main/internal/logger"
+	"main/utils/lyrics"
 	"main/utils/structs"
 	"os"
 	"regexp"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/spf13/pflag"
+	"golang.org/x/term"
 	"gopkg.in/yaml.v2"
 )
 
@@ -25,6 +27,20 @@ var (
 	Artist_select    bool
 	Debug_mode       bool
 	DisableDynamicUI bool // 禁用动态UI的标志，启用后使用纯日志输出
+	TUI              bool // 全屏队列管理界面
+	RescanLibrary    bool // 强制重新扫描曲库索引
+	Upgrade          bool // 音质升级模式：重新下载可提升音质的已有曲目
+	Repair           bool // library verify: 修复发现的问题
+	DryRun           bool // retag: 只显示差异，不写入文件
+	RetagFields      string
+	Force            bool   // lyrics fetch: 已有歌词的曲目也重新获取
+	ListFormats      bool   // 只列出 MV 的可用格式，不下载
+	MVFormat         string // MV 格式偏好表达式（--mv-format 或配置中的 mv-format）
+	ProgressMode     string // 进度输出方式，见 ProgressModes
+	ProgressFile     string // --progress=json 时 JSON 事件的输出文件，为空时写入标准输出
+	Listen           string // serve: HTTP 服务监听地址
+	Formats          string
+	DownloadFormats  []string // 由 --formats 解析出的格式列表，为空时使用 --atmos/--aac 决定的单一格式
 	Alac_max         *int
 	Atmos_max        *int
 	Mv_max           *int
@@ -39,6 +55,10 @@ var (
 	SharedLock       sync.Mutex
 	DeveloperToken   string
 	MaxPathLength    int
+	// MaxComponentLength 单个路径组件（文件夹名或文件名）的最大长度
+	MaxComponentLength int
+	// PathLengthUnit 路径长度计量单位: bytes（ext4/btrfs 等）或 utf16（Windows/NTFS）
+	PathLengthUnit string
 )
 
 type TrackStatus struct {
@@ -57,6 +77,16 @@ var RipLock sync.Mutex
 
 var TrackStatuses []TrackStatus
 
+// QualityTiers 音质回退链中可使用的等级
+var QualityTiers = []string{"atmos", "dolby-audio", "hires-192", "hires-96", "hires", "lossless", "alac", "aac-256", "skip"}
+
+// qualityTierFormats 各下载格式可使用的音质等级（aac-256 也是 alac 格式中无损版本不可用时的回退）
+var qualityTierFormats = map[string][]string{
+	"alac":  {"hires-192", "hires-96", "hires", "lossless", "alac", "aac-256"},
+	"aac":   {"aac-256"},
+	"atmos": {"atmos", "dolby-audio"},
+}
+
 func InitCounter() structs.Counter {
 	return structs.Counter{}
 }
@@ -72,14 +102,120 @@ func InitFlags() {
 	pflag.BoolVar(&Artist_select, "all-album", false, "下载歌手的所有专辑")
 	pflag.BoolVar(&Debug_mode, "debug", false, "启用调试模式，显示音频质量信息")
 	pflag.BoolVar(&DisableDynamicUI, "no-ui", false, "禁用动态终端UI，回退到纯日志输出模式（用于CI/调试或兼容性）")
+	pflag.StringVar(&ProgressMode, "progress", "auto", "进度输出方式：auto（终端中使用动态UI，输出被重定向时使用纯日志）、ui、log、json（每个事件输出一行 JSON）")
+	pflag.StringVar(&ProgressFile, "progress-file", "", "与 --progress=json 一起使用：JSON 事件写入该文件，而不是标准输出")
+	pflag.BoolVar(&TUI, "tui", false, "全屏队列管理界面：下载过程中可添加链接、调整顺序、暂停/取消专辑和跳过曲目")
+	pflag.StringVar(&Formats, "formats", "", "一次下载多种格式，逗号分隔（可选：alac, atmos, aac，例如：--formats alac,atmos）")
+	pflag.BoolVar(&Repair, "repair", false, "与 library verify 一起使用：重新下载或补写标签以修复发现的问题")
+	pflag.BoolVar(&DryRun, "dry-run", false, "与 retag 一起使用：只显示将要改写的标签，不写入文件")
+	pflag.BoolVar(&Force, "force", false, "与 lyrics fetch 一起使用：已有歌词的曲目也重新获取")
+	pflag.StringVar(&RetagFields, "fields", "", "与 retag 一起使用：只重写指定的标签字段，逗号分隔（例如：--fields genre,copyright）")
+	pflag.BoolVar(&Upgrade, "upgrade", false, "音质升级模式：已下载的曲目如有更高音质（如 Hi-Res Lossless）则重新下载并替换")
+	pflag.BoolVar(&RescanLibrary, "rescan-library", false, "重新扫描保存目录并更新曲库索引（需启用 library-index）")
+	pflag.StringVar(&Listen, "listen", "127.0.0.1:8080", "与 serve 一起使用：HTTP 服务监听地址")
 	pflag.IntVar(&StartFrom, "start", 0, "从 TXT 文件的第几个链接开始下载（从 1 开始计数，例如：--start 44）")
 	Alac_max = pflag.Int("alac-max", 0, "指定 ALAC 下载的最大音质（如：192000, 96000, 48000）")
 	Atmos_max = pflag.Int("atmos-max", 0, "指定 Dolby Atmos 下载的最大音质（如：2768, 2448）")
 	Aac_type = pflag.String("aac-type", "aac", "选择 AAC 类型（可选：aac, aac-binaural, aac-downmix）")
 	Mv_audio_type = pflag.String("mv-audio-type", "atmos", "选择 MV 音轨类型（可选：atmos, ac3, aac）")
+	pflag.BoolVar(&ListFormats, "list-formats", false, "列出 MV 的全部视频/音轨格式（编码、分辨率、动态范围、码率），不下载")
+	pflag.StringVar(&MVFormat, "mv-format", "", "MV 格式偏好，覆盖配置中的 mv-format（例如：--mv-format \"hevc>avc, sdr, <=2160\"）")
 	Mv_max = pflag.Int("mv-max", 1080, "指定 MV 下载的最大分辨率（如：2160, 1080, 720）")
 }
 
+// ProgressModes --progress 的可选值
+var ProgressModes = []string{"auto", "ui", "log", "json"}
+
+// ResolveProgressMode 校验 --progress 并决定是否使用动态UI：
+// auto 在标准输出不是终端时（管道、cron、systemd、CI）自动回退到纯日志，log 和 json 不使用动态UI
+func ResolveProgressMode() error {
+	switch ProgressMode {
+	case "auto":
+		if !term.IsTerminal(int(os.Stdout.Fd())) {
+			DisableDynamicUI = true
+		}
+	case "ui":
+	case "log", "json":
+		DisableDynamicUI = true
+	default:
+		return fmt.Errorf("无效的 --progress: %s（可选：%s）", ProgressMode, strings.Join(ProgressModes, ", "))
+	}
+	if ProgressFile != "" && ProgressMode != "json" {
+		return errors.New("--progress-file 需要与 --progress=json 一起使用")
+	}
+	return nil
+}
+
+// ParseFormats 解析 --formats 参数到 DownloadFormats（去重并保持顺序）
+func ParseFormats() error {
+	formats, err := ParseFormatList(Formats)
+	if err != nil {
+		return err
+	}
+	DownloadFormats = formats
+	return nil
+}
+
+// ParseFormatList 解析逗号分隔的格式列表（去重并保持顺序），为空时返回 nil
+func ParseFormatList(list string) ([]string, error) {
+	var formats []string
+	seen := make(map[string]bool)
+	for _, f := range strings.Split(list, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" || seen[f] {
+			continue
+		}
+		switch f {
+		case "alac", "atmos", "aac":
+		default:
+			return nil, fmt.Errorf("不支持的格式 '%s'（可选：alac, atmos, aac）", f)
+		}
+		seen[f] = true
+		formats = append(formats, f)
+	}
+	return formats, nil
+}
+
+// ApplyFormat 按格式名称设置 Dl_atmos/Dl_aac，供多格式下载时逐一切换
+func ApplyFormat(format string) {
+	Dl_atmos = format == "atmos"
+	Dl_aac = format == "aac"
+}
+
+// CurrentFormat 返回当前下载格式的名称（alac、aac、atmos），与 ApplyFormat 对应
+func CurrentFormat() string {
+	if Dl_atmos {
+		return "atmos"
+	}
+	if Dl_aac {
+		return "aac"
+	}
+	return "alac"
+}
+
+// QualityChainFor 返回音质回退链中适用于指定下载格式的部分（skip 保留在原位置）
+// 链中没有该格式的等级时返回 nil，该格式按原有方式下载，不会被其他格式的等级替换或因 skip 全部跳过
+func QualityChainFor(format string) []string {
+	var chain []string
+	applicable := false
+	for _, tier := range Config.QualityFallback {
+		if tier == "skip" {
+			chain = append(chain, tier)
+			continue
+		}
+		for _, t := range qualityTierFormats[format] {
+			if t == tier {
+				chain = append(chain, tier)
+				applicable = true
+			}
+		}
+	}
+	if !applicable {
+		return nil
+	}
+	return chain
+}
+
 func LoadConfig(configPath string) error {
 	if configPath == "" {
 		ConfigPath = "config.yaml"
@@ -146,6 +282,24 @@ func LoadConfig(configPath string) error {
 		}
 	}
 
+	// 单个路径组件长度限制（ext4/btrfs/NTFS 均为 255）
+	MaxComponentLength = 255
+	if Config.MaxComponentLength > 0 {
+		MaxComponentLength = Config.MaxComponentLength
+	}
+
+	// 路径长度计量单位：Windows/NTFS 按 UTF-16 编码单元计算，其余系统按 UTF-8 字节计算
+	switch Config.PathLengthUnit {
+	case "bytes", "utf16":
+		PathLengthUnit = Config.PathLengthUnit
+	default:
+		if runtime.GOOS == "windows" {
+			PathLengthUnit = "utf16"
+		} else {
+			PathLengthUnit = "bytes"
+		}
+	}
+
 	if *Alac_max == 0 {
 		Alac_max = &Config.AlacMax
 	}
@@ -161,6 +315,45 @@ func LoadConfig(configPath string) error {
 	if *Mv_max == 1080 {
 		Mv_max = &Config.MVMax
 	}
+	if MVFormat == "" {
+		MVFormat = Config.MVFormat
+	}
+
+	// 校验音质回退链
+	for i, tier := range Config.QualityFallback {
+		tier = strings.ToLower(strings.TrimSpace(tier))
+		valid := false
+		for _, t := range QualityTiers {
+			valid = valid || t == tier
+		}
+		if !valid {
+			return fmt.Errorf("quality-fallback 中的等级 '%s' 无效（可选：%s）", tier, strings.Join(QualityTiers, ", "))
+		}
+		Config.QualityFallback[i] = tier
+	}
+	if len(Config.QualityFallback) > 0 {
+		// 回退链只在对应格式中生效：本次下载的格式都用不到链中的等级时视为配置矛盾（如 --atmos 搭配 [hires, lossless]）
+		// --formats 格式错误时由 ParseFormats 报告
+		formats, _ := ParseFormatList(Formats)
+		if len(formats) == 0 {
+			formats = []string{CurrentFormat()}
+		}
+		applicable := false
+		for _, f := range formats {
+			applicable = applicable || QualityChainFor(f) != nil
+		}
+		if !applicable {
+			return fmt.Errorf("quality-fallback 中没有适用于 %s 格式的等级（alac: %s；aac: %s；atmos: %s）",
+				strings.Join(formats, ", "),
+				strings.Join(qualityTierFormats["alac"], ", "),
+				strings.Join(qualityTierFormats["aac"], ", "),
+				strings.Join(qualityTierFormats["atmos"], ", "))
+		}
+	}
+
+	if err := normalizeLyricsOutputs(); err != nil {
+		return err
+	}
 
 	// 设置缓存文件夹默认值
 	if Config.CacheFolder == "" {
@@ -175,6 +368,48 @@ func LoadConfig(configPath string) error {
 		)
 	}
 
+	// 设置歌手文件夹策略默认值
+	if Config.ArtistFolderStrategy == "" {
+		Config.ArtistFolderStrategy = "album-artist"
+	}
+	if Config.CompilationArtistName == "" {
+		Config.CompilationArtistName = "Various Artists"
+	}
+	if Config.PlaylistArtistName == "" {
+		Config.PlaylistArtistName = "Apple Music"
+	}
+	if Config.StationArtistName == "" {
+		Config.StationArtistName = "Apple Music"
+	}
+
+	// 设置文件名清理规则默认值
+	if Config.FilenameProfile == "" {
+		Config.FilenameProfile = "posix"
+	}
+
+	// 设置曲库索引文件默认值
+	if Config.LibraryIndexFile == "" {
+		Config.LibraryIndexFile = "library-index.json"
+	}
+
+	// 设置服务模式队列文件默认值
+	if Config.ServeQueueFile == "" {
+		Config.ServeQueueFile = "serve-queue.json"
+	}
+
+	// 设置标签映射方案默认值（方案内容由 metadata.InitTagProfile 校验）
+	if Config.TagProfile == "" {
+		Config.TagProfile = "default"
+	}
+
+	// 设置多碟专辑布局默认值
+	if Config.MultiDiscLayout == "" {
+		Config.MultiDiscLayout = "flat"
+	}
+	if Config.DiscFolderFormat == "" {
+		Config.DiscFolderFormat = "Disc {DiscNumber}"
+	}
+
 	// 设置分批下载默认值
 	if Config.BatchSize == 0 {
 		Config.BatchSize = 20
@@ -227,3 +462,112 @@ func LimitString(s string) string {
 	}
 	return s
 }
+
+// LyricsOptions 返回歌词渲染选项，mode 为空时使用 lyrics-mode
+func LyricsOptions(mode string) lyrics.Options {
+	if mode == "" {
+		mode = Config.LyricsMode
+	}
+	language := Config.LyricsLanguage
+	if language == "" {
+		language = Config.Language
+	}
+	return lyrics.Options{Mode: mode, Secondary: Config.LyricsSecondary, Language: language}
+}
+
+// normalizeLyricsOutputs 校验歌词格式并补全默认值
+// 未配置 embed-lrc-format 时沿用 lrc-format 的旧行为（逐字歌词的 lrc 为增强 LRC）；
+// 未配置 lyrics-sidecars 时 save-lrc-file 保存一个同格式的 .lrc 文件
+func normalizeLyricsOutputs() error {
+	legacy := strings.ToLower(Config.LrcFormat)
+	if legacy != "ttml" {
+		legacy = "lrc"
+		if Config.LrcType == "syllable-lyrics" {
+			legacy = "lrc-enhanced"
+		}
+	}
+	if Config.EmbedLrcFormat == "" {
+		Config.EmbedLrcFormat = legacy
+	}
+	if len(Config.LyricsSidecars) == 0 && Config.SaveLrcFile {
+		Config.LyricsSidecars = []structs.LyricsSidecar{{Format: legacy, File: "{SongFileName}.lrc"}}
+	}
+
+	valid := func(format string) bool {
+		for _, f := range lyrics.Formats {
+			if f == format {
+				return true
+			}
+		}
+		return false
+	}
+	Config.EmbedLrcFormat = strings.ToLower(Config.EmbedLrcFormat)
+	if !valid(Config.EmbedLrcFormat) {
+		return fmt.Errorf("embed-lrc-format '%s' 无效（可选：%s）", Config.EmbedLrcFormat, strings.Join(lyrics.Formats, ", "))
+	}
+	validMode := func(mode string) bool {
+		for _, m := range lyrics.Modes {
+			if m == mode {
+				return true
+			}
+		}
+		return false
+	}
+	Config.LyricsMode = strings.ToLower(strings.TrimSpace(Config.LyricsMode))
+	if Config.LyricsMode == "" {
+		Config.LyricsMode = "auto"
+	}
+	if !validMode(Config.LyricsMode) {
+		return fmt.Errorf("lyrics-mode '%s' 无效（可选：%s）", Config.LyricsMode, strings.Join(lyrics.Modes, ", "))
+	}
+	Config.LyricsSecondary = strings.ToLower(strings.TrimSpace(Config.LyricsSecondary))
+	if Config.LyricsSecondary == "" {
+		Config.LyricsSecondary = "translation"
+	}
+	if Config.LyricsSecondary != "translation" && Config.LyricsSecondary != "transliteration" {
+		return fmt.Errorf("lyrics-secondary '%s' 无效（可选：translation, transliteration）", Config.LyricsSecondary)
+	}
+	if len(Config.LyricsProviders) == 0 {
+		Config.LyricsProviders = []structs.LyricsSource{{Type: "apple"}}
+	}
+	for i := range Config.LyricsProviders {
+		p := &Config.LyricsProviders[i]
+		p.Type = strings.ToLower(strings.TrimSpace(p.Type))
+		switch p.Type {
+		case "apple":
+		case "directory":
+			if p.Path == "" {
+				return fmt.Errorf("lyrics-providers 中的 directory 需要设置 path")
+			}
+		case "http":
+			if p.URL == "" || p.Fields == "" {
+				return fmt.Errorf("lyrics-providers 中的 http 需要设置 url 和 fields")
+			}
+		default:
+			return fmt.Errorf("lyrics-providers 中的类型 '%s' 无效（可选：apple, directory, http）", p.Type)
+		}
+	}
+	seen := make(map[string]bool)
+	for i := range Config.LyricsSidecars {
+		sc := &Config.LyricsSidecars[i]
+		sc.Format = strings.ToLower(strings.TrimSpace(sc.Format))
+		if !valid(sc.Format) {
+			return fmt.Errorf("lyrics-sidecars 中的格式 '%s' 无效（可选：%s）", sc.Format, strings.Join(lyrics.Formats, ", "))
+		}
+		if sc.File == "" {
+			sc.File = "{SongFileName}" + lyrics.Extension(sc.Format)
+		}
+		if !strings.Contains(sc.File, "{SongFileName}") {
+			return fmt.Errorf("lyrics-sidecars 的文件名 '%s' 必须包含 {SongFileName}", sc.File)
+		}
+		sc.Mode = strings.ToLower(strings.TrimSpace(sc.Mode))
+		if sc.Mode != "" && !validMode(sc.Mode) {
+			return fmt.Errorf("lyrics-sidecars 中的 mode '%s' 无效（可选：%s）", sc.Mode, strings.Join(lyrics.Modes, ", "))
+		}
+		if seen[sc.File] {
+			return fmt.Errorf("lyrics-sidecars 中的文件名 '%s' 重复", sc.File)
+		}
+		seen[sc.File] = true
+	}
+	return nil
+}
@@ -1,13 +1,13 @@
 package downloader
 
 import (
-	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"main/internal/api"
 	"main/internal/core"
+	"main/internal/library"
 	"main/internal/logger"
 	"main/internal/metadata"
 	"main/internal/parser"
@@ -36,42 +36,10 @@ func cleanupEmptyAlbumFolders(baseSaveFolder string) int {
 			return nil
 		}
 
-		files, err := os.ReadDir(path)
-		if err != nil {
-			return nil
-		}
-
-		// 检查是否只包含 cover.jpg 或 folder.jpg
-		if len(files) == 0 {
-			// 空文件夹，删除
+		// 空文件夹或只包含 cover.jpg / folder.jpg 的文件夹，删除
+		if files, err := os.ReadDir(path); (err == nil && len(files) == 0) || library.IsCoverOnlyFolder(path) {
 			_ = os.RemoveAll(path)
 			cleanedCount++
-		} else if len(files) == 1 {
-			fileName := files[0].Name()
-			if fileName == "cover.jpg" || fileName == "folder.jpg" {
-				// 只有封面图片，删除整个文件夹
-				_ = os.RemoveAll(path)
-				cleanedCount++
-			}
-		} else if len(files) == 2 {
-			// 检查是否只有 cover.jpg 和 folder.jpg
-			hasCover := false
-			hasFolder := false
-			for _, f := range files {
-				if f.Name() == "cover.jpg" {
-					hasCover = true
-				} else if f.Name() == "folder.jpg" {
-					hasFolder = true
-				} else {
-					// 有其他文件，不是空文件夹
-					return nil
-				}
-			}
-			if hasCover && hasFolder {
-				// 只有两个封面图片，删除
-				_ = os.RemoveAll(path)
-				cleanedCount++
-			}
 		}
 		return nil
 	})
@@ -104,55 +72,7 @@ func SafeMoveFile(src, dst string) error {
 	return utils.SafeMoveFile(src, dst)
 }
 
-func checkAndReEncodeTrack(trackPath string, statusIndex int, notifier *progress.ProgressNotifier) (bool, error) {
-	if notifier != nil {
-		notifier.NotifyStatus(statusIndex, "正在检测...", "check")
-	}
-	checkArgs := strings.Fields(core.Config.FfmpegCheckArgs)
-	cmdCheckArgs := append([]string{"-i", trackPath}, checkArgs...)
-	checkCmd := exec.Command("ffmpeg", cmdCheckArgs...)
-
-	var stderr bytes.Buffer
-	checkCmd.Stderr = &stderr
-	err := checkCmd.Run()
-
-	if err == nil && stderr.Len() == 0 {
-		return false, nil
-	}
-
-	if notifier != nil {
-		notifier.NotifyStatus(statusIndex, "文件损坏, 正在重新编码...", "reencode")
-	}
-
-	tempTrackPath := trackPath + ".fixed.m4a"
-	defer func() {
-		_ = os.Remove(tempTrackPath)
-	}()
-
-	encodeArgs := strings.Fields(core.Config.FfmpegEncodeArgs)
-	cmdEncodeArgs := append([]string{"-i", trackPath}, encodeArgs...)
-	cmdEncodeArgs = append(cmdEncodeArgs, tempTrackPath)
-
-	encodeCmd := exec.Command("ffmpeg", cmdEncodeArgs...)
-	var encodeStderr bytes.Buffer
-	encodeCmd.Stderr = &encodeStderr
-	err = encodeCmd.Run()
-
-	if err != nil {
-		return true, fmt.Errorf("重新编码失败: %v, FFMPEG输出: %s", err, encodeStderr.String())
-	}
-
-	if err := os.Remove(trackPath); err != nil {
-		return true, fmt.Errorf("删除损坏的原文件失败: %w", err)
-	}
-	if err := os.Rename(tempTrackPath, trackPath); err != nil {
-		return true, fmt.Errorf("替换为修复文件失败: %w", err)
-	}
-
-	return true, nil
-}
-
-func downloadTrackWithFallback(track structs.TrackData, meta *structs.AutoGenerated, albumId, storefront, baseSaveFolder, finalSaveFolder, Codec, covPath string, workingAccounts []structs.Account, initialAccountIndex int, statusIndex int, updateStatus func(index int, status string, sColor func(a ...interface{}) string), progressChan chan runv14.ProgressUpdate) (string, error) {
+func downloadTrackWithFallback(session *ripSession, track structs.TrackData, albumId, storefront, baseSaveFolder, finalSaveFolder, Codec, covPath string, workingAccounts []structs.Account, initialAccountIndex int, statusIndex int, updateStatus func(index int, status string, sColor func(a ...interface{}) string), progressChan chan runv14.ProgressUpdate) (string, error) {
 	maxRetries := 3 // 每个账号最多重试次数
 	var lastError error
 	yellow := func(a ...interface{}) string { return fmt.Sprint(a...) }
@@ -167,12 +87,17 @@ func downloadTrackWithFallback(track structs.TrackData, meta *structs.AutoGenera
 		account := &workingAccounts[accountIndex]
 
 		for attempt := 0; attempt <= maxRetries; attempt++ {
-			trackPath, err := downloadTrackSilently(track, meta, albumId, storefront, baseSaveFolder, finalSaveFolder, Codec, covPath, account, progressChan)
+			trackPath, err := downloadTrackSilently(session, track, albumId, storefront, baseSaveFolder, finalSaveFolder, Codec, covPath, account, progressChan)
 			if err == nil {
 				return trackPath, nil
 			}
 			lastError = err
 
+			// 音质回退链的结果与账户无关，无需重试
+			if errors.Is(err, errQualitySkipped) || errors.Is(err, errQualityUnavailable) {
+				return "", err
+			}
+
 			// 检测连接被拒绝错误
 			if strings.Contains(err.Error(), "connection refused") {
 				connectionRefusedCount++
@@ -216,7 +141,8 @@ func downloadTrackWithFallback(track structs.TrackData, meta *structs.AutoGenera
 	return "", fmt.Errorf("所有账户失败: %s", errorMsg)
 }
 
-func downloadTrackSilently(track structs.TrackData, meta *structs.AutoGenerated, albumId, storefront, baseSaveFolder, finalSaveFolder, Codec, covPath string, account *structs.Account, progressChan chan runv14.ProgressUpdate) (string, error) {
+func downloadTrackSilently(session *ripSession, track structs.TrackData, albumId, storefront, baseSaveFolder, finalSaveFolder, Codec, covPath string, account *structs.Account, progressChan chan runv14.ProgressUpdate) (string, error) {
+	meta := session.meta
 	// Check if this is a music video download request
 	if track.Type == "music-videos" {
 		// Verify MV download prerequisites
@@ -233,26 +159,8 @@ func downloadTrackSilently(track structs.TrackData, meta *structs.AutoGenerated,
 		}
 
 		// Setup folder names for MV
-		var singerFoldername, albumFoldername string
-		if core.Config.ArtistFolderFormat != "" {
-			if strings.Contains(albumId, "pl.") {
-				singerFoldername = strings.NewReplacer(
-					"{ArtistName}", "Apple Music", "{ArtistId}", "", "{UrlArtistName}", "Apple Music",
-				).Replace(core.Config.ArtistFolderFormat)
-			} else if len(meta.Data[0].Relationships.Artists.Data) > 0 {
-				singerFoldername = strings.NewReplacer(
-					"{UrlArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName),
-					"{ArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName),
-					"{ArtistId}", meta.Data[0].Relationships.Artists.Data[0].ID,
-				).Replace(core.Config.ArtistFolderFormat)
-			} else {
-				singerFoldername = strings.NewReplacer(
-					"{UrlArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName),
-					"{ArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName),
-					"{ArtistId}", "",
-				).Replace(core.Config.ArtistFolderFormat)
-			}
-		}
+		var albumFoldername string
+		singerFoldername := buildSingerFolderName(meta, session.artistFolderFormat())
 
 		Quality := "Video"
 		MVCodec := "H.264"
@@ -298,36 +206,9 @@ func downloadTrackSilently(track structs.TrackData, meta *structs.AutoGenerated,
 			}
 
 			// 检查最终目标路径是否已存在MV文件
-			mvName := core.LimitString(track.Attributes.Name)
-			var releaseYear string
-			if len(track.Attributes.ReleaseDate) >= 4 {
-				releaseYear = track.Attributes.ReleaseDate[:4]
-			}
-			var mvFolderName, mvFileName string
-			if releaseYear != "" {
-				mvFolderName = fmt.Sprintf("%s (%s)", mvName, releaseYear)
-				mvFileName = fmt.Sprintf("%s (%s).mp4", mvName, releaseYear)
-			} else {
-				mvFolderName = mvName
-				mvFileName = fmt.Sprintf("%s.mp4", mvName)
-			}
-			sanitizedMvFolderName := core.ForbiddenNames.ReplaceAllString(mvFolderName, "_")
-			sanitizedMvFileName := core.ForbiddenNames.ReplaceAllString(mvFileName, "_")
-
-			checkArtistDir, checkMvDir, checkFilename := utils.EnsureSafePath(
-				checkMvSaveFolder, sanitizedSingerFolder, sanitizedMvFolderName, sanitizedMvFileName,
-			)
-			var checkSingerFolder string
-			if checkArtistDir != "" {
-				checkSingerFolder = filepath.Join(checkMvSaveFolder, checkArtistDir)
-			} else {
-				checkSingerFolder = checkMvSaveFolder
-			}
-			checkMvFolder := filepath.Join(checkSingerFolder, checkMvDir)
-			checkMvPath := filepath.Join(checkMvFolder, checkFilename)
-
-			exists, _ := utils.FileExists(checkMvPath)
-			if exists {
+			mvInfo := mvNameInfo{ID: track.ID, Name: track.Attributes.Name, ArtistName: track.Attributes.ArtistName, ReleaseDate: track.Attributes.ReleaseDate}
+			_, checkMvPath := resolveMVPath(checkMvSaveFolder, sanitizedSingerFolder, mvInfo, nil, nil)
+			if _, exists := findExistingMV(checkMvPath); exists {
 				// MV已存在于最终目标位置，跳过下载
 				core.OkDict[albumId] = append(core.OkDict[albumId], -1)
 				return "", nil
@@ -356,8 +237,14 @@ func downloadTrackSilently(track structs.TrackData, meta *structs.AutoGenerated,
 
 	// Check if manifest has required nested fields before accessing them
 	if manifest.Attributes.ExtendedAssetUrls.EnhancedHls == "" {
-		if core.Dl_atmos {
-			return "", errors.New("atmos unavailable")
+		// 配置了音质回退链时由 selectQuality 决定是否改用 AAC-LC
+		if !qualityChainEnabled() {
+			if core.Dl_atmos {
+				return "", errors.New("atmos unavailable")
+			}
+			if core.Config.QualityStrict && !core.Dl_aac {
+				return "", fmt.Errorf("%w: 仅有 AAC-LC 版本（严格模式）", errQualityUnavailable)
+			}
 		}
 		needDlAacLc = true
 	}
@@ -375,8 +262,20 @@ func downloadTrackSilently(track structs.TrackData, meta *structs.AutoGenerated,
 			manifest.Attributes.ExtendedAssetUrls.EnhancedHls = EnhancedHls_m3u8
 		}
 	}
+	var choice qualityChoice
+	if qualityChainEnabled() {
+		choice, err = selectQuality(track, manifest.Attributes.ExtendedAssetUrls.EnhancedHls, account)
+		if err != nil {
+			return "", err
+		}
+		needDlAacLc = choice.aacLc
+		Codec = choice.codec
+	}
+
 	var Quality string
-	if strings.Contains(core.Config.SongFileFormat, "Quality") {
+	if qualityChainEnabled() {
+		Quality = choice.quality
+	} else if strings.Contains(core.Config.SongFileFormat, "Quality") {
 		if core.Dl_atmos {
 			Quality = fmt.Sprintf("%dkbps", *core.Atmos_max-2000)
 		} else if needDlAacLc {
@@ -391,7 +290,9 @@ func downloadTrackSilently(track structs.TrackData, meta *structs.AutoGenerated,
 	// Determine quality tag using FormatQualityTag()
 	// {Tag} variable is specifically for audio quality (Dolby Atmos, Hi-Res Lossless, Alac, Aac 256)
 	var Tag_string string
-	if core.Dl_atmos {
+	if qualityChainEnabled() {
+		Tag_string = choice.tag
+	} else if core.Dl_atmos {
 		Tag_string = utils.FormatQualityTag("Dolby Atmos")
 	} else if needDlAacLc {
 		Tag_string = utils.FormatQualityTag("Aac 256")
@@ -417,98 +318,53 @@ func downloadTrackSilently(track structs.TrackData, meta *structs.AutoGenerated,
 		return "", errors.New("track not found in metadata")
 	}
 
-	var singerFoldername, albumFoldername string
-	if core.Config.ArtistFolderFormat != "" {
-		if strings.Contains(albumId, "pl.") {
-			singerFoldername = strings.NewReplacer(
-				"{ArtistName}", "Apple Music", "{ArtistId}", "", "{UrlArtistName}", "Apple Music",
-			).Replace(core.Config.ArtistFolderFormat)
-		} else if len(meta.Data[0].Relationships.Artists.Data) > 0 {
-			singerFoldername = strings.NewReplacer(
-				"{UrlArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName),
-				"{ArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName),
-				"{ArtistId}", meta.Data[0].Relationships.Artists.Data[0].ID,
-			).Replace(core.Config.ArtistFolderFormat)
-		} else {
-			singerFoldername = strings.NewReplacer(
-				"{UrlArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName),
-				"{ArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName),
-				"{ArtistId}", "",
-			).Replace(core.Config.ArtistFolderFormat)
-		}
-	}
+	sanitizedSingerFolder, sanitizedAlbumFolder, discFolder, filenameWithExt := session.trackPathParts(albumId, track, trackNum, Quality, Tag_string, Codec)
 
-	if strings.Contains(albumId, "pl.") {
-		albumFoldername = strings.NewReplacer(
-			"{PlaylistName}", core.LimitString(meta.Data[0].Attributes.Name),
-			"{PlaylistId}", albumId, "{Quality}", Quality, "{Codec}", Codec, "{Tag}", Tag_string,
-		).Replace(core.Config.PlaylistFolderFormat)
-	} else {
-		albumFoldername = strings.NewReplacer(
-			"{ReleaseDate}", meta.Data[0].Attributes.ReleaseDate, "{ReleaseYear}", meta.Data[0].Attributes.ReleaseDate[:4],
-			"{ArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName), "{AlbumName}", core.LimitString(meta.Data[0].Attributes.Name),
-			"{UPC}", meta.Data[0].Attributes.Upc, "{RecordLabel}", meta.Data[0].Attributes.RecordLabel,
-			"{Copyright}", meta.Data[0].Attributes.Copyright, "{AlbumId}", albumId,
-			"{Quality}", Quality, "{Codec}", Codec, "{Tag}", Tag_string,
-		).Replace(core.Config.AlbumFolderFormat)
-	}
-	// 移除末尾的编解码/音质标记（如 Alac/Aac/Flac/Mp3）
-	albumFoldername = utils.StripCodecSuffix(albumFoldername)
-
-	songName := strings.NewReplacer(
-		"{SongId}", track.ID,
-		"{SongNumer}", fmt.Sprintf("%02d", trackNum),
-		"{SongName}", core.LimitString(track.Attributes.Name),
-		"{DiscNumber}", fmt.Sprintf("%0d", track.Attributes.DiscNumber),
-		"{TrackNumber}", fmt.Sprintf("%0d", track.Attributes.TrackNumber),
-		"{Quality}", Quality,
-		"{Tag}", Tag_string,
-		"{Codec}", Codec,
-	).Replace(core.Config.SongFileFormat)
-
-	sanitizedSingerFolder := core.ForbiddenNames.ReplaceAllString(singerFoldername, "_")
-	sanitizedAlbumFolder := core.ForbiddenNames.ReplaceAllString(albumFoldername, "_")
-	sanitizedSongName := core.ForbiddenNames.ReplaceAllString(songName, "_")
-	filenameWithExt := fmt.Sprintf("%s.m4a", sanitizedSongName)
-
-	finalArtistDir, finalAlbumDir, finalFilename := utils.EnsureSafePath(baseSaveFolder, sanitizedSingerFolder, sanitizedAlbumFolder, filenameWithExt)
-	var finalSingerFolder string
-	if finalArtistDir != "" {
-		finalSingerFolder = filepath.Join(baseSaveFolder, finalArtistDir)
-	} else {
-		finalSingerFolder = baseSaveFolder
-	}
-	finalAlbumFolder := filepath.Join(finalSingerFolder, finalAlbumDir)
-	if err := os.MkdirAll(finalAlbumFolder, 0755); err != nil {
+	finalArtistDir, finalAlbumDir, finalDiscDir, finalFilename := session.safeTrackPath(baseSaveFolder, sanitizedSingerFolder, sanitizedAlbumFolder, discFolder, filenameWithExt)
+	finalAlbumFolder := filepath.Join(baseSaveFolder, finalArtistDir, finalAlbumDir)
+	finalTrackFolder := filepath.Join(finalAlbumFolder, finalDiscDir)
+	if err := os.MkdirAll(finalTrackFolder, 0755); err != nil {
 		return "", fmt.Errorf("创建专辑目录失败: %w", err)
 	}
-	trackPath := filepath.Join(finalAlbumFolder, finalFilename)
+	trackPath := filepath.Join(finalTrackFolder, finalFilename)
 
 	// 检查文件是否存在：如果使用缓存，检查最终目标路径；否则检查当前路径
 	checkPath := trackPath
 	returnPath := trackPath
 	if finalSaveFolder != baseSaveFolder {
 		// 使用缓存时，检查最终目标路径是否已存在文件
-		var targetSingerFolder string
-		if finalArtistDir != "" {
-			targetSingerFolder = filepath.Join(finalSaveFolder, finalArtistDir)
-		} else {
-			targetSingerFolder = finalSaveFolder
-		}
-		targetAlbumFolder := filepath.Join(targetSingerFolder, finalAlbumDir)
-		checkPath = filepath.Join(targetAlbumFolder, finalFilename)
+		checkPath = filepath.Join(finalSaveFolder, finalArtistDir, finalAlbumDir, finalDiscDir, finalFilename)
 		returnPath = checkPath // 如果文件已存在，返回最终目标路径而非缓存路径
 	}
 
-	exists, err := utils.FileExists(checkPath)
+	existingPath, exists, err := utils.FindExistingFile(checkPath)
 	if err != nil {
 		return "", errors.New("failed to check if track exists")
 	}
+	if !exists && upgradeEnabled() && !needDlAacLc {
+		// 升级模式：旧文件可能位于带有旧音质标记的文件夹中
+		existingPath, exists = findUpgradeSource(session, finalSaveFolder, albumId, track, trackNum, Quality)
+	}
 	if exists {
-		core.SharedLock.Lock()
-		core.OkDict[albumId] = append(core.OkDict[albumId], trackNum)
-		core.SharedLock.Unlock()
-		return returnPath, nil // 返回实际存在文件的路径
+		if upgradeEnabled() && !needDlAacLc && shouldUpgrade(existingPath, manifest.Attributes.ExtendedAssetUrls.EnhancedHls) {
+			trackPath, err = prepareUpgrade(trackPath, existingPath)
+			if err != nil {
+				return "", err
+			}
+		} else {
+			if existingPath != checkPath {
+				// 已存在的文件名称规范化形式不同（如 NFD），返回磁盘上的实际路径
+				returnPath = existingPath
+			}
+			core.SharedLock.Lock()
+			core.OkDict[albumId] = append(core.OkDict[albumId], trackNum)
+			core.SharedLock.Unlock()
+			return returnPath, nil // 返回实际存在文件的路径
+		}
+	}
+
+	if qualityChainEnabled() {
+		metadata.SetStreamVariant(trackPath, metadata.StreamVariant{Tier: choice.tier, Quality: choice.tag, Detail: choice.detail})
 	}
 
 	if needDlAacLc {
@@ -520,9 +376,12 @@ func downloadTrackSilently(track structs.TrackData, meta *structs.AutoGenerated,
 			return "", fmt.Errorf("failed to dl aac-lc: %w", err)
 		}
 	} else {
-		trackM3u8Url, _, _, err := parser.ExtractMedia(manifest.Attributes.ExtendedAssetUrls.EnhancedHls, false)
-		if err != nil {
-			return "", fmt.Errorf("failed to extract info from manifest: %w", err)
+		trackM3u8Url := choice.url
+		if !qualityChainEnabled() {
+			trackM3u8Url, _, _, err = parser.ExtractMedia(manifest.Attributes.ExtendedAssetUrls.EnhancedHls, false)
+			if err != nil {
+				return "", fmt.Errorf("failed to extract info from manifest: %w", err)
+			}
 		}
 		err = runv14.Run(track.ID, trackM3u8Url, trackPath, account, core.Config, progressChan)
 		if err != nil {
@@ -556,7 +415,11 @@ func downloadTrackSilently(track structs.TrackData, meta *structs.AutoGenerated,
 	return trackPath, nil
 }
 
-func Rip(albumId string, storefront string, urlArg_i string, notifier *progress.ProgressNotifier) error {
+// TrackGate 每首曲目开始下载前调用（由队列管理器设置），可阻塞以暂停下载，返回 false 时跳过该曲目
+var TrackGate func(trackNum int) bool
+
+// Rip 下载专辑或播放列表，artistDir 为歌手页面展开时确定的歌手文件夹格式，为空时使用 artist-folder-format
+func Rip(albumId string, storefront string, urlArg_i string, artistDir string, notifier *progress.ProgressNotifier) (err error) {
 	mainAccount, err := core.GetAccountForStorefront(storefront)
 	if err != nil {
 		return err
@@ -566,24 +429,48 @@ func Rip(albumId string, storefront string, urlArg_i string, notifier *progress.
 	if err != nil {
 		return err
 	}
-	var lyricAccount *structs.Account
-	for i := range core.Config.Accounts {
-		acc := &core.Config.Accounts[i]
-		if strings.EqualFold(acc.Storefront, storefront) {
-			lyricAccount = acc
-			break
-		}
+	loadSortNames(albumId, mainAccount, storefront)
+	session := newRipSession(meta, mainAccount, lyricAccountFor(storefront), artistDir)
+
+	if notifier != nil {
+		albumName := meta.Data[0].Attributes.Name
+		notifier.NotifyAlbumStart(albumId, albumName, len(meta.Data[0].Relationships.Tracks.Data))
+		defer func() { notifier.NotifyAlbumEnd(albumId, albumName, err) }()
 	}
 
-	if lyricAccount == nil && core.Config.DefaultLyricStorefront != "" {
-		for i := range core.Config.Accounts {
-			acc := &core.Config.Accounts[i]
-			if strings.EqualFold(acc.Storefront, core.Config.DefaultLyricStorefront) {
-				lyricAccount = acc
-				break
-			}
+	// 未指定 --formats 时按 --atmos/--aac 决定的单一格式下载
+	if len(core.DownloadFormats) == 0 {
+		return ripFormat(session, albumId, storefront, urlArg_i, notifier)
+	}
+
+	origAtmos, origAac := core.Dl_atmos, core.Dl_aac
+	defer func() {
+		core.Dl_atmos, core.Dl_aac = origAtmos, origAac
+	}()
+
+	var failedFormats []string
+	for i, format := range core.DownloadFormats {
+		core.ApplyFormat(format)
+		// 已完成标记不区分格式，切换格式前清空，避免上一种格式的结果使本格式的曲目被跳过
+		core.SharedLock.Lock()
+		delete(core.OkDict, albumId)
+		core.SharedLock.Unlock()
+		logger.Info("🎚️ 格式 %d/%d: %s", i+1, len(core.DownloadFormats), strings.ToUpper(format))
+		if err := ripFormat(session, albumId, storefront, urlArg_i, notifier); err != nil {
+			logger.Error("格式 %s 下载失败: %v", strings.ToUpper(format), err)
+			failedFormats = append(failedFormats, strings.ToUpper(format))
 		}
 	}
+	if len(failedFormats) > 0 {
+		return fmt.Errorf("以下格式下载失败: %s", strings.Join(failedFormats, ", "))
+	}
+	return nil
+}
+
+// ripFormat 按当前格式（core.Dl_atmos/core.Dl_aac）下载专辑，多格式下载时由 Rip 依次调用
+func ripFormat(session *ripSession, albumId string, storefront string, urlArg_i string, notifier *progress.ProgressNotifier) error {
+	meta := session.meta
+	mainAccount := session.mainAccount
 
 	var Codec string
 	if core.Dl_atmos {
@@ -599,12 +486,18 @@ func Rip(albumId string, storefront string, urlArg_i string, notifier *progress.
 	var usingCache bool
 	if core.Dl_atmos {
 		finalSaveFolder = core.Config.AtmosSaveFolder
+	} else if core.Dl_aac && core.Config.AacSaveFolder != "" {
+		finalSaveFolder = core.Config.AacSaveFolder
 	} else {
 		finalSaveFolder = core.Config.AlacSaveFolder
 	}
 
-	// 使用缓存机制
-	baseSaveFolder, finalSaveFolder, usingCache = GetCacheBasePath(finalSaveFolder, albumId)
+	// 使用缓存机制（升级模式需要在目标文件夹内原子替换旧文件，不使用缓存）
+	if upgradeEnabled() {
+		baseSaveFolder, usingCache = finalSaveFolder, false
+	} else {
+		baseSaveFolder, finalSaveFolder, usingCache = GetCacheBasePath(finalSaveFolder, albumId)
+	}
 
 	// 延迟清理函数：如果使用缓存且出错，清理缓存目录
 	var downloadSuccess bool
@@ -614,27 +507,6 @@ func Rip(albumId string, storefront string, urlArg_i string, notifier *progress.
 		}
 	}()
 
-	var singerFoldername, albumFoldername string
-	if core.Config.ArtistFolderFormat != "" {
-		if strings.Contains(albumId, "pl.") {
-			singerFoldername = strings.NewReplacer(
-				"{ArtistName}", "Apple Music", "{ArtistId}", "", "{UrlArtistName}", "Apple Music",
-			).Replace(core.Config.ArtistFolderFormat)
-		} else if len(meta.Data[0].Relationships.Artists.Data) > 0 {
-			singerFoldername = strings.NewReplacer(
-				"{UrlArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName),
-				"{ArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName),
-				"{ArtistId}", meta.Data[0].Relationships.Artists.Data[0].ID,
-			).Replace(core.Config.ArtistFolderFormat)
-		} else {
-			singerFoldername = strings.NewReplacer(
-				"{UrlArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName),
-				"{ArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName),
-				"{ArtistId}", "",
-			).Replace(core.Config.ArtistFolderFormat)
-		}
-	}
-
 	var Quality string
 
 	// Pre-detect album quality by checking all tracks' audio traits
@@ -670,25 +542,14 @@ func Rip(albumId string, storefront string, urlArg_i string, notifier *progress.
 		}
 	}
 
-	if strings.Contains(albumId, "pl.") {
-		albumFoldername = strings.NewReplacer(
-			"{PlaylistName}", core.LimitString(meta.Data[0].Attributes.Name),
-			"{PlaylistId}", albumId, "{Quality}", Quality, "{Codec}", Codec, "{Tag}", Album_Tag_string,
-		).Replace(core.Config.PlaylistFolderFormat)
-	} else {
-		albumFoldername = strings.NewReplacer(
-			"{ReleaseDate}", meta.Data[0].Attributes.ReleaseDate, "{ReleaseYear}", meta.Data[0].Attributes.ReleaseDate[:4],
-			"{ArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName), "{AlbumName}", core.LimitString(meta.Data[0].Attributes.Name),
-			"{UPC}", meta.Data[0].Attributes.Upc, "{RecordLabel}", meta.Data[0].Attributes.RecordLabel,
-			"{Copyright}", meta.Data[0].Attributes.Copyright, "{AlbumId}", albumId,
-			"{Quality}", Quality, "{Codec}", Codec, "{Tag}", Album_Tag_string,
-		).Replace(core.Config.AlbumFolderFormat)
-	}
-	// 移除末尾的编解码/音质标记（如 Alac/Aac/Flac/Mp3）
-	albumFoldername = utils.StripCodecSuffix(albumFoldername)
+	sanitizedSingerFolder := core.ForbiddenNames.ReplaceAllString(buildSingerFolderName(meta, session.artistFolderFormat()), "_")
+	sanitizedAlbumFolder := core.ForbiddenNames.ReplaceAllString(buildAlbumFolderName(meta, albumId, Quality, Codec, Album_Tag_string), "_")
 
-	sanitizedSingerFolder := core.ForbiddenNames.ReplaceAllString(singerFoldername, "_")
-	sanitizedAlbumFolder := core.ForbiddenNames.ReplaceAllString(albumFoldername, "_")
+	// 分碟布局下按最后一张碟的子文件夹名预留长度（碟片号补零后各碟名称等长）
+	var longestDiscFolder string
+	if trackCount := len(meta.Data[0].Relationships.Tracks.Data); trackCount > 0 {
+		longestDiscFolder = core.ForbiddenNames.ReplaceAllString(buildDiscFolderName(meta, albumId, meta.Data[0].Relationships.Tracks.Data[trackCount-1]), "_")
+	}
 
 	var longestFilename string
 	for i := range meta.Data[0].Relationships.Tracks.Data {
@@ -704,7 +565,10 @@ func Rip(albumId string, storefront string, urlArg_i string, notifier *progress.
 		"{Codec}", "ATMOS",
 	).Replace(core.Config.SongFileFormat) + ".m4a"
 
-	finalArtistDir, finalAlbumDir, _ := utils.EnsureSafePath(baseSaveFolder, sanitizedSingerFolder, sanitizedAlbumFolder, longestFilename)
+	finalArtistDir, finalAlbumDir, _, _ := utils.EnsureSafeTrackPath(baseSaveFolder, sanitizedSingerFolder, sanitizedAlbumFolder, longestDiscFolder, longestFilename)
+	// 曲目按同一结果放入专辑文件夹；使用缓存时最终目录下的已存在检查也沿用该结果
+	session.rememberAlbumDirs(baseSaveFolder, sanitizedSingerFolder, sanitizedAlbumFolder, finalArtistDir, finalAlbumDir)
+	session.rememberAlbumDirs(finalSaveFolder, sanitizedSingerFolder, sanitizedAlbumFolder, finalArtistDir, finalAlbumDir)
 
 	var finalSingerFolder string
 	if finalArtistDir != "" {
@@ -722,14 +586,21 @@ func Rip(albumId string, storefront string, urlArg_i string, notifier *progress.
 
 	if core.Config.SaveArtistCover && !(strings.Contains(albumId, "pl.")) {
 		if len(meta.Data[0].Relationships.Artists.Data) > 0 {
-			_, err = metadata.WriteCover(finalSingerFolder, "folder", meta.Data[0].Relationships.Artists.Data[0].Attributes.Artwork.Url)
+			_, err := session.writeCover(finalSingerFolder, "folder", meta.Data[0].Relationships.Artists.Data[0].Attributes.Artwork.Url)
 			if err != nil {
 			}
 		}
 	}
-	covPath, err := metadata.WriteCover(finalAlbumFolder, "cover", meta.Data[0].Attributes.Artwork.URL)
+	covPath, err := session.writeCover(finalAlbumFolder, "cover", meta.Data[0].Attributes.Artwork.URL)
 	if err != nil {
 	}
+	if core.Config.SaveNfo {
+		var artistFolder string
+		if finalArtistDir != "" {
+			artistFolder = finalSingerFolder
+		}
+		writeAlbumNFOs(meta, finalAlbumFolder, artistFolder, mainAccount, storefront, false)
+	}
 	if core.Config.SaveAnimatedArtwork && meta.Data[0].Attributes.EditorialVideo.MotionDetailSquare.Video != "" {
 		motionvideoUrlSquare, _, err := parser.ExtractVideo(meta.Data[0].Attributes.EditorialVideo.MotionDetailSquare.Video)
 		if err == nil {
@@ -755,34 +626,42 @@ func Rip(albumId string, storefront string, urlArg_i string, notifier *progress.
 		}
 	}
 
-	// SelectTracks可能涉及交互式输入，暂停UI
-	if !core.DisableDynamicUI && core.Dl_select {
-		ui.Suspend()
-	}
-	selected := ui.SelectTracks(meta, storefront, urlArg_i)
-	if !core.DisableDynamicUI && core.Dl_select {
-		ui.Resume()
+	// 曲目选择和版权预检与格式无关，多格式下载时只进行一次
+	if session.selected == nil {
+		// SelectTracks可能涉及交互式输入，暂停UI
+		if !core.DisableDynamicUI && core.Dl_select {
+			ui.Suspend()
+		}
+		session.selected = ui.SelectTracks(meta, storefront, urlArg_i)
+		if !core.DisableDynamicUI && core.Dl_select {
+			ui.Resume()
+		}
 	}
-
-	fmt.Println("🔬 正在进行版权预检，请稍候...")
-	var workingAccounts []structs.Account
-	if len(meta.Data[0].Relationships.Tracks.Data) > 0 {
-		firstTrackId := meta.Data[0].Relationships.Tracks.Data[0].ID
-		for _, acc := range core.Config.Accounts {
-			_, err := api.GetInfoFromAdam(firstTrackId, &acc, acc.Storefront)
-			if err == nil {
-				workingAccounts = append(workingAccounts, acc)
-			} else {
-				fmt.Printf("账户 [%s] 无法访问此专辑 (可能无版权)，本次任务将跳过该账户。\n", acc.Name)
+	selected := session.selected
+
+	if session.workingAccounts == nil {
+		fmt.Println("🔬 正在进行版权预检，请稍候...")
+		var workingAccounts []structs.Account
+		if len(meta.Data[0].Relationships.Tracks.Data) > 0 {
+			firstTrackId := meta.Data[0].Relationships.Tracks.Data[0].ID
+			for _, acc := range core.Config.Accounts {
+				_, err := api.GetInfoFromAdam(firstTrackId, &acc, acc.Storefront)
+				if err == nil {
+					workingAccounts = append(workingAccounts, acc)
+				} else {
+					fmt.Printf("账户 [%s] 无法访问此专辑 (可能无版权)，本次任务将跳过该账户。\n", acc.Name)
+				}
 			}
+		} else {
+			return errors.New("专辑中没有曲目")
 		}
-	} else {
-		return errors.New("专辑中没有曲目")
-	}
 
-	if len(workingAccounts) == 0 {
-		return errors.New("所有账户均无法访问此专辑，任务中止")
+		if len(workingAccounts) == 0 {
+			return errors.New("所有账户均无法访问此专辑，任务中止")
+		}
+		session.workingAccounts = workingAccounts
 	}
+	workingAccounts := session.workingAccounts
 
 	albumQualityType := "AAC"
 	albumQualityString := "AAC"
@@ -863,63 +742,32 @@ func Rip(albumId string, storefront string, urlArg_i string, notifier *progress.
 		checkSaveFolder = baseSaveFolder
 	}
 
+	prepareLibrary(finalSaveFolder)
+
 	allFilesExist := true
 	for _, trackNum := range selected {
-		track := meta.Data[0].Relationships.Tracks.Data[trackNum-1]
-
-		// 构建文件路径进行检查
-		var singerFoldername, albumFoldername string
-		if core.Config.ArtistFolderFormat != "" {
-			if strings.Contains(albumId, "pl.") {
-				singerFoldername = strings.NewReplacer(
-					"{ArtistName}", "Apple Music", "{ArtistId}", "", "{UrlArtistName}", "Apple Music",
-				).Replace(core.Config.ArtistFolderFormat)
-			} else if len(meta.Data[0].Relationships.Artists.Data) > 0 {
-				singerFoldername = strings.NewReplacer(
-					"{UrlArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName),
-					"{ArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName),
-					"{ArtistId}", meta.Data[0].Relationships.Artists.Data[0].ID,
-				).Replace(core.Config.ArtistFolderFormat)
-			}
-		}
-
-		if strings.Contains(albumId, "pl.") {
-			albumFoldername = strings.NewReplacer(
-				"{PlaylistName}", core.LimitString(meta.Data[0].Attributes.Name),
-				"{PlaylistId}", albumId,
-			).Replace(core.Config.PlaylistFolderFormat)
-		} else {
-			albumFoldername = strings.NewReplacer(
-				"{ArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName),
-				"{AlbumName}", core.LimitString(meta.Data[0].Attributes.Name),
-				"{AlbumId}", albumId,
-			).Replace(core.Config.AlbumFolderFormat)
+		if upgradeEnabled() {
+			// 升级模式下已存在的曲目需要逐一比较音质，不做整体跳过
+			allFilesExist = false
+			break
 		}
+		track := meta.Data[0].Relationships.Tracks.Data[trackNum-1]
 
-		songName := strings.NewReplacer(
-			"{SongNumer}", fmt.Sprintf("%02d", trackNum),
-			"{SongName}", core.LimitString(track.Attributes.Name),
-		).Replace(core.Config.SongFileFormat)
+		// 构建文件路径进行检查（与下载时使用同一套命名逻辑，包含分碟子文件夹）
+		_, checkFilePath := session.resolveTrackPath(checkSaveFolder, albumId, track, trackNum, Quality, Album_Tag_string, Codec)
 
-		sanitizedSingerFolder := core.ForbiddenNames.ReplaceAllString(singerFoldername, "_")
-		sanitizedAlbumFolder := core.ForbiddenNames.ReplaceAllString(albumFoldername, "_")
-		sanitizedSongName := core.ForbiddenNames.ReplaceAllString(songName, "_")
-		filenameWithExt := fmt.Sprintf("%s.m4a", sanitizedSongName)
-
-		checkArtistDir, checkAlbumDir, checkFilename := utils.EnsureSafePath(checkSaveFolder, sanitizedSingerFolder, sanitizedAlbumFolder, filenameWithExt)
-		var checkSingerFolder string
-		if checkArtistDir != "" {
-			checkSingerFolder = filepath.Join(checkSaveFolder, checkArtistDir)
-		} else {
-			checkSingerFolder = checkSaveFolder
+		_, exists, _ := utils.FindExistingFile(checkFilePath)
+		if !exists {
+			// 曲库索引中已有该曲目（命名格式变化或文件被移动），直接标记为已完成
+			if _, found := findInLibrary(finalSaveFolder, albumId, track, Codec); found {
+				core.SharedLock.Lock()
+				core.OkDict[albumId] = append(core.OkDict[albumId], trackNum)
+				core.SharedLock.Unlock()
+				exists = true
+			}
 		}
-		checkAlbumFolder := filepath.Join(checkSingerFolder, checkAlbumDir)
-		checkFilePath := filepath.Join(checkAlbumFolder, checkFilename)
-
-		exists, _ := utils.FileExists(checkFilePath)
 		if !exists {
 			allFilesExist = false
-			break
 		}
 	}
 
@@ -942,6 +790,8 @@ func Rip(albumId string, storefront string, urlArg_i string, notifier *progress.
 		return nil
 	}
 
+	ui.Job.StartAlbum(meta.Data[0].Attributes.Name, len(selected))
+
 	// 使用批次迭代器进行数据层分批处理
 	batchIterator := structs.NewBatchIterator(selected, core.Config.BatchSize)
 
@@ -959,6 +809,7 @@ func Rip(albumId string, storefront string, urlArg_i string, notifier *progress.
 		}
 
 		// 初始化当前批次的 TrackStatuses
+		ui.Job.StartBatch()
 		core.TrackStatuses = make([]core.TrackStatus, len(batch.Tracks))
 		for i, trackNum := range batch.Tracks {
 			track := meta.Data[0].Relationships.Tracks.Data[trackNum-1]
@@ -1007,6 +858,13 @@ func Rip(albumId string, storefront string, urlArg_i string, notifier *progress.
 
 				trackData := meta.Data[0].Relationships.Tracks.Data[trackIndexInMeta-1]
 
+				if TrackGate != nil && !TrackGate(trackIndexInMeta) {
+					if notifier != nil {
+						notifier.NotifyStatus(statusIndex, "已跳过", "skipped")
+					}
+					return
+				}
+
 				core.SharedLock.Lock()
 				isDone := utils.IsInArray(core.OkDict[albumId], trackIndexInMeta)
 				core.SharedLock.Unlock()
@@ -1064,7 +922,7 @@ func Rip(albumId string, storefront string, urlArg_i string, notifier *progress.
 						progressChan = ch
 					}
 
-					trackPath, err := downloadTrackWithFallback(trackData, meta, albumId, storefront, baseSaveFolder, finalSaveFolder, Codec, covPath, workingAccounts, statusIndex, statusIndex, ui.UpdateStatus, progressChan)
+					trackPath, err := downloadTrackWithFallback(session, trackData, albumId, storefront, baseSaveFolder, finalSaveFolder, Codec, covPath, workingAccounts, statusIndex, statusIndex, ui.UpdateStatus, progressChan)
 					close(progressChan)
 
 					if err != nil {
@@ -1097,36 +955,42 @@ func Rip(albumId string, storefront string, urlArg_i string, notifier *progress.
 					var postDownloadError error
 					wasFixed := false
 
-					// Step 2: Re-encode if necessary
-					if core.Config.FfmpegFix && trackData.Type != "music-videos" {
-						isAAC := core.Dl_aac && *core.Aac_type == "aac-lc"
-						if !isAAC {
-							var fixErr error
-							wasFixed, fixErr = checkAndReEncodeTrack(trackPath, statusIndex, notifier)
-							if fixErr != nil {
-								postDownloadError = fmt.Errorf("修复失败: %w", fixErr)
-							}
+					// Step 2: Verify integrity, re-encode if necessary
+					if (core.Config.VerifyTracks || core.Config.FfmpegFix) && trackData.Type != "music-videos" {
+						var verifyErr error
+						wasFixed, verifyErr = verifyTrack(trackPath, trackData, statusIndex, notifier)
+						if verifyErr != nil {
+							postDownloadError = verifyErr
 						}
 					}
 
 					// Step 3: Write tags (only if previous step was successful)
 					if postDownloadError == nil {
-						var finalLrc string
-						if lyricAccount != nil && (core.Config.EmbedLrc || core.Config.SaveLrcFile) && trackData.Type != "music-videos" {
-							lrcStr, lrcErr := lyrics.Get(storefront, trackData.ID, core.Config.LrcType, core.Config.Language, core.Config.LrcFormat, core.DeveloperToken, lyricAccount.MediaUserToken)
-							if lrcErr == nil {
-								if core.Config.SaveLrcFile {
-									lrcFilename := fmt.Sprintf("%s.lrc", strings.TrimSuffix(filepath.Base(trackPath), filepath.Ext(filepath.Base(trackPath))))
-									_ = metadata.WriteLyrics(filepath.Dir(trackPath), lrcFilename, lrcStr)
-								}
-								if core.Config.EmbedLrc {
-									finalLrc = lrcStr
-								}
+						var finalLrc, lrcSource string
+						if len(session.providers) > 0 && (core.Config.EmbedLrc || len(core.Config.LyricsSidecars) > 0) && trackData.Type != "music-videos" {
+							fetched, lrcErr := session.getLyrics(lyrics.Track{
+								ID:         trackData.ID,
+								ISRC:       trackData.Attributes.Isrc,
+								Artist:     trackData.Attributes.ArtistName,
+								Title:      trackData.Attributes.Name,
+								Album:      trackData.Attributes.AlbumName,
+								Storefront: storefront,
+								DurationMs: trackData.Attributes.DurationInMillis,
+							})
+							switch {
+							case lrcErr == nil:
+								finalLrc = writeLyrics(trackPath, fetched.ttml)
+								lrcSource = fetched.source
+							case errors.Is(lrcErr, lyrics.ErrNoLyrics):
+								logger.Debug("曲目 %s 没有歌词", trackData.ID)
+							default:
+								logger.Warn("⚠️ 获取歌词失败 %s: %v", trackData.Attributes.Name, lrcErr)
 							}
 						}
 
 						// 使用带自动修复功能的标签写入
-						tagErr := metadata.WriteMP4TagsWithRetry(trackPath, finalLrc, meta, trackIndexInMeta, len(meta.Data[0].Relationships.Tracks.Data))
+						tagErr := metadata.WriteMP4TagsWithRetry(trackPath, finalLrc, lrcSource, meta, trackIndexInMeta, len(meta.Data[0].Relationships.Tracks.Data))
+						metadata.ClearStreamVariant(trackPath)
 						if tagErr != nil {
 							postDownloadError = fmt.Errorf("标签写入失败: %w", tagErr)
 						}
@@ -1162,6 +1026,19 @@ func Rip(albumId string, storefront string, urlArg_i string, notifier *progress.
 						}
 					}
 
+					// 升级下载：用新文件原子替换旧文件
+					if err := completeUpgrade(trackPath); err != nil {
+						_ = os.Remove(trackPath)
+						if notifier != nil {
+							notifier.NotifyError(statusIndex, err)
+						}
+						core.SharedLock.Lock()
+						core.Counter.Total++
+						core.Counter.Error++
+						core.SharedLock.Unlock()
+						return
+					}
+
 					// All steps successful
 					core.SharedLock.Lock()
 					core.Counter.Total++
@@ -1357,12 +1234,17 @@ func Rip(albumId string, storefront string, urlArg_i string, notifier *progress.
 		}
 	}
 
+	// 升级后文件夹名称变化的专辑：合并旧文件夹
+	finishFolderUpgrades()
+
 	// 清理只包含封面图片的空文件夹（由于音质标签不一致产生的冗余文件夹）
 	cleanedCount := cleanupEmptyAlbumFolders(finalSaveFolder)
 	if cleanedCount > 0 {
 		logger.Info("🧹 已清理 %d 个冗余空文件夹", cleanedCount)
 	}
 
+	refreshLibrary(filepath.Join(finalSaveFolder, finalArtistDir, finalAlbumDir))
+
 	downloadSuccess = true
 	return nil
 }
@@ -1373,60 +1255,33 @@ func MvDownloader(adamID string, baseSaveDir, artistDir string, storefront strin
 		return "", "", err
 	}
 
-	var trackTotal int
 	var trackNum int
-	var index int
 	if meta != nil {
-		trackTotal = len(meta.Data[0].Relationships.Tracks.Data)
 		for i, track := range meta.Data[0].Relationships.Tracks.Data {
 			if adamID == track.ID {
-				index = i
 				trackNum = i + 1
 			}
 		}
 	}
 
-	// Extract release year for Emby naming standard
-	var releaseYear string
-	if len(MVInfo.Data[0].Attributes.ReleaseDate) >= 4 {
-		releaseYear = MVInfo.Data[0].Attributes.ReleaseDate[:4]
-	}
-
 	// Emby naming standard: {VideoName (Year)}/{VideoName (Year)}.mp4
 	// Artist name is already in the parent folder, no need to repeat
-	var mvFolderName, mvFileName string
 	mvName := core.LimitString(MVInfo.Data[0].Attributes.Name)
-	if releaseYear != "" {
-		mvFolderName = fmt.Sprintf("%s (%s)", mvName, releaseYear)
-		mvFileName = fmt.Sprintf("%s (%s).mp4", mvName, releaseYear)
-	} else {
-		// Fallback without year
-		mvFolderName = mvName
-		mvFileName = fmt.Sprintf("%s.mp4", mvName)
+	mvInfo := mvNameInfo{
+		ID:          adamID,
+		Name:        MVInfo.Data[0].Attributes.Name,
+		ArtistName:  MVInfo.Data[0].Attributes.ArtistName,
+		ReleaseDate: MVInfo.Data[0].Attributes.ReleaseDate,
 	}
-
-	sanitizedMvFolderName := core.ForbiddenNames.ReplaceAllString(mvFolderName, "_")
-	sanitizedMvFileName := core.ForbiddenNames.ReplaceAllString(mvFileName, "_")
-
-	// Use artistDir as sub-folder under MV save folder for organization
-	finalArtistDir, finalMvDir, finalFilename := utils.EnsureSafePath(
-		baseSaveDir, artistDir, sanitizedMvFolderName, sanitizedMvFileName,
-	)
-
-	var finalSingerFolder string
-	if finalArtistDir != "" {
-		finalSingerFolder = filepath.Join(baseSaveDir, finalArtistDir)
-	} else {
-		finalSingerFolder = baseSaveDir
-	}
-	finalMvFolder := filepath.Join(finalSingerFolder, finalMvDir)
-	mvOutPath := filepath.Join(finalMvFolder, finalFilename)
+	finalMvFolder, checkPath := resolveMVPath(baseSaveDir, artistDir, mvInfo, nil, nil)
 	if err := os.MkdirAll(finalMvFolder, 0755); err != nil {
 		return "", "", fmt.Errorf("创建MV目录失败: %w", err)
 	}
-	exists, _ := utils.FileExists(mvOutPath)
-	if exists {
-		return mvOutPath, "已存在", nil
+	if existingPath, exists := findExistingMV(checkPath); exists {
+		if nfoExists, _ := utils.FileExists(metadata.MusicVideoNFOPath(existingPath)); core.Config.SaveNfo && !nfoExists {
+			writeMusicVideoNFO(existingPath, MVInfo)
+		}
+		return existingPath, "已存在", nil
 	}
 
 	mvm3u8url, _, err := runv3.GetWebplayback(adamID, core.DeveloperToken, account.MediaUserToken, true)
@@ -1437,23 +1292,32 @@ func MvDownloader(adamID string, baseSaveDir, artistDir string, storefront strin
 		return "", "", errors.New("media-user-token may be wrong or expired")
 	}
 
-	vidPath := filepath.Join(finalMvFolder, fmt.Sprintf("%s_vid.mp4", adamID))
-	audPath := filepath.Join(finalMvFolder, fmt.Sprintf("%s_aud.mp4", adamID))
-
-	videom3u8url, resolution, err := parser.ExtractVideo(mvm3u8url)
+	formats, err := parser.ListMVFormats(mvm3u8url)
+	if err != nil {
+		return "", "", fmt.Errorf("解析MV播放列表失败: %w", err)
+	}
+	pref := parser.CurrentMVFormat()
+	video, err := pref.SelectVideo(formats.Video)
 	if err != nil {
 		return "", "", fmt.Errorf("提取视频流URL失败: %w", err)
 	}
+	audio, err := pref.SelectAudio(formats.Audio)
+	if err != nil {
+		return "", "", fmt.Errorf("提取音频流URL失败: %w", err)
+	}
+	_, mvOutPath := resolveMVPath(baseSaveDir, artistDir, mvInfo, &video, &audio)
 
 	// 显示视频质量信息
-	if resolution != "" {
-		fmt.Printf("📺 视频质量: %s\n", resolution)
-	}
+	resolution := video.Resolution()
+	fmt.Printf("📺 视频质量: %s %s %s, 音轨: %s\n", resolution, strings.ToUpper(video.Codec), video.RangeName(), audio.CodecName())
+
+	vidPath := filepath.Join(finalMvFolder, fmt.Sprintf("%s_vid.mp4", adamID))
+	audPath := filepath.Join(finalMvFolder, fmt.Sprintf("%s_aud.mp4", adamID))
 
 	// 显示下载开始提示
 	fmt.Println("🎥 开始下载MV...")
 
-	videokeyAndUrls, err := runv3.Run(adamID, videom3u8url, core.DeveloperToken, account.MediaUserToken, true)
+	videokeyAndUrls, err := runv3.Run(adamID, video.URL, core.DeveloperToken, account.MediaUserToken, true)
 	if err != nil {
 		return "", "", fmt.Errorf("获取视频密钥和URL失败: %w", err)
 	}
@@ -1462,11 +1326,7 @@ func MvDownloader(adamID string, baseSaveDir, artistDir string, storefront strin
 		return "", "", fmt.Errorf("下载或解密视频数据失败: %w", err)
 	}
 
-	audiom3u8url, err := parser.ExtractMvAudio(mvm3u8url)
-	if err != nil {
-		return "", "", fmt.Errorf("提取音频流URL失败: %w", err)
-	}
-	audiokeyAndUrls, err := runv3.Run(adamID, audiom3u8url, core.DeveloperToken, account.MediaUserToken, true)
+	audiokeyAndUrls, err := runv3.Run(adamID, audio.URL, core.DeveloperToken, account.MediaUserToken, true)
 	if err != nil {
 		return "", "", fmt.Errorf("获取音频密钥和URL失败: %w", err)
 	}
@@ -1475,47 +1335,26 @@ func MvDownloader(adamID string, baseSaveDir, artistDir string, storefront strin
 		return "", "", fmt.Errorf("下载或解密视频数据失败: %w", err)
 	}
 
-	tags := []string{
-		"tool=",
-		fmt.Sprintf("artist=%s", MVInfo.Data[0].Attributes.ArtistName),
-		fmt.Sprintf("title=%s", MVInfo.Data[0].Attributes.Name),
-		fmt.Sprintf("genre=%s", MVInfo.Data[0].Attributes.GenreNames[0]),
-		fmt.Sprintf("created=%s", MVInfo.Data[0].Attributes.ReleaseDate),
-		fmt.Sprintf("ISRC=%s", MVInfo.Data[0].Attributes.Isrc),
-	}
-
-	if MVInfo.Data[0].Attributes.ContentRating == "explicit" {
-		tags = append(tags, "rating=1")
-	} else if MVInfo.Data[0].Attributes.ContentRating == "clean" {
-		tags = append(tags, "rating=2")
-	} else {
-		tags = append(tags, "rating=0")
+	muxCmd := exec.Command("MP4Box", "-itags", "tool=", "-quiet", "-add", vidPath, "-add", audPath, "-keep-utc", "-new", mvOutPath)
+	if err := muxCmd.Run(); err != nil {
+		return "", "", err
 	}
 
-	if meta != nil {
-		if meta.Data[0].Type == "playlists" && !core.Config.UseSongInfoForPlaylist {
-			tags = append(tags, "disk=1/1", fmt.Sprintf("album=%s", meta.Data[0].Attributes.Name), fmt.Sprintf("track=%d", trackNum), fmt.Sprintf("tracknum=%d/%d", trackNum, trackTotal), fmt.Sprintf("album_artist=%s", meta.Data[0].Attributes.ArtistName), fmt.Sprintf("performer=%s", meta.Data[0].Relationships.Tracks.Data[index].Attributes.ArtistName), fmt.Sprintf("copyright=%s", meta.Data[0].Attributes.Copyright), fmt.Sprintf("UPC=%s", meta.Data[0].Attributes.Upc))
-		} else {
-			tags = append(tags, fmt.Sprintf("album=%s", meta.Data[0].Relationships.Tracks.Data[index].Attributes.AlbumName), fmt.Sprintf("disk=%d/%d", meta.Data[0].Relationships.Tracks.Data[index].Attributes.DiscNumber, meta.Data[0].Relationships.Tracks.Data[trackTotal-1].Attributes.DiscNumber), fmt.Sprintf("track=%d", meta.Data[0].Relationships.Tracks.Data[index].Attributes.TrackNumber), fmt.Sprintf("tracknum=%d/%d", meta.Data[0].Relationships.Tracks.Data[index].Attributes.TrackNumber, meta.Data[0].Attributes.TrackCount), fmt.Sprintf("album_artist=%s", meta.Data[0].Attributes.ArtistName), fmt.Sprintf("performer=%s", meta.Data[0].Relationships.Tracks.Data[index].Attributes.ArtistName), fmt.Sprintf("copyright=%s", meta.Data[0].Attributes.Copyright), fmt.Sprintf("UPC=%s", meta.Data[0].Attributes.Upc))
-		}
-	} else {
-		tags = append(tags, fmt.Sprintf("album=%s", MVInfo.Data[0].Attributes.AlbumName), fmt.Sprintf("disk=%d", MVInfo.Data[0].Attributes.DiscNumber), fmt.Sprintf("track=%d", MVInfo.Data[0].Attributes.TrackNumber), fmt.Sprintf("tracknum=%d", MVInfo.Data[0].Attributes.TrackNumber), fmt.Sprintf("performer=%s", MVInfo.Data[0].Attributes.ArtistName))
+	// 标签与音频曲目使用相同的字段映射，封面作为海报嵌入
+	mvTags := metadata.BuildMVTags(MVInfo, meta, trackNum)
+	baseThumbName := core.ForbiddenNames.ReplaceAllString(mvName, "_") + "_thumbnail"
+	covPath, err := metadata.WriteCover(finalMvFolder, baseThumbName, MVInfo.Data[0].Attributes.Artwork.URL)
+	if err != nil {
+		logger.Warn("⚠️ MV 封面下载失败: %v", err)
+		covPath = ""
+	} else if err := metadata.EmbedArtwork(mvTags, covPath); err != nil {
+		logger.Warn("⚠️ MV 封面嵌入失败: %v", err)
 	}
-
-	var covPath string
-	if true {
-		thumbURL := MVInfo.Data[0].Attributes.Artwork.URL
-		baseThumbName := core.ForbiddenNames.ReplaceAllString(mvName, "_") + "_thumbnail"
-		covPath, err = metadata.WriteCover(finalMvFolder, baseThumbName, thumbURL)
-		if err == nil {
-			tags = append(tags, fmt.Sprintf("cover=%s", covPath))
-		}
+	if err := metadata.WriteMVTags(mvOutPath, mvTags); err != nil {
+		logger.Warn("⚠️ MV 标签写入失败: %v", err)
 	}
-
-	tagsString := strings.Join(tags, ":")
-	muxCmd := exec.Command("MP4Box", "-itags", tagsString, "-quiet", "-add", vidPath, "-add", audPath, "-keep-utc", "-new", mvOutPath)
-	if err := muxCmd.Run(); err != nil {
-		return "", "", err
+	if core.Config.SaveNfo {
+		writeMusicVideoNFO(mvOutPath, MVInfo)
 	}
 	defer func() {
 		_ = os.Remove(vidPath)
@@ -0,0 +1,50 @@
+package downloader
+
+import (
+	"strings"
+
+	"main/internal/library"
+	"main/internal/logger"
+	"main/utils/structs"
+)
+
+// findInLibrary 通过曲库索引（曲目ID或ISRC）查找已下载的曲目，不依赖文件路径
+// 专辑只匹配该专辑下载的文件，播放列表只匹配播放列表下载的文件（文件中不写入专辑ID）；
+// codecs 为可接受的编码类别（library.CodecALAC 等），为空时不限制
+// 未启用 library-index 时始终返回 false
+func findInLibrary(saveFolder, albumId string, track structs.TrackData, codecs ...string) (string, bool) {
+	idx := library.Default()
+	if idx == nil {
+		return "", false
+	}
+	contextID := albumId
+	if strings.Contains(albumId, "pl.") {
+		contextID = ""
+	}
+	return idx.Lookup(saveFolder, track.ID, track.Attributes.Isrc, contextID, codecs...)
+}
+
+// prepareLibrary 确保保存目录已建立曲库索引
+func prepareLibrary(saveFolder string) {
+	idx := library.Default()
+	if idx == nil {
+		return
+	}
+	if err := idx.EnsureRoot(saveFolder); err != nil {
+		logger.Warn("⚠️ 曲库索引扫描失败: %v", err)
+	}
+}
+
+// refreshLibrary 下载完成后更新专辑文件夹的索引并保存
+func refreshLibrary(albumFolder string) {
+	idx := library.Default()
+	if idx == nil {
+		return
+	}
+	if _, err := idx.Refresh(albumFolder); err != nil {
+		logger.Warn("⚠️ 曲库索引更新失败: %v", err)
+	}
+	if err := idx.Save(); err != nil {
+		logger.Warn("⚠️ %v", err)
+	}
+}
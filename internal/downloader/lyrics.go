@@ -0,0 +1,102 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"main/internal/core"
+	"main/internal/logger"
+	"main/utils/lyrics"
+	"main/utils/structs"
+)
+
+// lyricAccountFor 选择获取歌词的账户：优先与 storefront 相同区域的账户，其次 default-lyric-storefront 区域的账户，都没有时为 nil
+func lyricAccountFor(storefront string) *structs.Account {
+	for i := range core.Config.Accounts {
+		acc := &core.Config.Accounts[i]
+		if strings.EqualFold(acc.Storefront, storefront) {
+			return acc
+		}
+	}
+	if core.Config.DefaultLyricStorefront != "" {
+		for i := range core.Config.Accounts {
+			acc := &core.Config.Accounts[i]
+			if strings.EqualFold(acc.Storefront, core.Config.DefaultLyricStorefront) {
+				return acc
+			}
+		}
+	}
+	return nil
+}
+
+// dirProviders 本地歌词文件夹只扫描一次，各专辑共用
+var dirProviders sync.Map // 路径 -> *lyrics.DirProvider
+
+// lyricsProviders 按 lyrics-providers 的顺序创建歌词来源，没有可用于歌词的账户时跳过 apple
+func lyricsProviders(lyricAccount *structs.Account) []lyrics.Provider {
+	var providers []lyrics.Provider
+	for _, cfg := range core.Config.LyricsProviders {
+		switch cfg.Type {
+		case "apple":
+			if lyricAccount == nil {
+				continue
+			}
+			providers = append(providers, &lyrics.AppleProvider{
+				LrcType:        core.Config.LrcType,
+				Language:       core.LyricsOptions("").Language,
+				Token:          core.DeveloperToken,
+				MediaUserToken: lyricAccount.MediaUserToken,
+			})
+		case "directory":
+			p, _ := dirProviders.LoadOrStore(cfg.Path, &lyrics.DirProvider{Dir: cfg.Path})
+			providers = append(providers, p.(*lyrics.DirProvider))
+		case "http":
+			var fields []string
+			for _, f := range strings.Split(cfg.Fields, ",") {
+				if f = strings.TrimSpace(f); f != "" {
+					fields = append(fields, f)
+				}
+			}
+			providers = append(providers, &lyrics.HTTPProvider{URL: cfg.URL, Fields: fields, Headers: cfg.Headers})
+		}
+	}
+	return providers
+}
+
+// lyricSidecarPaths 返回曲目对应的全部歌词文件路径（按 lyrics-sidecars 的顺序）
+func lyricSidecarPaths(trackPath string) []string {
+	base := strings.TrimSuffix(filepath.Base(trackPath), filepath.Ext(trackPath))
+	var paths []string
+	for _, sc := range core.Config.LyricsSidecars {
+		name := strings.ReplaceAll(sc.File, "{SongFileName}", base)
+		paths = append(paths, filepath.Join(filepath.Dir(trackPath), core.ForbiddenNames.ReplaceAllString(name, "_")))
+	}
+	return paths
+}
+
+// writeLyrics 按 lyrics-sidecars 保存歌词文件，并返回按 embed-lrc-format 渲染的内嵌歌词（embed-lrc 关闭时为空）
+// 某种格式无法渲染（如无时间轴的歌词没有 SRT）时只跳过该格式
+func writeLyrics(trackPath, ttml string) string {
+	for i, path := range lyricSidecarPaths(trackPath) {
+		format := core.Config.LyricsSidecars[i].Format
+		text, err := lyrics.Render(ttml, format, core.LyricsOptions(core.Config.LyricsSidecars[i].Mode))
+		if err != nil {
+			logger.Debug("歌词无法输出为 %s: %v", format, err)
+			continue
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			logger.Warn("⚠️ 写入歌词文件失败: %v", err)
+		}
+	}
+	if !core.Config.EmbedLrc {
+		return ""
+	}
+	text, err := lyrics.Render(ttml, core.Config.EmbedLrcFormat, core.LyricsOptions(""))
+	if err != nil {
+		logger.Debug("歌词无法输出为 %s: %v", core.Config.EmbedLrcFormat, err)
+		return ""
+	}
+	return text
+}
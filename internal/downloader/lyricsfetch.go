@@ -0,0 +1,150 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"main/internal/api"
+	"main/internal/core"
+	"main/internal/library"
+	"main/internal/logger"
+	"main/internal/metadata"
+	"main/utils/lyrics"
+
+	"github.com/zhaarey/go-mp4tag"
+)
+
+// LyricsFetchOptions lyrics fetch 选项
+type LyricsFetchOptions struct {
+	Force bool // 已有歌词的曲目也重新获取
+}
+
+// LyricsFetchStats lyrics fetch 结果统计
+type LyricsFetchStats struct {
+	Written  int
+	Existing int // 已有内嵌歌词和全部歌词文件
+	Skipped  int // 标签中没有曲目ID或 ISRC
+	Failed   int
+	Missing  []string // 没有歌词的曲目
+}
+
+// FetchLyrics 为 root 下已下载的 .m4a 补充歌词，不下载音频
+// 曲目通过 APPLE_TRACK_ID、ISRC 或歌手和标题识别，按 lyrics-providers 的顺序获取；
+// 歌词按 lyrics-sidecars 保存，embed-lrc 开启时同时内嵌
+func FetchLyrics(root string, opts LyricsFetchOptions) (LyricsFetchStats, error) {
+	var stats LyricsFetchStats
+	if _, err := os.Stat(root); err != nil {
+		return stats, err
+	}
+	if !core.Config.EmbedLrc && len(core.Config.LyricsSidecars) == 0 {
+		return stats, errors.New("embed-lrc 未开启且没有配置歌词文件（lyrics-sidecars / save-lrc-file）")
+	}
+	if len(core.Config.Accounts) == 0 {
+		return stats, errors.New("没有可用的账户")
+	}
+	storefront := core.Config.Accounts[0].Storefront
+	account, err := core.GetAccountForStorefront(storefront)
+	if err != nil {
+		return stats, err
+	}
+	providers := lyricsProviders(lyricAccountFor(storefront))
+	if len(providers) == 0 {
+		return stats, fmt.Errorf("没有可用的歌词来源（lyrics-providers 只有 apple 时需要 %s 或 default-lyric-storefront 区域的账户）", storefront)
+	}
+	idx := library.Default()
+
+	walkErr := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.EqualFold(filepath.Ext(path), ".m4a") {
+			return nil
+		}
+		old, err := readTags(path)
+		if err != nil {
+			logger.Warn("⚠️ 标签无法读取，跳过: %s", path)
+			stats.Skipped++
+			return nil
+		}
+		if !opts.Force && hasAllLyrics(path, old) {
+			stats.Existing++
+			return nil
+		}
+
+		track := trackFromTags(old, storefront)
+		if track.ID == "" && track.ISRC != "" {
+			if song, err := api.GetSongByISRC(track.ISRC, account, storefront); err != nil {
+				logger.Debug("通过 ISRC 查询曲目失败: %v", err)
+			} else if song != nil {
+				track.ID = song.ID
+			}
+		}
+		if track.ID == "" && track.ISRC == "" && (track.Artist == "" || track.Title == "") {
+			logger.Warn("⚠️ 标签中没有可用的曲目ID、ISRC 或歌手和标题，跳过: %s", path)
+			stats.Skipped++
+			return nil
+		}
+
+		ttml, source, err := lyrics.FetchFirst(providers, track)
+		switch {
+		case errors.Is(err, lyrics.ErrNoLyrics):
+			stats.Missing = append(stats.Missing, path)
+			return nil
+		case err != nil:
+			logger.Warn("⚠️ 获取歌词失败 %s: %v", path, err)
+			stats.Failed++
+			return nil
+		}
+		// 内嵌歌词（未启用时为空）和歌词来源一次写入
+		if err := metadata.WriteTags(path, metadata.LyricsTags(writeLyrics(path, ttml), source)); err != nil {
+			logger.Warn("⚠️ 写入内嵌歌词失败 %s: %v", path, err)
+			stats.Failed++
+			return nil
+		}
+		if idx != nil {
+			_ = idx.Update(path)
+		}
+		logger.Info("🎤 %s", path)
+		stats.Written++
+		return nil
+	})
+	if idx != nil && stats.Written > 0 {
+		if err := idx.Save(); err != nil {
+			logger.Warn("⚠️ 曲库索引保存失败: %v", err)
+		}
+	}
+	return stats, walkErr
+}
+
+// trackFromTags 用文件标签中的信息查找歌词
+func trackFromTags(tags *mp4tag.MP4Tags, storefront string) lyrics.Track {
+	t := lyrics.Track{Artist: tags.Artist, Title: tags.Title, Album: tags.Album, Storefront: storefront}
+	if tags.Custom != nil {
+		t.ID = tags.Custom["APPLE_TRACK_ID"]
+		t.ISRC = tags.Custom["ISRC"]
+	}
+	return t
+}
+
+// readTags 读取文件的标签
+func readTags(path string) (*mp4tag.MP4Tags, error) {
+	mp4, err := mp4tag.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer mp4.Close()
+	return mp4.Read()
+}
+
+// hasAllLyrics 文件已有内嵌歌词（embed-lrc 开启时）且全部歌词文件都已存在
+func hasAllLyrics(path string, tags *mp4tag.MP4Tags) bool {
+	if core.Config.EmbedLrc && tags.Lyrics == "" {
+		return false
+	}
+	for _, sidecar := range lyricSidecarPaths(path) {
+		if _, err := os.Stat(sidecar); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"main/internal/core"
+	"main/internal/parser"
+	"main/utils/runv3"
+	"main/utils/structs"
+)
+
+// PrintMVFormats 列出 MV 的全部视频变体和音轨，并用 * 标出按当前 mv-format 会选中的格式
+func PrintMVFormats(adamID string, account *structs.Account) error {
+	mvm3u8url, _, err := runv3.GetWebplayback(adamID, core.DeveloperToken, account.MediaUserToken, true)
+	if err != nil {
+		return fmt.Errorf("获取MV播放列表失败: %w", err)
+	}
+	if mvm3u8url == "" {
+		return errors.New("media-user-token may be wrong or expired")
+	}
+	formats, err := parser.ListMVFormats(mvm3u8url)
+	if err != nil {
+		return fmt.Errorf("解析MV播放列表失败: %w", err)
+	}
+	pref := parser.CurrentMVFormat()
+	video, videoErr := pref.SelectVideo(formats.Video)
+	audio, audioErr := pref.SelectAudio(formats.Audio)
+
+	mark := func(selected bool) string {
+		if selected {
+			return "*"
+		}
+		return " "
+	}
+	fmt.Println("📺 视频:")
+	for _, v := range formats.Video {
+		fmt.Printf("  %s %-10s %-5s %-13s %7sfps %6d kbps  %s\n",
+			mark(videoErr == nil && v.URL == video.URL),
+			fmt.Sprintf("%dx%d", v.Width, v.Height), strings.ToUpper(v.Codec), v.RangeName(),
+			strconv.FormatFloat(v.FrameRate, 'f', -1, 64), v.Bandwidth, v.Codecs)
+	}
+	fmt.Println("🔊 音轨:")
+	for _, a := range formats.Audio {
+		fmt.Printf("  %s %-6s %-24s gr%d\n", mark(audioErr == nil && a.URL == audio.URL), a.CodecName(), a.GroupID, a.Rank)
+	}
+	if videoErr != nil {
+		fmt.Printf("⚠️ %v\n", videoErr)
+	}
+	if audioErr != nil {
+		fmt.Printf("⚠️ %v\n", audioErr)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package downloader
+
+import (
+	"fmt"
+	"main/internal/core"
+	"main/internal/metadata"
+	"main/internal/parser"
+	"main/internal/utils"
+	"main/utils/structs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// buildSingerFolderName 根据歌手文件夹格式生成歌手文件夹名称（未清理非法字符）
+// 格式为空时返回空字符串，表示不创建歌手文件夹。歌手名称由 metadata.ResolveAlbumArtist 决定，与 AlbumArtist 标签一致
+func buildSingerFolderName(meta *structs.AutoGenerated, format string) string {
+	if format == "" {
+		return ""
+	}
+	artistName, artistId := metadata.ResolveAlbumArtist(meta)
+	return strings.NewReplacer(
+		"{UrlArtistName}", core.LimitString(artistName),
+		"{ArtistName}", core.LimitString(artistName),
+		"{ArtistId}", artistId,
+	).Replace(format)
+}
+
+// buildAlbumFolderName 根据专辑/播放列表文件夹格式生成文件夹名称（未清理非法字符）
+func buildAlbumFolderName(meta *structs.AutoGenerated, albumId, quality, codec, tag string) string {
+	var albumFoldername string
+	if strings.Contains(albumId, "pl.") {
+		albumFoldername = strings.NewReplacer(
+			"{PlaylistName}", core.LimitString(meta.Data[0].Attributes.Name),
+			"{PlaylistId}", albumId, "{Quality}", quality, "{Codec}", codec, "{Tag}", tag,
+		).Replace(core.Config.PlaylistFolderFormat)
+	} else {
+		albumFoldername = strings.NewReplacer(
+			"{ReleaseDate}", meta.Data[0].Attributes.ReleaseDate, "{ReleaseYear}", releaseYear(meta.Data[0].Attributes.ReleaseDate),
+			"{ArtistName}", core.LimitString(meta.Data[0].Attributes.ArtistName), "{AlbumName}", core.LimitString(meta.Data[0].Attributes.Name),
+			"{UPC}", meta.Data[0].Attributes.Upc, "{RecordLabel}", meta.Data[0].Attributes.RecordLabel,
+			"{Copyright}", meta.Data[0].Attributes.Copyright, "{AlbumId}", albumId,
+			"{Quality}", quality, "{Codec}", codec, "{Tag}", tag,
+		).Replace(core.Config.AlbumFolderFormat)
+	}
+	// 移除末尾的编解码/音质标记（如 Alac/Aac/Flac/Mp3）
+	return utils.StripCodecSuffix(albumFoldername)
+}
+
+// buildSongFileName 根据 song-file-format 生成曲目文件名（不含扩展名，未清理非法字符）
+// trackNum 为曲目在专辑/播放列表中的位置；分碟布局下 {SongNumer} 改用碟内曲目号
+func buildSongFileName(meta *structs.AutoGenerated, albumId string, track structs.TrackData, trackNum int, quality, tag, codec string) string {
+	songNumber := trackNum
+	if useDiscFolders(meta, albumId) && track.Attributes.TrackNumber > 0 {
+		songNumber = track.Attributes.TrackNumber
+	}
+	return strings.NewReplacer(
+		"{SongId}", track.ID,
+		"{SongNumer}", fmt.Sprintf("%02d", songNumber),
+		"{SongName}", core.LimitString(track.Attributes.Name),
+		"{DiscNumber}", fmt.Sprintf("%0d", track.Attributes.DiscNumber),
+		"{TrackNumber}", fmt.Sprintf("%0d", track.Attributes.TrackNumber),
+		"{Quality}", quality,
+		"{Tag}", tag,
+		"{Codec}", codec,
+	).Replace(core.Config.SongFileFormat)
+}
+
+// discCount 返回专辑的碟片总数（取所有曲目中最大的碟片号）
+func discCount(meta *structs.AutoGenerated) int {
+	total := 0
+	for _, track := range meta.Data[0].Relationships.Tracks.Data {
+		if track.Attributes.DiscNumber > total {
+			total = track.Attributes.DiscNumber
+		}
+	}
+	return total
+}
+
+// useDiscFolders 判断当前专辑是否使用分碟子文件夹
+// 仅对多碟专辑生效；单碟专辑和播放列表始终保持扁平结构
+func useDiscFolders(meta *structs.AutoGenerated, albumId string) bool {
+	if core.Config.MultiDiscLayout != "disc-folders" || strings.Contains(albumId, "pl.") {
+		return false
+	}
+	return discCount(meta) > 1
+}
+
+// buildDiscFolderName 生成曲目所在碟片的子文件夹名称（未清理非法字符）
+// 不使用分碟布局时返回空字符串。碟片号按碟片总数的位数补零，保证套装专辑（10+ 碟）排序正确
+func buildDiscFolderName(meta *structs.AutoGenerated, albumId string, track structs.TrackData) string {
+	if !useDiscFolders(meta, albumId) {
+		return ""
+	}
+	total := discCount(meta)
+	width := len(strconv.Itoa(total))
+	return strings.NewReplacer(
+		"{DiscNumber}", fmt.Sprintf("%0*d", width, track.Attributes.DiscNumber),
+		"{DiscTotal}", strconv.Itoa(total),
+		"{AlbumName}", core.LimitString(meta.Data[0].Attributes.Name),
+	).Replace(core.Config.DiscFolderFormat)
+}
+
+// trackPathParts 返回曲目相对保存目录的各级名称（已清理非法字符、未做长度处理）
+// 下载、预检和缓存目标检查都必须通过此函数构建路径，确保三者一致
+func (s *ripSession) trackPathParts(albumId string, track structs.TrackData, trackNum int, quality, tag, codec string) (string, string, string, string) {
+	meta := s.meta
+	singerFolder := core.ForbiddenNames.ReplaceAllString(buildSingerFolderName(meta, s.artistFolderFormat()), "_")
+	albumFolder := core.ForbiddenNames.ReplaceAllString(buildAlbumFolderName(meta, albumId, quality, codec, tag), "_")
+	discFolder := core.ForbiddenNames.ReplaceAllString(buildDiscFolderName(meta, albumId, track), "_")
+	songName := core.ForbiddenNames.ReplaceAllString(buildSongFileName(meta, albumId, track, trackNum, quality, tag, codec), "_")
+	return singerFolder, albumFolder, discFolder, fmt.Sprintf("%s.m4a", songName)
+}
+
+// resolveTrackPath 在指定保存目录下计算曲目的最终路径（已处理路径长度限制）
+// 返回值: (专辑文件夹路径, 曲目文件路径)
+func (s *ripSession) resolveTrackPath(saveFolder, albumId string, track structs.TrackData, trackNum int, quality, tag, codec string) (string, string) {
+	singerFolder, albumFolder, discFolder, fileName := s.trackPathParts(albumId, track, trackNum, quality, tag, codec)
+	artistDir, albumDir, discDir, safeFileName := s.safeTrackPath(saveFolder, singerFolder, albumFolder, discFolder, fileName)
+	albumPath := filepath.Join(saveFolder, artistDir, albumDir)
+	return albumPath, filepath.Join(albumPath, discDir, safeFileName)
+}
+
+// albumDirKey 标识保存目录下的一个歌手/专辑文件夹（截断前的名称）
+type albumDirKey struct {
+	saveFolder, singerFolder, albumFolder string
+}
+
+// rememberAlbumDirs 记录 ripFormat 按整张专辑截断后的歌手/专辑文件夹，供各曲目复用
+func (s *ripSession) rememberAlbumDirs(saveFolder, singerFolder, albumFolder, artistDir, albumDir string) {
+	s.mu.Lock()
+	s.albumDirs[albumDirKey{saveFolder, singerFolder, albumFolder}] = [2]string{artistDir, albumDir}
+	s.mu.Unlock()
+}
+
+// safeTrackPath 与 utils.EnsureSafeTrackPath 相同，但专辑文件夹已由 ripFormat 截断过时沿用该结果，
+// 只缩短文件名。否则按各曲目的文件名分别截断，长名称专辑的曲目可能落在不同的哈希文件夹中
+func (s *ripSession) safeTrackPath(saveFolder, singerFolder, albumFolder, discFolder, fileName string) (string, string, string, string) {
+	s.mu.Lock()
+	dirs, ok := s.albumDirs[albumDirKey{saveFolder, singerFolder, albumFolder}]
+	s.mu.Unlock()
+	if ok {
+		discDir, safeFileName := utils.EnsureSafeTrackFile(filepath.Join(saveFolder, dirs[0], dirs[1]), discFolder, fileName)
+		return dirs[0], dirs[1], discDir, safeFileName
+	}
+	return utils.EnsureSafeTrackPath(saveFolder, singerFolder, albumFolder, discFolder, fileName)
+}
+
+// releaseYear 从发行日期中提取年份，日期不完整时返回空字符串
+func releaseYear(releaseDate string) string {
+	if len(releaseDate) >= 4 {
+		return releaseDate[:4]
+	}
+	return ""
+}
+
+// mvNameInfo 生成 MV 路径所需的目录信息
+type mvNameInfo struct {
+	ID          string
+	Name        string
+	ArtistName  string
+	ReleaseDate string
+}
+
+// mvFormatWildcard 格式尚未选定时代替格式占位符，检查已下载文件时匹配任意格式
+const mvFormatWildcard = "\x00"
+
+// buildMVFolderName MV 文件夹名称（Emby 命名规范：{VideoName (Year)}，未清理非法字符）
+func buildMVFolderName(mv mvNameInfo) string {
+	name := core.LimitString(mv.Name)
+	if year := releaseYear(mv.ReleaseDate); year != "" {
+		return fmt.Sprintf("%s (%s)", name, year)
+	}
+	return name
+}
+
+// buildMVFileName 根据 mv-file-format 生成 MV 文件名（不含扩展名，未清理非法字符）
+// 未配置格式时与文件夹同名；video/audio 为 nil 时格式相关的占位符替换为 mvFormatWildcard
+func buildMVFileName(mv mvNameInfo, video *parser.VideoVariant, audio *parser.MVAudioVariant) string {
+	if core.Config.MVFileFormat == "" {
+		return buildMVFolderName(mv)
+	}
+	resolution, videoCodec, videoRange, frameRate, audioCodec := mvFormatWildcard, mvFormatWildcard, mvFormatWildcard, mvFormatWildcard, mvFormatWildcard
+	if video != nil {
+		resolution = fmt.Sprintf("%dp", video.Height)
+		videoCodec = strings.ToUpper(video.Codec)
+		videoRange = video.RangeName()
+		frameRate = ""
+		if video.FrameRate > 0 {
+			frameRate = strconv.FormatFloat(video.FrameRate, 'f', -1, 64)
+		}
+	}
+	if audio != nil {
+		audioCodec = audio.CodecName()
+	}
+	return strings.NewReplacer(
+		"{VideoId}", mv.ID,
+		"{VideoName}", core.LimitString(mv.Name),
+		"{ArtistName}", core.LimitString(mv.ArtistName),
+		"{ReleaseDate}", mv.ReleaseDate,
+		"{ReleaseYear}", releaseYear(mv.ReleaseDate),
+		"{Resolution}", resolution,
+		"{VideoCodec}", videoCodec,
+		"{Range}", videoRange,
+		"{FrameRate}", frameRate,
+		"{AudioCodec}", audioCodec,
+	).Replace(core.Config.MVFileFormat)
+}
+
+// resolveMVPath 在指定保存目录下计算 MV 的文件夹和文件路径（已处理路径长度限制）
+// 格式未选定时文件名中可能包含通配符 *，需要通过 findExistingMV 查找
+func resolveMVPath(saveFolder, artistDir string, mv mvNameInfo, video *parser.VideoVariant, audio *parser.MVAudioVariant) (string, string) {
+	folderName := core.ForbiddenNames.ReplaceAllString(buildMVFolderName(mv), "_")
+	fileName := core.ForbiddenNames.ReplaceAllString(buildMVFileName(mv, video, audio), "_")
+	fileName = strings.ReplaceAll(fileName, mvFormatWildcard, "*") + ".mp4"
+	safeArtistDir, mvDir, safeFileName := utils.EnsureSafePath(saveFolder, artistDir, folderName, fileName)
+	mvFolder := filepath.Join(saveFolder, safeArtistDir, mvDir)
+	return mvFolder, filepath.Join(mvFolder, safeFileName)
+}
+
+// findExistingMV 检查 MV 是否已下载，文件名中的 * 匹配任意格式
+func findExistingMV(mvPath string) (string, bool) {
+	if !strings.Contains(filepath.Base(mvPath), "*") {
+		exists, _ := utils.FileExists(mvPath)
+		return mvPath, exists
+	}
+	entries, err := os.ReadDir(filepath.Dir(mvPath))
+	if err != nil {
+		return "", false
+	}
+	// 文件名已清理过非法字符，只需转义 [
+	pattern := strings.ReplaceAll(filepath.Base(mvPath), "[", "[[]")
+	for _, entry := range entries {
+		if ok, _ := filepath.Match(pattern, entry.Name()); ok && !entry.IsDir() {
+			return filepath.Join(filepath.Dir(mvPath), entry.Name()), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,73 @@
+package downloader
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"main/internal/api"
+	"main/internal/core"
+	"main/internal/logger"
+	"main/internal/metadata"
+	"main/internal/utils"
+	"main/utils/structs"
+)
+
+var artistInfoCache sync.Map // 歌手ID -> *structs.AutoGeneratedArtistInfo
+
+// artistInfo 获取歌手详情，同一歌手只请求一次
+func artistInfo(artistID string, account *structs.Account, storefront string) (*structs.AutoGeneratedArtistInfo, error) {
+	if v, ok := artistInfoCache.Load(artistID); ok {
+		return v.(*structs.AutoGeneratedArtistInfo), nil
+	}
+	info, err := api.GetArtistInfo(artistID, account, storefront)
+	if err != nil {
+		return nil, err
+	}
+	artistInfoCache.Store(artistID, info)
+	return info, nil
+}
+
+// writeAlbumNFOs 写入 album.nfo，artistFolder 非空时同时写入 artist.nfo
+// overwrite 为 false 时保留已存在的 artist.nfo（下载时避免为同一歌手的每张专辑重复请求简介）
+func writeAlbumNFOs(meta *structs.AutoGenerated, albumFolder, artistFolder string, account *structs.Account, storefront string, overwrite bool) {
+	if strings.Contains(meta.Data[0].ID, "pl.") {
+		return
+	}
+	if err := metadata.WriteAlbumNFO(albumFolder, meta); err != nil {
+		logger.Warn("⚠️ 写入 %s 失败: %v", metadata.AlbumNFOName, err)
+	}
+
+	// 歌手文件夹由 ResolveAlbumArtist 决定，artist.nfo 使用同一歌手；合辑等没有对应歌手的文件夹不写入
+	_, artistID := metadata.ResolveAlbumArtist(meta)
+	if artistFolder == "" || artistID == "" {
+		return
+	}
+	nfoPath := filepath.Join(artistFolder, metadata.ArtistNFOName)
+	if exists, _ := utils.FileExists(nfoPath); exists && !overwrite {
+		return
+	}
+	info, err := artistInfo(artistID, account, storefront)
+	if err != nil {
+		logger.Warn("⚠️ 获取歌手简介失败: %v", err)
+		return
+	}
+	if err := metadata.WriteArtistNFO(artistFolder, info); err != nil {
+		logger.Warn("⚠️ 写入 %s 失败: %v", metadata.ArtistNFOName, err)
+	}
+}
+
+// writeMusicVideoNFO 在 MV 文件旁写入 .nfo
+func writeMusicVideoNFO(mvPath string, mv *structs.AutoGeneratedMusicVideo) {
+	if err := metadata.WriteMusicVideoNFO(mvPath, mv); err != nil {
+		logger.Warn("⚠️ 写入 MV NFO 失败: %v", err)
+	}
+}
+
+// nfoArtistFolder 专辑文件夹的上级是否为歌手文件夹（artist-folder-format 为空时专辑直接位于保存目录）
+func nfoArtistFolder(albumFolder string) string {
+	if core.Config.ArtistFolderFormat == "" {
+		return ""
+	}
+	return filepath.Dir(albumFolder)
+}
@@ -0,0 +1,112 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"main/internal/core"
+	"main/internal/parser"
+	"main/internal/utils"
+	"main/utils/structs"
+)
+
+var (
+	// errQualitySkipped 回退链到达 skip，曲目按跳过处理
+	errQualitySkipped = errors.New("无符合音质回退链的版本，已跳过")
+	// errQualityUnavailable 回退链中没有可用的版本（或严格模式下首选音质不可用）
+	errQualityUnavailable = errors.New("无符合要求的音质版本")
+)
+
+// qualityChoice 按音质回退链为曲目选定的版本
+type qualityChoice struct {
+	tier    string
+	url     string // HLS 变体地址（aacLc 时为空）
+	aacLc   bool   // 没有增强 HLS，通过 runv3 下载 AAC-LC
+	quality string // {Quality}
+	tag     string // {Tag} 与 QUALITY 标签
+	codec   string // {Codec}
+	detail  string
+}
+
+// qualityChain 返回回退链中适用于当前下载格式的等级，多格式下载时各格式只在自身的等级中回退
+func qualityChain() []string {
+	return core.QualityChainFor(core.CurrentFormat())
+}
+
+// qualityChainEnabled 判断当前下载格式是否使用音质回退链
+func qualityChainEnabled() bool {
+	return len(qualityChain()) > 0
+}
+
+// selectQuality 按回退链依次尝试各等级，返回第一个可用的版本
+// 严格模式下只接受链中的第一个等级
+func selectQuality(track structs.TrackData, enhancedHls string, account *structs.Account) (qualityChoice, error) {
+	chain := qualityChain()
+	if core.Config.QualityStrict {
+		chain = chain[:1]
+	}
+
+	var variants []parser.AudioVariant
+	if enhancedHls != "" {
+		var err error
+		variants, err = parser.ListAudioVariants(enhancedHls)
+		if err != nil {
+			return qualityChoice{}, fmt.Errorf("failed to extract info from manifest: %w", err)
+		}
+	}
+
+	for _, tier := range chain {
+		if tier == "skip" {
+			recordQualityEvent(track, "", "")
+			return qualityChoice{}, errQualitySkipped
+		}
+		if tier == "aac-256" && enhancedHls == "" && len(account.MediaUserToken) > 50 {
+			// 没有增强 HLS 的曲目只能下载 AAC-LC
+			choice := qualityChoice{tier: tier, aacLc: true, quality: "256kbps", tag: utils.FormatQualityTag("Aac 256"), codec: "AAC", detail: "AAC 256 kbps"}
+			recordQualityEvent(track, choice.tier, choice.detail)
+			return choice, nil
+		}
+		v, ok := parser.MatchTier(variants, tier)
+		if !ok {
+			continue
+		}
+		choice := qualityChoice{tier: tier, url: v.URL, quality: v.Quality(), detail: v.Detail()}
+		switch {
+		case v.Codec == "alac" && v.SampleRate > 48000:
+			choice.tag, choice.codec = utils.FormatQualityTag("Hi-Res Lossless"), "ALAC"
+		case v.Codec == "alac":
+			choice.tag, choice.codec = utils.FormatQualityTag("Alac"), "ALAC"
+		case v.Atmos:
+			choice.tag, choice.codec = utils.FormatQualityTag("Dolby Atmos"), "ATMOS"
+		case v.Codec == "ac-3":
+			choice.tag, choice.codec = "Dolby Audio", "AC3"
+		default:
+			choice.tag, choice.codec = utils.FormatQualityTag("Aac 256"), "AAC"
+		}
+		recordQualityEvent(track, choice.tier, choice.detail)
+		return choice, nil
+	}
+
+	recordQualityEvent(track, "", "")
+	return qualityChoice{}, fmt.Errorf("%w（回退链: %s）", errQualityUnavailable, strings.Join(chain, ", "))
+}
+
+// recordQualityEvent 曲目未获得首选音质时记入运行报告，获得首选音质时清除之前的记录
+func recordQualityEvent(track structs.TrackData, chosen, detail string) {
+	name := trackDisplayName(track)
+	requested := qualityChain()[0]
+	switch chosen {
+	case requested:
+		removeReportEntry(reportQuality, name)
+	case "":
+		addReportEntry(reportQuality, name, fmt.Sprintf("✗ 无可用版本（首选 %s）", requested))
+	default:
+		addReportEntry(reportQuality, name, fmt.Sprintf("↓ %s → %s (%s)", requested, chosen, detail))
+	}
+}
+
+// trackDisplayName 运行报告中显示的曲目名称
+func trackDisplayName(track structs.TrackData) string {
+	return fmt.Sprintf("%s - %s", track.Attributes.ArtistName, track.Attributes.Name)
+}
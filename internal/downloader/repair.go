@@ -0,0 +1,168 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"main/internal/api"
+	"main/internal/core"
+	"main/internal/library"
+	"main/internal/logger"
+	"main/internal/metadata"
+	"main/internal/progress"
+	"main/internal/utils"
+	"main/internal/verify"
+	"main/utils/lyrics"
+)
+
+// brokenSuffix 重新下载前损坏文件的备份后缀，下载失败时恢复
+const brokenSuffix = ".broken"
+
+// RepairFindings 修复 library verify 发现的问题
+// 损坏或标签缺失的文件按专辑重新下载；缺少封面或歌词的文件只补写对应标签；仅含封面的文件夹直接删除
+// ISRC 重复需要人工判断保留哪个文件，不自动处理
+// 返回值: 修复成功和失败的数量
+func RepairFindings(findings []library.Finding, notifier *progress.ProgressNotifier) (int, int) {
+	if len(core.Config.Accounts) == 0 {
+		logger.Error("没有可用的账户，无法修复")
+		return 0, len(findings)
+	}
+	storefront := core.Config.Accounts[0].Storefront
+
+	fixed, failed := 0, 0
+	redownload := make(map[string][]string) // 专辑ID -> 需要重新下载的文件
+	var albumOrder []string
+
+	for _, f := range findings {
+		switch f.Problem {
+		case library.ProblemCoverOnly:
+			if err := os.RemoveAll(f.Path); err != nil {
+				logger.Warn("⚠️ 删除文件夹失败 %s: %v", f.Path, err)
+				failed++
+				continue
+			}
+			logger.Info("🗑️ 已删除: %s", f.Path)
+			fixed++
+		case library.ProblemCorrupt, library.ProblemMissingTags:
+			if f.AlbumID == "" {
+				logger.Warn("⚠️ 无法确定专辑ID，跳过: %s", f.Path)
+				failed++
+				continue
+			}
+			if _, ok := redownload[f.AlbumID]; !ok {
+				albumOrder = append(albumOrder, f.AlbumID)
+			}
+			redownload[f.AlbumID] = append(redownload[f.AlbumID], f.Path)
+		case library.ProblemNoCover, library.ProblemNoLyrics:
+			if err := repairTags(f, storefront); err != nil {
+				logger.Warn("⚠️ 补写标签失败 %s: %v", f.Path, err)
+				failed++
+				continue
+			}
+			logger.Info("🏷️ 已修复（%s）: %s", library.ProblemNames[f.Problem], f.Path)
+			fixed++
+		case library.ProblemDuplicateISRC:
+			// 需要人工处理
+		}
+	}
+
+	for _, albumID := range albumOrder {
+		paths := redownload[albumID]
+		repaired, err := redownloadAlbumTracks(albumID, storefront, paths, notifier)
+		if err != nil {
+			logger.Warn("⚠️ 专辑 %s 重新下载失败: %v", albumID, err)
+		}
+		fixed += repaired
+		failed += len(paths) - repaired
+	}
+	return fixed, failed
+}
+
+// redownloadAlbumTracks 备份损坏的文件后重新下载专辑（已存在的完好曲目会被跳过）
+// 单首曲目下载失败时 Rip 不返回错误，因此逐个检查：原路径重新出现且通过结构校验时删除备份，否则恢复原文件
+// 返回值: 修复成功的数量
+func redownloadAlbumTracks(albumID, storefront string, paths []string, notifier *progress.ProgressNotifier) (int, error) {
+	var backups []string
+	for _, path := range paths {
+		if err := os.Rename(path, path+brokenSuffix); err != nil {
+			restoreBackups(backups)
+			return 0, fmt.Errorf("备份损坏文件失败: %w", err)
+		}
+		backups = append(backups, path)
+	}
+
+	logger.Info("🔁 重新下载专辑 %s（%d 个文件）", albumID, len(paths))
+	if err := Rip(albumID, storefront, "", "", notifier); err != nil {
+		restoreBackups(backups)
+		return 0, err
+	}
+	repaired := 0
+	for _, path := range backups {
+		if exists, _ := utils.FileExists(path); !exists || verify.File(path, 0).Corrupt() {
+			logger.Warn("⚠️ 重新下载未得到完好的文件，已恢复原文件: %s", path)
+			_ = os.Remove(path)
+			restoreBackups([]string{path})
+			continue
+		}
+		_ = os.Remove(path + brokenSuffix)
+		repaired++
+	}
+	return repaired, nil
+}
+
+func restoreBackups(paths []string) {
+	for _, path := range paths {
+		_ = os.Rename(path+brokenSuffix, path)
+	}
+}
+
+// repairTags 为文件补写缺失的封面或歌词，不改动其他标签和音频数据
+func repairTags(f library.Finding, storefront string) error {
+	if f.AlbumID == "" || f.TrackID == "" {
+		return errors.New("标签中没有专辑ID或曲目ID")
+	}
+	account, err := core.GetAccountForStorefront(storefront)
+	if err != nil {
+		return err
+	}
+
+	switch f.Problem {
+	case library.ProblemNoLyrics:
+		track := lyrics.Track{ID: f.TrackID, Storefront: storefront}
+		if tags, err := readTags(f.Path); err == nil {
+			track = trackFromTags(tags, storefront)
+			track.ID = f.TrackID
+		}
+		ttml, source, err := lyrics.FetchFirst(lyricsProviders(account), track)
+		if err != nil {
+			return err
+		}
+		lrc, err := lyrics.Render(ttml, core.Config.EmbedLrcFormat, core.LyricsOptions(""))
+		if err != nil {
+			return err
+		}
+		return metadata.WriteTags(f.Path, metadata.LyricsTags(lrc, source))
+	case library.ProblemNoCover:
+		meta, err := api.GetMeta(f.AlbumID, account, storefront)
+		if err != nil {
+			return err
+		}
+		tempDir, err := os.MkdirTemp("", "amdl-cover-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tempDir)
+		covPath, err := metadata.WriteCover(tempDir, "cover", meta.Data[0].Attributes.Artwork.URL)
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command("MP4Box", "-quiet", "-itags", "cover="+covPath, f.Path)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("MP4Box 写入封面失败: %v %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package downloader
+
+import (
+	"sync"
+
+	"main/internal/logger"
+)
+
+// 运行报告的分类
+const (
+	reportQuality = "quality" // 未获得首选音质的曲目
+	reportVerify  = "verify"  // 完整性校验发现问题的曲目
+)
+
+var reportTitles = map[string]string{
+	reportQuality: "🎚️ 音质回退报告",
+	reportVerify:  "🔍 完整性校验报告",
+}
+
+// runReport 本次运行中需要在结束时汇总输出的曲目记录
+// 同一曲目可能因重试被记录多次，只保留最后一次
+var runReport = struct {
+	sync.Mutex
+	entries map[string]map[string]string // 分类 -> 曲目 -> 说明
+	order   map[string][]string
+}{
+	entries: make(map[string]map[string]string),
+	order:   make(map[string][]string),
+}
+
+// addReportEntry 向运行报告添加一条记录
+func addReportEntry(section, track, detail string) {
+	runReport.Lock()
+	defer runReport.Unlock()
+	if runReport.entries[section] == nil {
+		runReport.entries[section] = make(map[string]string)
+	}
+	if _, ok := runReport.entries[section][track]; !ok {
+		runReport.order[section] = append(runReport.order[section], track)
+	}
+	runReport.entries[section][track] = detail
+}
+
+// removeReportEntry 曲目重试成功后移除之前的记录
+func removeReportEntry(section, track string) {
+	runReport.Lock()
+	defer runReport.Unlock()
+	if _, ok := runReport.entries[section][track]; !ok {
+		return
+	}
+	delete(runReport.entries[section], track)
+	order := runReport.order[section][:0]
+	for _, name := range runReport.order[section] {
+		if name != track {
+			order = append(order, name)
+		}
+	}
+	runReport.order[section] = order
+}
+
+// PrintRunReport 在运行结束时输出音质回退和完整性校验的汇总
+func PrintRunReport() {
+	runReport.Lock()
+	defer runReport.Unlock()
+	for _, section := range []string{reportQuality, reportVerify} {
+		if len(runReport.order[section]) == 0 {
+			continue
+		}
+		logger.Info("\n%s:", reportTitles[section])
+		for _, track := range runReport.order[section] {
+			logger.Info("  %s: %s", track, runReport.entries[section][track])
+		}
+	}
+}
+
+// ReportEntry 运行报告中的一条记录
+type ReportEntry struct {
+	Section string // quality 或 verify
+	Track   string
+	Detail  string
+}
+
+// RunReportEntries 返回运行报告中的全部记录
+func RunReportEntries() []ReportEntry {
+	runReport.Lock()
+	defer runReport.Unlock()
+	var entries []ReportEntry
+	for _, section := range []string{reportQuality, reportVerify} {
+		for _, track := range runReport.order[section] {
+			entries = append(entries, ReportEntry{Section: section, Track: track, Detail: runReport.entries[section][track]})
+		}
+	}
+	return entries
+}
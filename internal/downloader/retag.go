@@ -0,0 +1,277 @@
+package downloader
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"main/internal/api"
+	"main/internal/core"
+	"main/internal/library"
+	"main/internal/logger"
+	"main/internal/metadata"
+	"main/internal/utils"
+	"main/utils/structs"
+
+	"github.com/zhaarey/go-mp4tag"
+)
+
+// RetagOptions retag 选项
+type RetagOptions struct {
+	Fields []string // 只重写这些字段，为空时重写全部
+	DryRun bool     // 只显示差异，不写入文件
+}
+
+// RetagStats retag 结果统计
+type RetagStats struct {
+	Updated   int
+	Unchanged int
+	Skipped   int // 找不到ID或目录中已没有该曲目
+	Failed    int
+	NFOs      int // 重新生成的 NFO 数量
+}
+
+// retagger 一次 retag 运行的状态，按专辑缓存目录元数据
+type retagger struct {
+	opts       RetagOptions
+	account    *structs.Account
+	storefront string
+	albums     map[string]*structs.AutoGenerated
+	albumErrs  map[string]error
+	folders    map[string]string // 专辑文件夹 -> 专辑ID，用于重新生成 NFO
+}
+
+// Retag 用最新的目录元数据重写 root 下所有 .m4a 文件的标签，不改动音频数据
+// 曲目通过标签中的专辑ID（ItunesAlbumID）、APPLE_TRACK_ID 或 ISRC 识别；
+// 文件中已有的音质标签、歌词和封面保持不变
+func Retag(root string, opts RetagOptions) (RetagStats, error) {
+	var stats RetagStats
+	if _, err := os.Stat(root); err != nil {
+		return stats, err
+	}
+	if len(core.Config.Accounts) == 0 {
+		return stats, errors.New("没有可用的账户")
+	}
+	storefront := core.Config.Accounts[0].Storefront
+	account, err := core.GetAccountForStorefront(storefront)
+	if err != nil {
+		return stats, err
+	}
+	r := &retagger{
+		opts:       opts,
+		account:    account,
+		storefront: storefront,
+		albums:     make(map[string]*structs.AutoGenerated),
+		albumErrs:  make(map[string]error),
+		folders:    make(map[string]string),
+	}
+	idx := library.Default()
+
+	walkErr := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(path), ".nfo") {
+			if r.refreshMusicVideoNFO(path) {
+				stats.NFOs++
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".m4a") {
+			return nil
+		}
+		changed, err := r.retagFile(path)
+		switch {
+		case errors.Is(err, errRetagSkipped):
+			stats.Skipped++
+		case err != nil:
+			logger.Warn("⚠️ %s: %v", path, err)
+			stats.Failed++
+		case changed:
+			stats.Updated++
+			if idx != nil && !opts.DryRun {
+				_ = idx.Update(path)
+			}
+		default:
+			stats.Unchanged++
+		}
+		return nil
+	})
+	stats.NFOs += r.refreshAlbumNFOs()
+	if idx != nil && !opts.DryRun && stats.Updated > 0 {
+		if err := idx.Save(); err != nil {
+			logger.Warn("⚠️ 曲库索引保存失败: %v", err)
+		}
+	}
+	return stats, walkErr
+}
+
+// errRetagSkipped 文件无法对应到目录中的曲目
+var errRetagSkipped = errors.New("已跳过")
+
+// retagFile 重写单个文件的标签，返回是否有字段发生变化
+func (r *retagger) retagFile(path string) (bool, error) {
+	mp4, err := mp4tag.Open(path)
+	if err != nil {
+		logger.Warn("⚠️ 标签无法读取，跳过（可使用 library verify --repair 修复）: %s", path)
+		return false, errRetagSkipped
+	}
+	old, err := mp4.Read()
+	mp4.Close()
+	if err != nil {
+		logger.Warn("⚠️ 标签无法读取，跳过（可使用 library verify --repair 修复）: %s", path)
+		return false, errRetagSkipped
+	}
+
+	var trackID, isrc, albumID string
+	if old.Custom != nil {
+		trackID = old.Custom["APPLE_TRACK_ID"]
+		isrc = old.Custom["ISRC"]
+	}
+	if old.ItunesAlbumID > 0 {
+		albumID = strconv.Itoa(int(old.ItunesAlbumID))
+	}
+	// 专辑下载的文件都带有专辑ID；没有时可能是播放列表下载的文件，需要核对专辑标签
+	fromAlbum := albumID != ""
+	if albumID == "" {
+		albumID, trackID = r.resolveAlbum(trackID, isrc)
+	}
+	if albumID == "" {
+		logger.Warn("⚠️ 标签中没有可用的专辑ID、曲目ID或 ISRC，跳过: %s", path)
+		return false, errRetagSkipped
+	}
+
+	meta, err := r.albumMeta(albumID)
+	if err != nil {
+		return false, err
+	}
+	trackNum, ok := metadata.FindTrack(meta, trackID, isrc)
+	if !ok {
+		logger.Warn("⚠️ 专辑 %s 中找不到该曲目，跳过: %s", albumID, path)
+		return false, errRetagSkipped
+	}
+	if fromAlbum {
+		r.folders[albumFolderOf(path, meta, albumID, trackNum)] = albumID
+	} else if albumName := meta.Data[0].Relationships.Tracks.Data[trackNum-1].Attributes.AlbumName; !strings.EqualFold(old.Album, utils.StripCodecSuffix(albumName)) {
+		// 专辑标签是播放列表名称（未开启 use-songinfo-for-playlist），按原专辑重写会破坏播放列表的标签
+		logger.Warn("⚠️ 播放列表下载的曲目（专辑标签与原专辑不符），跳过: %s", path)
+		return false, errRetagSkipped
+	}
+
+	// 音质描述的是文件本身，不能按当前的下载模式重新推断
+	quality := metadata.FileQuality(path, old)
+	if quality == "" {
+		logger.Warn("⚠️ 无法确定文件音质，跳过: %s", path)
+		return false, errRetagSkipped
+	}
+	fresh := metadata.BuildMP4Tags(path, "", "", meta, trackNum, len(meta.Data[0].Relationships.Tracks.Data), quality)
+	fresh = metadata.FilterTags(fresh, r.opts.Fields)
+
+	changes := metadata.DiffTags(old, fresh, r.opts.Fields)
+	if len(changes) == 0 {
+		return false, nil
+	}
+	logger.Info("🏷️ %s", path)
+	for _, c := range changes {
+		logger.Info("    %s: %q → %q", c.Field, c.Old, c.New)
+	}
+	if r.opts.DryRun {
+		return true, nil
+	}
+	if err := metadata.WriteTags(path, fresh); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// resolveAlbum 标签中没有专辑ID时，通过曲目ID或 ISRC 查询所属专辑，返回专辑ID和曲目ID
+func (r *retagger) resolveAlbum(trackID, isrc string) (string, string) {
+	var song *structs.SongData
+	var err error
+	if trackID != "" {
+		song, err = api.GetInfoFromAdam(trackID, r.account, r.storefront)
+	}
+	if song == nil && isrc != "" {
+		song, err = api.GetSongByISRC(isrc, r.account, r.storefront)
+	}
+	if err != nil {
+		logger.Debug("查询曲目所属专辑失败: %v", err)
+	}
+	if song == nil || len(song.Relationships.Albums.Data) == 0 {
+		return "", trackID
+	}
+	return song.Relationships.Albums.Data[0].ID, song.ID
+}
+
+// albumMeta 获取专辑元数据，同一专辑只请求一次
+func (r *retagger) albumMeta(albumID string) (*structs.AutoGenerated, error) {
+	if meta, ok := r.albums[albumID]; ok {
+		return meta, nil
+	}
+	if err, ok := r.albumErrs[albumID]; ok {
+		return nil, err
+	}
+	meta, err := api.GetMeta(albumID, r.account, r.storefront)
+	if err != nil {
+		r.albumErrs[albumID] = err
+		return nil, err
+	}
+	loadSortNames(albumID, r.account, r.storefront)
+	r.albums[albumID] = meta
+	return meta, nil
+}
+
+// albumFolderOf 曲目所在的专辑文件夹（分碟布局下为碟片子文件夹的上级）
+func albumFolderOf(path string, meta *structs.AutoGenerated, albumID string, trackNum int) string {
+	dir := filepath.Dir(path)
+	track := meta.Data[0].Relationships.Tracks.Data[trackNum-1]
+	discFolder := core.ForbiddenNames.ReplaceAllString(buildDiscFolderName(meta, albumID, track), "_")
+	if discFolder != "" && filepath.Base(dir) == discFolder {
+		return filepath.Dir(dir)
+	}
+	return dir
+}
+
+// refreshAlbumNFOs 为本次处理过的专辑重新生成 NFO
+// 只包含带专辑ID的文件所在的文件夹，播放列表文件夹中不会写入原专辑的 album.nfo
+// 开启 save-nfo 或文件夹中已有 album.nfo 时才写入，避免给从未生成过 NFO 的曲库添加文件
+func (r *retagger) refreshAlbumNFOs() int {
+	if r.opts.DryRun {
+		return 0
+	}
+	count := 0
+	for folder, albumID := range r.folders {
+		if exists, _ := utils.FileExists(filepath.Join(folder, metadata.AlbumNFOName)); !exists && !core.Config.SaveNfo {
+			continue
+		}
+		artistFolder := nfoArtistFolder(folder)
+		if artistFolder != "" && !core.Config.SaveNfo {
+			if exists, _ := utils.FileExists(filepath.Join(artistFolder, metadata.ArtistNFOName)); !exists {
+				artistFolder = ""
+			}
+		}
+		writeAlbumNFOs(r.albums[albumID], folder, artistFolder, r.account, r.storefront, true)
+		count++
+	}
+	return count
+}
+
+// refreshMusicVideoNFO 用最新的目录元数据重写 MV 的 .nfo
+func (r *retagger) refreshMusicVideoNFO(path string) bool {
+	kind, id, err := metadata.ReadNFOAppleID(path)
+	if err != nil || kind != "musicvideo" || r.opts.DryRun {
+		return false
+	}
+	mv, err := api.GetMVInfoFromAdam(id, r.account, r.storefront)
+	if err != nil || len(mv.Data) == 0 {
+		logger.Warn("⚠️ 获取 MV %s 信息失败，跳过: %s", id, path)
+		return false
+	}
+	if err := metadata.WriteMusicVideoNFO(strings.TrimSuffix(path, filepath.Ext(path))+".mp4", mv); err != nil {
+		logger.Warn("⚠️ %s: %v", path, err)
+		return false
+	}
+	return true
+}
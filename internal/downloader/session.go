@@ -0,0 +1,122 @@
+package downloader
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"main/internal/core"
+	"main/internal/metadata"
+	"main/utils/lyrics"
+	"main/utils/structs"
+)
+
+// ripSession 同一专辑按多种格式下载时共享的数据
+// 元数据、曲目选择、可用账户、封面和歌词只获取一次，各格式依次复用
+type ripSession struct {
+	meta        *structs.AutoGenerated
+	mainAccount *structs.Account
+	providers   []lyrics.Provider // 歌词来源
+	artistDir   string            // 歌手页面展开时确定的歌手文件夹格式，为空时使用 artist-folder-format
+
+	selected        []int
+	workingAccounts []structs.Account
+
+	mu        sync.Mutex
+	covers    map[string]string         // 封面URL -> 已下载的本地文件
+	lrcs      map[string]fetchedLyrics  // 曲目ID -> 歌词
+	albumDirs map[albumDirKey][2]string // 截断前的文件夹 -> [歌手文件夹, 专辑文件夹]（已截断），随会话结束释放
+}
+
+// fetchedLyrics 获取到的 TTML 歌词及其来源
+type fetchedLyrics struct {
+	ttml   string
+	source string
+}
+
+func newRipSession(meta *structs.AutoGenerated, mainAccount, lyricAccount *structs.Account, artistDir string) *ripSession {
+	return &ripSession{
+		meta:        meta,
+		mainAccount: mainAccount,
+		providers:   lyricsProviders(lyricAccount),
+		artistDir:   artistDir,
+		covers:      make(map[string]string),
+		lrcs:        make(map[string]fetchedLyrics),
+		albumDirs:   make(map[albumDirKey][2]string),
+	}
+}
+
+// artistFolderFormat 本次下载使用的歌手文件夹格式
+func (s *ripSession) artistFolderFormat() string {
+	if s.artistDir != "" {
+		return s.artistDir
+	}
+	return core.Config.ArtistFolderFormat
+}
+
+// writeCover 写入封面：同一URL已在其他格式的文件夹中下载过时直接复制，避免重复请求
+func (s *ripSession) writeCover(folder, name, url string) (string, error) {
+	s.mu.Lock()
+	cached, ok := s.covers[url]
+	s.mu.Unlock()
+
+	if ok && filepath.Ext(cached) != "" {
+		target := filepath.Join(folder, name+filepath.Ext(cached))
+		if target == cached {
+			return cached, nil
+		}
+		if err := copyFile(cached, target); err == nil {
+			return target, nil
+		}
+	}
+
+	covPath, err := metadata.WriteCover(folder, name, url)
+	if err != nil {
+		return covPath, err
+	}
+	s.mu.Lock()
+	s.covers[url] = covPath
+	s.mu.Unlock()
+	return covPath, nil
+}
+
+// getLyrics 按 lyrics-providers 的顺序获取曲目的 TTML 歌词，同一曲目只请求一次（各输出格式由 writeLyrics 渲染）
+func (s *ripSession) getLyrics(t lyrics.Track) (fetchedLyrics, error) {
+	s.mu.Lock()
+	cached, ok := s.lrcs[t.ID]
+	s.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	ttml, source, err := lyrics.FetchFirst(s.providers, t)
+	if err != nil {
+		return fetchedLyrics{}, err
+	}
+	fetched := fetchedLyrics{ttml: ttml, source: source}
+	s.mu.Lock()
+	s.lrcs[t.ID] = fetched
+	s.mu.Unlock()
+	return fetched, nil
+}
+
+// copyFile 复制文件（目标已存在时覆盖）
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
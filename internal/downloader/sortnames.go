@@ -0,0 +1,23 @@
+package downloader
+
+import (
+	"main/internal/api"
+	"main/internal/logger"
+	"main/internal/metadata"
+	"main/utils/structs"
+)
+
+// loadSortNames 标签方案设置了 sort-language 时，以该语言获取专辑元数据，供排序标签使用
+// 获取失败时排序标签回退为原名称
+func loadSortNames(albumId string, account *structs.Account, storefront string) {
+	lang := metadata.SortLanguage()
+	if lang == "" {
+		return
+	}
+	sortMeta, err := api.GetMetaInLanguage(albumId, account, storefront, lang)
+	if err != nil {
+		logger.Warn("⚠️ 获取排序名称（%s）失败，排序标签使用原名称: %v", lang, err)
+		return
+	}
+	metadata.SetSortMetadata(sortMeta)
+}
@@ -0,0 +1,231 @@
+package downloader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"main/internal/core"
+	"main/internal/library"
+	"main/internal/logger"
+	"main/internal/metadata"
+	"main/internal/parser"
+	"main/internal/utils"
+	"main/utils/structs"
+
+	"github.com/zhaarey/go-mp4tag"
+)
+
+// upgradeDirName 升级下载的临时目录名（位于目标文件夹内，保证替换时为同一文件系统内的原子重命名）
+const upgradeDirName = ".upgrade"
+
+// audioQuality 用于比较音质高低的描述
+type audioQuality struct {
+	lossless   bool
+	bitDepth   int
+	sampleRate int
+}
+
+// betterThan 判断 q 是否优于 other：无损优于有损，其次比较位深和采样率
+func (q audioQuality) betterThan(other audioQuality) bool {
+	if q.lossless != other.lossless {
+		return q.lossless
+	}
+	if q.bitDepth != other.bitDepth {
+		return q.bitDepth > other.bitDepth
+	}
+	return q.sampleRate > other.sampleRate
+}
+
+func (q audioQuality) String() string {
+	if !q.lossless {
+		return "AAC"
+	}
+	return fmt.Sprintf("%dbit/%.1fkHz", q.bitDepth, float64(q.sampleRate)/1000.0)
+}
+
+// upgradeTarget 一次进行中的升级：临时文件完成后替换 oldPath，最终位于 finalPath
+type upgradeTarget struct {
+	finalPath string
+	oldPath   string
+}
+
+var (
+	pendingUpgrades   = make(map[string]upgradeTarget) // 键为临时文件路径
+	upgradedFolders   = make(map[string]string)        // 旧文件夹 -> 新文件夹
+	pendingUpgradesMu sync.Mutex
+)
+
+// upgradeEnabled 判断当前模式是否支持音质升级（仅 ALAC 模式，杜比全景声和 AAC 没有更高音质可升级）
+func upgradeEnabled() bool {
+	return core.Upgrade && !core.Dl_atmos && !core.Dl_aac
+}
+
+// existingQuality 读取已有文件的音质：优先使用实际流信息，失败时回退到 QUALITY 标签
+func existingQuality(path string) (audioQuality, bool) {
+	if info, err := metadata.ReadStreamInfo(path); err == nil && info.Codec != "" {
+		return audioQuality{lossless: info.Lossless(), bitDepth: info.BitDepth, sampleRate: info.SampleRate}, true
+	}
+
+	mp4, err := mp4tag.Open(path)
+	if err != nil {
+		return audioQuality{}, false
+	}
+	defer mp4.Close()
+	tags, err := mp4.Read()
+	if err != nil || tags.Custom == nil {
+		return audioQuality{}, false
+	}
+	switch tags.Custom["QUALITY"] {
+	case "Hi-Res Lossless":
+		return audioQuality{lossless: true, bitDepth: 24, sampleRate: 88200}, true
+	case "Alac":
+		return audioQuality{lossless: true, bitDepth: 16, sampleRate: 44100}, true
+	case "Aac 256":
+		return audioQuality{}, true
+	}
+	return audioQuality{}, false
+}
+
+// availableQuality 解析 parser.ExtractMedia 返回的文件名音质（如 "24B-96.0kHz"）
+func availableQuality(qualityForFilename string) (audioQuality, bool) {
+	var bitDepth int
+	var khz float64
+	if _, err := fmt.Sscanf(qualityForFilename, "%dB-%fkHz", &bitDepth, &khz); err != nil {
+		return audioQuality{}, false
+	}
+	return audioQuality{lossless: true, bitDepth: bitDepth, sampleRate: int(khz*1000 + 0.5)}, true
+}
+
+// shouldUpgrade 比较已有文件与当前可下载的音质，只有能提升音质时返回 true
+func shouldUpgrade(existingPath, enhancedHls string) bool {
+	current, ok := existingQuality(existingPath)
+	if !ok {
+		return false
+	}
+	_, qualityForFilename, _, err := parser.ExtractMedia(enhancedHls, false)
+	if err != nil {
+		return false
+	}
+	available, ok := availableQuality(qualityForFilename)
+	if !ok || !available.betterThan(current) {
+		return false
+	}
+	logger.Debug("升级 %s: %s -> %s", filepath.Base(existingPath), current, available)
+	return true
+}
+
+// findUpgradeSource 查找曲目已下载的旧文件
+// 旧文件所在的专辑文件夹和文件名可能带有不同的 {Tag}/{Codec}，因此按曲库索引和所有可能的标记依次查找
+func findUpgradeSource(session *ripSession, saveFolder, albumId string, track structs.TrackData, trackNum int, quality string) (string, bool) {
+	// 杜比全景声文件不是无损版本的升级来源
+	if path, ok := findInLibrary(saveFolder, albumId, track, library.CodecALAC, library.CodecAAC); ok {
+		return path, true
+	}
+	tags := []string{"Alac", "Hi-Res Lossless", "Aac 256"}
+	codecs := []string{"ALAC", "AAC"}
+	qualities := []string{quality, ""}
+	for _, tag := range tags {
+		for _, codec := range codecs {
+			for _, q := range qualities {
+				_, candidate := session.resolveTrackPath(saveFolder, albumId, track, trackNum, q, tag, codec)
+				if path, exists, _ := utils.FindExistingFile(candidate); exists {
+					return path, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// prepareUpgrade 为升级下载登记临时文件，返回实际下载使用的路径
+// 新文件先下载到目标文件夹内的临时目录，标签写入成功后由 completeUpgrade 替换旧文件
+func prepareUpgrade(finalPath, oldPath string) (string, error) {
+	tempDir := filepath.Join(filepath.Dir(finalPath), upgradeDirName)
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return "", fmt.Errorf("创建升级临时目录失败: %w", err)
+	}
+	tempPath := filepath.Join(tempDir, filepath.Base(finalPath))
+
+	pendingUpgradesMu.Lock()
+	pendingUpgrades[tempPath] = upgradeTarget{finalPath: finalPath, oldPath: oldPath}
+	pendingUpgradesMu.Unlock()
+	return tempPath, nil
+}
+
+// completeUpgrade 在新文件处理完成后替换旧文件（非升级下载的路径直接忽略）
+// 同目录内的重命名是原子操作，失败时旧文件保持不变
+func completeUpgrade(tempPath string) error {
+	pendingUpgradesMu.Lock()
+	target, ok := pendingUpgrades[tempPath]
+	delete(pendingUpgrades, tempPath)
+	pendingUpgradesMu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	if err := os.Rename(tempPath, target.finalPath); err != nil {
+		return fmt.Errorf("替换旧文件失败: %w", err)
+	}
+	// 同名歌词文件一并替换
+	finalLyrics := lyricSidecarPaths(target.finalPath)
+	for i, tempLrc := range lyricSidecarPaths(tempPath) {
+		if _, err := os.Stat(tempLrc); err == nil {
+			_ = os.Rename(tempLrc, finalLyrics[i])
+		}
+	}
+	_ = os.Remove(filepath.Dir(tempPath))
+
+	if target.oldPath != target.finalPath {
+		_ = os.Remove(target.oldPath)
+		for _, oldLrc := range lyricSidecarPaths(target.oldPath) {
+			_ = os.Remove(oldLrc)
+		}
+
+		pendingUpgradesMu.Lock()
+		oldFolder, newFolder := filepath.Dir(target.oldPath), filepath.Dir(target.finalPath)
+		if oldFolder != newFolder {
+			upgradedFolders[oldFolder] = newFolder
+		}
+		pendingUpgradesMu.Unlock()
+	}
+	return nil
+}
+
+// finishFolderUpgrades 将升级后文件夹名称改变（{Tag}/{Quality} 变化）的旧文件夹合并到新文件夹
+// 未升级的曲目、封面和歌词会移入新文件夹，旧文件夹清空后删除
+func finishFolderUpgrades() {
+	pendingUpgradesMu.Lock()
+	folders := upgradedFolders
+	upgradedFolders = make(map[string]string)
+	pendingUpgradesMu.Unlock()
+
+	for oldFolder, newFolder := range folders {
+		entries, err := os.ReadDir(oldFolder)
+		if err != nil {
+			continue
+		}
+		moved := 0
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			src := filepath.Join(oldFolder, entry.Name())
+			if err := utils.SafeMoveFile(src, filepath.Join(newFolder, entry.Name())); err != nil {
+				if strings.Contains(err.Error(), "目标文件已存在") {
+					// 新文件夹中已有同名文件（如重新下载的封面），丢弃旧文件
+					_ = os.Remove(src)
+				}
+				continue
+			}
+			moved++
+		}
+		if err := os.Remove(oldFolder); err == nil {
+			logger.Info("📁 专辑文件夹已升级: %s -> %s", filepath.Base(oldFolder), filepath.Base(newFolder))
+		} else if moved > 0 {
+			logger.Warn("⚠️ 旧文件夹未能完全合并: %s", oldFolder)
+		}
+	}
+}
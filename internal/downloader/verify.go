@@ -0,0 +1,93 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"main/internal/core"
+	"main/internal/metadata"
+	"main/internal/progress"
+	"main/internal/verify"
+	"main/utils/structs"
+)
+
+// verifyTrack 校验下载的文件，返回是否经过重新编码
+// 先用 mp4ff 做结构校验（快速），只有结构正常但存在可疑之处（如时长与目录不符）时才用 ffmpeg 解码检查
+// 结构损坏时返回错误，由调用方删除文件并重新下载；解码失败时按 ffmpeg-fix 决定是否重新编码
+func verifyTrack(trackPath string, track structs.TrackData, statusIndex int, notifier *progress.ProgressNotifier) (bool, error) {
+	if notifier != nil {
+		notifier.NotifyStatus(statusIndex, "正在校验...", "check")
+	}
+	name := trackDisplayName(track)
+	expected := time.Duration(track.Attributes.DurationInMillis) * time.Millisecond
+
+	report := verify.File(trackPath, expected)
+	if report.Corrupt() {
+		addReportEntry(reportVerify, name, "✗ "+report.Summary())
+		return false, fmt.Errorf("文件损坏: %s", report.Summary())
+	}
+	if !report.Suspicious() {
+		removeReportEntry(reportVerify, name)
+		return false, nil
+	}
+
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		addReportEntry(reportVerify, name, "⚠ "+report.Summary()+"（未找到 ffmpeg，跳过解码检查）")
+		return false, nil
+	}
+	if notifier != nil {
+		notifier.NotifyStatus(statusIndex, "正在解码检查...", "check")
+	}
+	if err := verify.Decode(trackPath, strings.Fields(core.Config.FfmpegCheckArgs)); err == nil {
+		addReportEntry(reportVerify, name, "⚠ "+report.Summary()+"（解码正常）")
+		return false, nil
+	}
+
+	// 重新编码使用 ALAC，只适用于无损文件
+	info, _ := metadata.ReadStreamInfo(trackPath)
+	if !core.Config.FfmpegFix || !info.Lossless() {
+		addReportEntry(reportVerify, name, "✗ "+report.Summary()+"（解码失败）")
+		return false, fmt.Errorf("解码检查失败: %s", report.Summary())
+	}
+	if err := reencodeTrack(trackPath, statusIndex, notifier); err != nil {
+		addReportEntry(reportVerify, name, "✗ 重新编码失败")
+		return true, fmt.Errorf("修复失败: %w", err)
+	}
+	addReportEntry(reportVerify, name, "🔧 已重新编码（"+report.Summary()+"）")
+	return true, nil
+}
+
+// reencodeTrack 使用 ffmpeg 重新编码损坏的文件并替换原文件
+func reencodeTrack(trackPath string, statusIndex int, notifier *progress.ProgressNotifier) error {
+	if notifier != nil {
+		notifier.NotifyStatus(statusIndex, "文件损坏, 正在重新编码...", "reencode")
+	}
+
+	tempTrackPath := trackPath + ".fixed.m4a"
+	defer func() {
+		_ = os.Remove(tempTrackPath)
+	}()
+
+	encodeArgs := strings.Fields(core.Config.FfmpegEncodeArgs)
+	cmdEncodeArgs := append([]string{"-i", trackPath}, encodeArgs...)
+	cmdEncodeArgs = append(cmdEncodeArgs, tempTrackPath)
+
+	encodeCmd := exec.Command("ffmpeg", cmdEncodeArgs...)
+	var encodeStderr bytes.Buffer
+	encodeCmd.Stderr = &encodeStderr
+	if err := encodeCmd.Run(); err != nil {
+		return fmt.Errorf("重新编码失败: %v, FFMPEG输出: %s", err, encodeStderr.String())
+	}
+
+	if err := os.Remove(trackPath); err != nil {
+		return fmt.Errorf("删除损坏的原文件失败: %w", err)
+	}
+	if err := os.Rename(tempTrackPath, trackPath); err != nil {
+		return fmt.Errorf("替换为修复文件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package library
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"main/internal/verify"
+
+	"github.com/zhaarey/go-mp4tag"
+)
+
+// 审计发现的问题类型
+const (
+	ProblemCorrupt       = "corrupt"        // 文件被截断或 MP4 结构解析失败
+	ProblemMissingTags   = "missing-tags"   // 没有 ilst（标签缺失或损坏）
+	ProblemNoCover       = "no-cover"       // 配置要求嵌入封面但文件中没有
+	ProblemNoLyrics      = "no-lyrics"      // 配置要求嵌入歌词但文件中没有
+	ProblemDuplicateISRC = "duplicate-isrc" // 同一 ISRC 存在多个文件
+	ProblemCoverOnly     = "cover-only"     // 只剩封面图片的文件夹
+)
+
+// ProblemNames 问题类型的中文说明
+var ProblemNames = map[string]string{
+	ProblemCorrupt:       "文件损坏",
+	ProblemMissingTags:   "标签缺失",
+	ProblemNoCover:       "缺少封面",
+	ProblemNoLyrics:      "缺少歌词",
+	ProblemDuplicateISRC: "ISRC 重复",
+	ProblemCoverOnly:     "仅含封面的文件夹",
+}
+
+// Finding 审计发现的一个问题
+type Finding struct {
+	Path    string
+	Problem string
+	Detail  string
+	TrackID string // 可用于修复的 Apple Music 曲目ID（来自标签或曲库索引）
+	AlbumID string
+}
+
+// AuditOptions 审计选项
+type AuditOptions struct {
+	RequireCover  bool     // 检查内嵌封面（embed-cover）
+	RequireLyrics bool     // 检查内嵌歌词（embed-lrc）
+	SkipDirs      []string // 不检查的目录（如 cache-folder），以 "." 开头的临时目录（如升级下载的 .upgrade）总是跳过
+	// Progress 每检查完一个文件调用一次，可为 nil
+	Progress func(checked int, path string)
+}
+
+// Audit 遍历 root 下的所有 .m4a 文件和文件夹，返回发现的问题（按路径排序）
+// idx 可为 nil；提供时，标签无法读取的文件会从索引中查找曲目ID和专辑ID，以便重新下载
+func Audit(root string, idx *Index, opts AuditOptions) ([]Finding, error) {
+	root = absPath(root)
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	byISRC := make(map[string][]string)
+	ids := make(map[string][2]string) // 文件 -> [曲目ID, 专辑ID]
+	checked := 0
+
+	walkErr := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path != root && (strings.HasPrefix(info.Name(), ".") || opts.skipped(path)) {
+				return filepath.SkipDir
+			}
+			if path != root && IsCoverOnlyFolder(path) {
+				findings = append(findings, Finding{Path: path, Problem: ProblemCoverOnly})
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".m4a") {
+			return nil
+		}
+
+		fileFindings, isrc, trackID, albumID := auditFile(path, opts)
+		if (trackID == "" || albumID == "") && idx != nil {
+			idx.mu.RLock()
+			if entry := idx.Entries[path]; entry != nil {
+				trackID, albumID = entry.TrackID, entry.AlbumID
+			}
+			idx.mu.RUnlock()
+		}
+		for i := range fileFindings {
+			fileFindings[i].TrackID, fileFindings[i].AlbumID = trackID, albumID
+		}
+		findings = append(findings, fileFindings...)
+		if isrc != "" {
+			byISRC[isrc] = append(byISRC[isrc], path)
+			ids[path] = [2]string{trackID, albumID}
+		}
+
+		checked++
+		if opts.Progress != nil {
+			opts.Progress(checked, path)
+		}
+		return nil
+	})
+
+	for isrc, paths := range byISRC {
+		if len(paths) < 2 {
+			continue
+		}
+		for _, path := range paths {
+			var others []string
+			for _, other := range paths {
+				if other != path {
+					others = append(others, other)
+				}
+			}
+			findings = append(findings, Finding{
+				Path:    path,
+				Problem: ProblemDuplicateISRC,
+				Detail:  fmt.Sprintf("%s，另见 %s", isrc, strings.Join(others, ", ")),
+				TrackID: ids[path][0],
+				AlbumID: ids[path][1],
+			})
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Path < findings[j].Path
+	})
+	return findings, walkErr
+}
+
+// auditFile 检查单个文件的结构和标签，返回发现的问题以及标签中的 ISRC、曲目ID、专辑ID
+func auditFile(path string, opts AuditOptions) (findings []Finding, isrc, trackID, albumID string) {
+	report := verify.File(path, 0)
+	if report.Corrupt() {
+		return []Finding{{Path: path, Problem: ProblemCorrupt, Detail: report.Summary()}}, "", "", ""
+	}
+
+	mp4, err := mp4tag.Open(path)
+	if err != nil {
+		return []Finding{{Path: path, Problem: ProblemMissingTags, Detail: err.Error()}}, "", "", ""
+	}
+	defer mp4.Close()
+	tags, err := mp4.Read()
+	if err != nil {
+		return []Finding{{Path: path, Problem: ProblemMissingTags, Detail: err.Error()}}, "", "", ""
+	}
+	if tags.Title == "" && len(tags.Custom) == 0 {
+		return []Finding{{Path: path, Problem: ProblemMissingTags, Detail: "ilst 为空"}}, "", "", ""
+	}
+
+	if tags.Custom != nil {
+		isrc = tags.Custom["ISRC"]
+		trackID = tags.Custom["APPLE_TRACK_ID"]
+	}
+	if tags.ItunesAlbumID > 0 {
+		albumID = strconv.Itoa(int(tags.ItunesAlbumID))
+	}
+
+	if opts.RequireCover && len(tags.Pictures) == 0 {
+		findings = append(findings, Finding{Path: path, Problem: ProblemNoCover})
+	}
+	if opts.RequireLyrics && strings.TrimSpace(tags.Lyrics) == "" {
+		findings = append(findings, Finding{Path: path, Problem: ProblemNoLyrics})
+	}
+	return findings, isrc, trackID, albumID
+}
+
+// skipped 判断目录是否在 SkipDirs 中
+func (o AuditOptions) skipped(dir string) bool {
+	for _, skip := range o.SkipDirs {
+		if skip != "" && absPath(skip) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCoverOnlyFolder 判断文件夹是否只包含封面图片（cover.jpg/folder.jpg），空文件夹不算
+// 这类文件夹通常是音质标签不一致时产生的冗余专辑文件夹
+func IsCoverOnlyFolder(dir string) bool {
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) == 0 {
+		return false
+	}
+	for _, f := range files {
+		if f.Name() != "cover.jpg" && f.Name() != "folder.jpg" {
+			return false
+		}
+	}
+	return true
+}
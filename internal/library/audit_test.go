@@ -0,0 +1,49 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditCorruptAndCoverOnly(t *testing.T) {
+	root := t.TempDir()
+	album := filepath.Join(root, "Artist", "Album")
+	leftover := filepath.Join(root, "Artist", "Album Hi-Res")
+	empty := filepath.Join(root, "Artist", "Empty")
+	staging := filepath.Join(album, ".upgrade")
+	for _, dir := range []string{album, leftover, empty, staging} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(album, "01. Track.m4a"), []byte("truncated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{leftover, staging} {
+		if err := os.WriteFile(filepath.Join(dir, "cover.jpg"), []byte{0xff, 0xd8}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	findings, err := Audit(root, nil, AuditOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, f := range findings {
+		got[f.Path] = f.Problem
+	}
+	if got[filepath.Join(album, "01. Track.m4a")] != ProblemCorrupt {
+		t.Errorf("expected corrupt track, got %v", findings)
+	}
+	if got[leftover] != ProblemCoverOnly {
+		t.Errorf("expected cover-only folder, got %v", findings)
+	}
+	// 有曲目的专辑文件夹、用户创建的空文件夹和升级下载的临时目录都不能被删除
+	for _, dir := range []string{album, empty, staging} {
+		if _, ok := got[dir]; ok {
+			t.Errorf("%s reported: %v", dir, findings)
+		}
+	}
+}
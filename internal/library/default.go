@@ -0,0 +1,58 @@
+package library
+
+import (
+	"sync"
+
+	"main/internal/core"
+	"main/internal/logger"
+)
+
+var (
+	defaultIndex *Index
+	defaultOnce  sync.Once
+
+	// scannedRoots 本次运行中已扫描过的根目录（--rescan-library 时每个根目录只重新扫描一次）
+	scannedRoots   = make(map[string]bool)
+	scannedRootsMu sync.Mutex
+)
+
+// Default 返回按配置加载的全局曲库索引，未启用 library-index 时返回 nil
+func Default() *Index {
+	if !core.Config.LibraryIndex {
+		return nil
+	}
+	defaultOnce.Do(func() {
+		idx, err := Load(core.Config.LibraryIndexFile)
+		if err != nil {
+			logger.Warn("⚠️ %v，将重新建立曲库索引", err)
+			idx = &Index{path: core.Config.LibraryIndexFile, Version: indexVersion, Entries: make(map[string]*Entry)}
+			idx.rebuildLookups()
+		}
+		defaultIndex = idx
+	})
+	return defaultIndex
+}
+
+// EnsureRoot 确保根目录已建立索引：首次遇到的根目录或指定 --rescan-library 时扫描，其余情况直接使用已保存的索引
+func (idx *Index) EnsureRoot(root string) error {
+	root = absPath(root)
+
+	scannedRootsMu.Lock()
+	defer scannedRootsMu.Unlock()
+	if scannedRoots[root] {
+		return nil
+	}
+	if idx.HasRoot(root) && !core.RescanLibrary {
+		scannedRoots[root] = true
+		return nil
+	}
+
+	logger.Info("📚 正在建立曲库索引: %s", root)
+	updated, err := idx.Scan(root)
+	if err != nil {
+		return err
+	}
+	scannedRoots[root] = true
+	logger.Info("📚 曲库索引已更新 %d 个文件", updated)
+	return idx.Save()
+}
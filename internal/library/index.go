@@ -0,0 +1,386 @@
+package library
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/zhaarey/go-mp4tag"
+
+	"main/internal/metadata"
+)
+
+// indexVersion 索引格式版本，低于该版本的索引文件缺少新字段，加载时丢弃并重新扫描
+const indexVersion = 2
+
+// 索引条目的编码类别，与下载格式（ALAC/AAC/ATMOS）对应
+const (
+	CodecALAC  = "ALAC"
+	CodecAAC   = "AAC"
+	CodecAtmos = "ATMOS"
+)
+
+// Entry 曲库中单个音频文件的索引信息（来自文件内嵌标签）
+type Entry struct {
+	Path    string `json:"path"`
+	TrackID string `json:"track_id,omitempty"` // Apple Music 曲目ID（APPLE_TRACK_ID 标签）
+	ISRC    string `json:"isrc,omitempty"`
+	AlbumID string `json:"album_id,omitempty"` // Apple Music 专辑ID（ItunesAlbumID 标签）
+	UPC     string `json:"upc,omitempty"`
+	Quality string `json:"quality,omitempty"` // QUALITY 标签
+	Codec   string `json:"codec,omitempty"`   // 实际编码类别（CodecALAC 等）
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"`
+}
+
+// Index 曲库索引：扫描文件标签建立，按曲目ID、ISRC、专辑ID查找已下载的曲目
+// 索引持久化为 JSON 文件，已扫描过的根目录在后续运行中不会重新扫描
+type Index struct {
+	mu    sync.RWMutex
+	path  string
+	dirty bool
+
+	Version int               `json:"version"`
+	Roots   []string          `json:"roots"`
+	Entries map[string]*Entry `json:"entries"` // 键为文件绝对路径
+
+	byTrackID map[string][]string
+	byISRC    map[string][]string
+	byAlbumID map[string][]string
+}
+
+// Load 从 path 加载索引文件，文件不存在时返回空索引
+func Load(path string) (*Index, error) {
+	idx := &Index{path: path, Entries: make(map[string]*Entry)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			idx.Version = indexVersion
+			idx.rebuildLookups()
+			return idx, nil
+		}
+		return nil, fmt.Errorf("读取曲库索引失败: %w", err)
+	}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("解析曲库索引失败: %w", err)
+	}
+	if idx.Entries == nil || idx.Version < indexVersion {
+		// 旧版本索引没有记录编码，清空后各根目录在下次使用时重新扫描
+		idx.Version, idx.Roots, idx.Entries = indexVersion, nil, make(map[string]*Entry)
+		idx.dirty = true
+	}
+	idx.rebuildLookups()
+	return idx, nil
+}
+
+// Save 将索引写回磁盘（先写临时文件再重命名，避免中断时损坏索引）
+func (idx *Index) Save() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if !idx.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化曲库索引失败: %w", err)
+	}
+	if dir := filepath.Dir(idx.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("创建曲库索引目录失败: %w", err)
+		}
+	}
+	tmpPath := idx.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("写入曲库索引失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, idx.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("保存曲库索引失败: %w", err)
+	}
+	idx.dirty = false
+	return nil
+}
+
+// HasRoot 判断根目录是否已扫描过
+func (idx *Index) HasRoot(root string) bool {
+	root = absPath(root)
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	for _, r := range idx.Roots {
+		if r == root {
+			return true
+		}
+	}
+	return false
+}
+
+// Scan 扫描根目录下的所有 .m4a 文件并更新索引，并将其记录为已扫描的根目录
+// 返回值: 新增或更新的条目数
+func (idx *Index) Scan(root string) (int, error) {
+	root = absPath(root)
+	updated, err := idx.Refresh(root)
+	if err != nil {
+		return updated, err
+	}
+	idx.addRoot(root)
+	return updated, nil
+}
+
+// Refresh 重新扫描目录（如刚下载完成的专辑文件夹）下的 .m4a 文件
+// 大小和修改时间未变化的文件不会重新读取标签；已删除的文件会从索引中移除
+// 返回值: 新增或更新的条目数
+func (idx *Index) Refresh(dir string) (int, error) {
+	dir = absPath(dir)
+	if _, err := os.Stat(dir); err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	seen := make(map[string]bool)
+	updated := 0
+	walkErr := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.EqualFold(filepath.Ext(path), ".m4a") {
+			return nil
+		}
+		seen[path] = true
+
+		idx.mu.RLock()
+		existing := idx.Entries[path]
+		idx.mu.RUnlock()
+		if existing != nil && existing.Size == info.Size() && existing.ModTime == info.ModTime().UnixNano() {
+			return nil
+		}
+
+		entry, readErr := readEntry(path, info)
+		if readErr != nil {
+			// 无法读取标签的文件（如下载中断的残留文件）不加入索引
+			return nil
+		}
+		idx.put(entry)
+		updated++
+		return nil
+	})
+
+	// 移除该目录下已不存在的文件
+	idx.mu.Lock()
+	for path := range idx.Entries {
+		if isUnder(path, dir) && !seen[path] {
+			delete(idx.Entries, path)
+			idx.dirty = true
+		}
+	}
+	idx.mu.Unlock()
+
+	idx.rebuildLookups()
+	return updated, walkErr
+}
+
+// Update 读取单个文件的标签并更新索引（文件不存在时移除对应条目）
+func (idx *Index) Update(path string) error {
+	path = absPath(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			idx.remove(path)
+			return nil
+		}
+		return err
+	}
+	entry, err := readEntry(path, info)
+	if err != nil {
+		return err
+	}
+	idx.put(entry)
+	idx.rebuildLookups()
+	return nil
+}
+
+// Lookup 在根目录下查找曲目，优先按曲目ID匹配，其次按 ISRC 匹配
+// 只接受与下载上下文一致的文件：albumID 为专辑ID时要求文件属于该专辑，为空（播放列表）时要求文件
+// 没有专辑ID（播放列表下载的文件），避免专辑中的曲目因播放列表文件夹中的同一曲目被跳过，反之亦然。
+// codecs 不为空时只接受其中编码类别的文件，同一根目录下的其他格式（如 AAC 与 ALAC）不会互相顶替
+// 返回值: (文件路径, 是否找到)
+func (idx *Index) Lookup(root, trackID, isrc, albumID string, codecs ...string) (string, bool) {
+	root = absPath(root)
+	match := func(lookup map[string][]string, key string) (string, bool) {
+		for _, path := range idx.candidates(lookup, key) {
+			if !isUnder(path, root) {
+				continue
+			}
+			idx.mu.RLock()
+			entry, ok := idx.Entries[path]
+			idx.mu.RUnlock()
+			if !ok || entry.AlbumID != albumID || (len(codecs) > 0 && !containsCodec(codecs, entry.Codec)) {
+				continue
+			}
+			if idx.stillExists(path) {
+				return path, true
+			}
+		}
+		return "", false
+	}
+	if trackID != "" {
+		if path, ok := match(idx.byTrackID, trackID); ok {
+			return path, true
+		}
+	}
+	if isrc != "" {
+		return match(idx.byISRC, isrc)
+	}
+	return "", false
+}
+
+func containsCodec(codecs []string, codec string) bool {
+	for _, c := range codecs {
+		if c == codec {
+			return true
+		}
+	}
+	return false
+}
+
+// AlbumTracks 返回根目录下属于指定专辑的所有索引条目
+func (idx *Index) AlbumTracks(root, albumID string) []Entry {
+	root = absPath(root)
+	var entries []Entry
+	for _, path := range idx.candidates(idx.byAlbumID, albumID) {
+		if !isUnder(path, root) {
+			continue
+		}
+		idx.mu.RLock()
+		if entry, ok := idx.Entries[path]; ok {
+			entries = append(entries, *entry)
+		}
+		idx.mu.RUnlock()
+	}
+	return entries
+}
+
+func (idx *Index) candidates(lookup map[string][]string, key string) []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return append([]string(nil), lookup[key]...)
+}
+
+// stillExists 确认索引中的文件仍然存在，不存在时移除条目
+func (idx *Index) stillExists(path string) bool {
+	if _, err := os.Stat(path); err == nil {
+		return true
+	}
+	idx.remove(path)
+	return false
+}
+
+func (idx *Index) put(entry *Entry) {
+	idx.mu.Lock()
+	idx.Entries[entry.Path] = entry
+	idx.dirty = true
+	idx.mu.Unlock()
+}
+
+func (idx *Index) remove(path string) {
+	idx.mu.Lock()
+	if _, ok := idx.Entries[path]; ok {
+		delete(idx.Entries, path)
+		idx.dirty = true
+	}
+	idx.mu.Unlock()
+	idx.rebuildLookups()
+}
+
+func (idx *Index) addRoot(root string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, r := range idx.Roots {
+		if r == root {
+			return
+		}
+	}
+	idx.Roots = append(idx.Roots, root)
+	idx.dirty = true
+}
+
+// rebuildLookups 根据 Entries 重建内存中的查找表
+func (idx *Index) rebuildLookups() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.byTrackID = make(map[string][]string)
+	idx.byISRC = make(map[string][]string)
+	idx.byAlbumID = make(map[string][]string)
+	for path, entry := range idx.Entries {
+		if entry.TrackID != "" {
+			idx.byTrackID[entry.TrackID] = append(idx.byTrackID[entry.TrackID], path)
+		}
+		if entry.ISRC != "" {
+			idx.byISRC[entry.ISRC] = append(idx.byISRC[entry.ISRC], path)
+		}
+		if entry.AlbumID != "" {
+			idx.byAlbumID[entry.AlbumID] = append(idx.byAlbumID[entry.AlbumID], path)
+		}
+	}
+}
+
+// readEntry 读取文件标签生成索引条目
+func readEntry(path string, info os.FileInfo) (*Entry, error) {
+	mp4, err := mp4tag.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer mp4.Close()
+
+	tags, err := mp4.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{
+		Path:    path,
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+	}
+	if tags.Custom != nil {
+		entry.TrackID = tags.Custom["APPLE_TRACK_ID"]
+		entry.ISRC = tags.Custom["ISRC"]
+		entry.UPC = tags.Custom["UPC"]
+		entry.Quality = tags.Custom["QUALITY"]
+	}
+	if tags.ItunesAlbumID > 0 {
+		entry.AlbumID = strconv.Itoa(int(tags.ItunesAlbumID))
+	}
+	if info, err := metadata.ReadStreamInfo(path); err == nil {
+		entry.Codec = codecClass(info.Codec)
+	}
+	return entry, nil
+}
+
+// codecClass 将采样描述类型（alac、mp4a、ec-3 等）归类为下载格式
+func codecClass(sampleEntry string) string {
+	switch sampleEntry {
+	case "alac":
+		return CodecALAC
+	case "mp4a":
+		return CodecAAC
+	case "ec-3", "ac-3":
+		return CodecAtmos
+	}
+	return strings.ToUpper(sampleEntry)
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// isUnder 判断 path 是否位于 root 目录下
+func isUnder(path, root string) bool {
+	return strings.HasPrefix(path, root+string(filepath.Separator))
+}
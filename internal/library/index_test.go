@@ -0,0 +1,112 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestIndex(t *testing.T, root string, entries ...Entry) *Index {
+	t.Helper()
+	idx, err := Load(filepath.Join(t.TempDir(), "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range entries {
+		entry := entries[i]
+		entry.Path = filepath.Join(root, entry.Path)
+		if err := os.MkdirAll(filepath.Dir(entry.Path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(entry.Path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		idx.put(&entry)
+	}
+	idx.rebuildLookups()
+	return idx
+}
+
+func TestLookupMatchesCodec(t *testing.T) {
+	root := t.TempDir()
+	idx := newTestIndex(t, root,
+		Entry{Path: "Artist/Album/01. Song.m4a", TrackID: "1", ISRC: "ISRC1", AlbumID: "100", Codec: CodecALAC},
+	)
+
+	if path, ok := idx.Lookup(root, "1", "ISRC1", "100", CodecALAC); !ok || filepath.Base(path) != "01. Song.m4a" {
+		t.Errorf("ALAC lookup = %q, %v", path, ok)
+	}
+	// aac-save-folder 未设置时 AAC 与 ALAC 保存在同一根目录，ALAC 文件不能算作 AAC 已下载
+	if path, ok := idx.Lookup(root, "1", "ISRC1", "100", CodecAAC); ok {
+		t.Errorf("AAC lookup matched %q", path)
+	}
+	if _, ok := idx.Lookup(root, "1", "", "100"); !ok {
+		t.Error("lookup without codec filter failed")
+	}
+}
+
+func TestLookupMatchesAlbumContext(t *testing.T) {
+	root := t.TempDir()
+	idx := newTestIndex(t, root,
+		Entry{Path: "Playlists/Mix/01. Song.m4a", TrackID: "1", ISRC: "ISRC1", Codec: CodecALAC},
+		Entry{Path: "Artist/Best Of/05. Song.m4a", TrackID: "2", ISRC: "ISRC1", AlbumID: "200", Codec: CodecALAC},
+	)
+
+	// 只在播放列表文件夹中的曲目不算专辑已下载，按曲目ID和 ISRC 都一样
+	if path, ok := idx.Lookup(root, "1", "ISRC1", "100", CodecALAC); ok {
+		t.Errorf("album lookup matched %q", path)
+	}
+	if path, ok := idx.Lookup(root, "1", "", "", CodecALAC); !ok || filepath.Base(path) != "01. Song.m4a" {
+		t.Errorf("playlist lookup = %q, %v", path, ok)
+	}
+	if path, ok := idx.Lookup(root, "3", "ISRC1", "200", CodecALAC); !ok || filepath.Base(path) != "05. Song.m4a" {
+		t.Errorf("ISRC lookup = %q, %v", path, ok)
+	}
+	if _, ok := idx.Lookup(filepath.Join(root, "Other"), "1", "", "", CodecALAC); ok {
+		t.Error("lookup matched a file outside the root")
+	}
+}
+
+func TestLookupDropsDeletedFiles(t *testing.T) {
+	root := t.TempDir()
+	idx := newTestIndex(t, root, Entry{Path: "Artist/Album/01. Song.m4a", TrackID: "1", AlbumID: "100", Codec: CodecALAC})
+
+	if err := os.Remove(filepath.Join(root, "Artist/Album/01. Song.m4a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := idx.Lookup(root, "1", "", "100"); ok {
+		t.Error("deleted file still found")
+	}
+	if len(idx.Entries) != 0 {
+		t.Errorf("entries = %v", idx.Entries)
+	}
+}
+
+func TestLoadDiscardsOldIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	old := `{"roots": ["/music"], "entries": {"/music/a.m4a": {"path": "/music/a.m4a", "track_id": "1"}}}`
+	if err := os.WriteFile(path, []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Entries) != 0 || idx.HasRoot("/music") {
+		t.Errorf("old index kept: roots %v, entries %v", idx.Roots, idx.Entries)
+	}
+	if err := idx.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if idx, err = Load(path); err != nil || idx.Version != indexVersion {
+		t.Errorf("reloaded version = %d, %v", idx.Version, err)
+	}
+}
+
+func TestCodecClass(t *testing.T) {
+	for sampleEntry, want := range map[string]string{"alac": CodecALAC, "mp4a": CodecAAC, "ec-3": CodecAtmos, "ac-3": CodecAtmos} {
+		if got := codecClass(sampleEntry); got != want {
+			t.Errorf("codecClass(%q) = %q, want %q", sampleEntry, got, want)
+		}
+	}
+}
@@ -153,6 +153,13 @@ func SetOutput(w io.Writer) {
 	global.SetOutput(w)
 }
 
+// Output 返回全局输出目标
+func Output() io.Writer {
+	global.mu.Lock()
+	defer global.mu.Unlock()
+	return global.output
+}
+
 // SetShowTime 设置全局是否显示时间戳
 func SetShowTime(show bool) {
 	global.SetShowTime(show)
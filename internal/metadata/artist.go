@@ -0,0 +1,55 @@
+package metadata
+
+import (
+	"strings"
+
+	"main/internal/core"
+	"main/utils/structs"
+)
+
+// ResolveAlbumArtist 根据歌手文件夹策略确定专辑的歌手名称和歌手ID
+// 歌手文件夹和 AlbumArtist 标签都使用此函数，保证两者一致
+// 返回值: (歌手名称, 歌手ID)，播放列表、电台和合辑没有对应的歌手ID
+func ResolveAlbumArtist(meta *structs.AutoGenerated) (string, string) {
+	data := meta.Data[0]
+	if strings.Contains(data.ID, "pl.") {
+		return core.Config.PlaylistArtistName, ""
+	}
+	if strings.HasPrefix(data.ID, "ra.") {
+		return core.Config.StationArtistName, ""
+	}
+	if data.Attributes.IsCompilation && core.Config.CompilationArtistName != "" {
+		return core.Config.CompilationArtistName, ""
+	}
+
+	name := data.Attributes.ArtistName
+	var artistId string
+	if len(data.Relationships.Artists.Data) > 0 {
+		primary := data.Relationships.Artists.Data[0]
+		artistId = primary.ID
+		// 主歌手名称来自歌手实体，同一歌手ID的名称在所有专辑中保持一致
+		if core.Config.ArtistFolderStrategy == "primary-artist" && primary.Attributes.Name != "" {
+			name = primary.Attributes.Name
+		}
+	}
+	return applyArtistAlias(name, artistId), artistId
+}
+
+// applyArtistAlias 按 artist-aliases 配置将歌手名称统一为规范名称
+// 优先按歌手ID匹配，其次按名称匹配（不区分大小写）
+func applyArtistAlias(name, artistId string) string {
+	if len(core.Config.ArtistAliases) == 0 {
+		return name
+	}
+	if artistId != "" {
+		if alias, ok := core.Config.ArtistAliases[artistId]; ok && alias != "" {
+			return alias
+		}
+	}
+	for key, alias := range core.Config.ArtistAliases {
+		if alias != "" && strings.EqualFold(strings.TrimSpace(key), strings.TrimSpace(name)) {
+			return alias
+		}
+	}
+	return name
+}
@@ -0,0 +1,119 @@
+package metadata
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+
+	"main/internal/core"
+	"main/utils/structs"
+
+	"github.com/zhaarey/go-mp4tag"
+)
+
+// BuildMVTags 根据目录元数据生成 MV 的标签，字段映射与音频曲目相同（tag-profile）
+// meta 非空时为专辑或播放列表中的 MV，trackNum 为其在 meta 中的序号（从 1 开始），用于补充专辑相关字段
+func BuildMVTags(mv *structs.AutoGeneratedMusicVideo, meta *structs.AutoGenerated, trackNum int) *mp4tag.MP4Tags {
+	data := mv.Data[0]
+	profile := currentProfile()
+
+	values := map[string]string{
+		"title":        data.Attributes.Name,
+		"title-sort":   data.Attributes.Name,
+		"artist":       data.Attributes.ArtistName,
+		"artist-sort":  data.Attributes.ArtistName,
+		"genre":        profile.joinGenres(data.Attributes.GenreNames),
+		"date":         data.Attributes.ReleaseDate,
+		"release-date": data.Attributes.ReleaseDate,
+		"isrc":         data.Attributes.Isrc,
+		"performer":    data.Attributes.ArtistName,
+		"album":        data.Attributes.AlbumName,
+	}
+	t := &mp4tag.MP4Tags{
+		Custom: map[string]string{
+			"APPLE_TRACK_ID": data.ID,
+		},
+	}
+	discNum, discTotal := data.Attributes.DiscNumber, 0
+	trackNumber, trackTotal := data.Attributes.TrackNumber, 0
+
+	if meta != nil && trackNum > 0 && trackNum <= len(meta.Data[0].Relationships.Tracks.Data) {
+		tracks := meta.Data[0].Relationships.Tracks.Data
+		track := tracks[trackNum-1]
+		values["copyright"] = meta.Data[0].Attributes.Copyright
+		values["label"] = meta.Data[0].Attributes.RecordLabel
+		values["upc"] = meta.Data[0].Attributes.Upc
+		values["performer"] = track.Attributes.ArtistName
+		if strings.Contains(meta.Data[0].ID, "pl.") && !core.Config.UseSongInfoForPlaylist {
+			values["album"] = meta.Data[0].Attributes.Name
+			// 与 BuildMP4Tags 一致，播放列表使用 playlist-artist-name
+			values["album-artist"], _ = ResolveAlbumArtist(meta)
+			discNum, discTotal = 1, 1
+			trackNumber, trackTotal = trackNum, len(tracks)
+		} else {
+			values["album"] = track.Attributes.AlbumName
+			values["album-artist"], _ = ResolveAlbumArtist(meta)
+			discNum, discTotal = track.Attributes.DiscNumber, tracks[len(tracks)-1].Attributes.DiscNumber
+			trackNumber, trackTotal = track.Attributes.TrackNumber, meta.Data[0].Attributes.TrackCount
+		}
+	}
+	values["album-sort"] = values["album"]
+	values["album-artist-sort"] = values["album-artist"]
+	profile.apply(t, values)
+
+	if discNum > 0 && discNum <= math.MaxInt16 {
+		t.DiscNumber = int16(discNum)
+	}
+	if discTotal > 0 && discTotal <= math.MaxInt16 {
+		t.DiscTotal = int16(discTotal)
+	}
+	if trackNumber > 0 && trackNumber <= math.MaxInt16 {
+		t.TrackNumber = int16(trackNumber)
+	}
+	if trackTotal > 0 && trackTotal <= math.MaxInt16 {
+		t.TrackTotal = int16(trackTotal)
+	}
+
+	switch data.Attributes.ContentRating {
+	case "explicit":
+		t.ItunesAdvisory = mp4tag.ItunesAdvisoryExplicit
+	case "clean":
+		t.ItunesAdvisory = mp4tag.ItunesAdvisoryClean
+	default:
+		t.ItunesAdvisory = mp4tag.ItunesAdvisoryNone
+	}
+	return t
+}
+
+// EmbedArtwork 把封面图片加入标签（仅支持 JPEG/PNG，其他格式忽略）
+func EmbedArtwork(t *mp4tag.MP4Tags, coverPath string) error {
+	data, err := os.ReadFile(coverPath)
+	if err != nil {
+		return err
+	}
+	var format mp4tag.ImageType
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		format = mp4tag.ImageTypeJPEG
+	case "image/png":
+		format = mp4tag.ImageTypePNG
+	default:
+		return fmt.Errorf("不支持的封面格式: %s", coverPath)
+	}
+	t.Pictures = append(t.Pictures, &mp4tag.MP4Picture{Format: format, Data: data})
+	return nil
+}
+
+// WriteMVTags 写入 MV 标签，文件缺少 ilst box 时先用 FFmpeg 重新封装再重试
+func WriteMVTags(mvPath string, t *mp4tag.MP4Tags) error {
+	err := WriteTags(mvPath, t)
+	if err == nil || !strings.Contains(err.Error(), "ilst") {
+		return err
+	}
+	if fixErr := fixIlstBoxMissing(mvPath); fixErr != nil {
+		return fmt.Errorf("ilst box 修复失败: %w (原错误: %v)", fixErr, err)
+	}
+	return WriteTags(mvPath, t)
+}
@@ -0,0 +1,47 @@
+package metadata
+
+import (
+	"encoding/json"
+	"testing"
+
+	"main/internal/core"
+	"main/utils/structs"
+)
+
+const testMusicVideo = `{"data":[{"id":"1600000000","attributes":{"name":"Song: Live","artistName":"Artist","albumName":"Single",
+"genreNames":["J-Pop"],"isrc":"JPXX02000099","trackNumber":3,"discNumber":1,"contentRating":"explicit","releaseDate":"2021-05-01"}}]}`
+
+func TestBuildMVTags(t *testing.T) {
+	mv := new(structs.AutoGeneratedMusicVideo)
+	if err := json.Unmarshal([]byte(testMusicVideo), mv); err != nil {
+		t.Fatal(err)
+	}
+	useProfile(t, "default", nil)
+
+	tags := BuildMVTags(mv, nil, 0)
+	if tags.Title != "Song: Live" || tags.Artist != "Artist" || tags.Album != "Single" || tags.CustomGenre != "J-Pop" {
+		t.Errorf("unexpected tags: %+v", tags)
+	}
+	if tags.TrackNumber != 3 || tags.Custom["ISRC"] != "JPXX02000099" || tags.Custom["APPLE_TRACK_ID"] != "1600000000" {
+		t.Errorf("unexpected ids: %+v", tags)
+	}
+
+	// 专辑中的 MV 使用专辑的字段
+	meta := loadTestAlbum(t)
+	meta.Data[0].Attributes.TrackCount = 2
+	tags = BuildMVTags(mv, meta, 2)
+	if tags.Publisher != "Label" || tags.TrackNumber != 2 || tags.TrackTotal != 2 || tags.AlbumArtist != "Artist" {
+		t.Errorf("unexpected album tags: %+v", tags)
+	}
+
+	// 播放列表中的 MV 与音频曲目使用相同的专辑歌手
+	oldName := core.Config.PlaylistArtistName
+	core.Config.PlaylistArtistName = "Apple Music"
+	defer func() { core.Config.PlaylistArtistName = oldName }()
+	meta.Data[0].ID = "pl.u-test"
+	meta.Data[0].Attributes.Name = "My Playlist"
+	tags = BuildMVTags(mv, meta, 2)
+	if tags.Album != "My Playlist" || tags.AlbumArtist != "Apple Music" || tags.TrackNumber != 2 {
+		t.Errorf("unexpected playlist tags: %+v", tags)
+	}
+}
@@ -0,0 +1,234 @@
+package metadata
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"main/internal/core"
+	"main/utils/structs"
+)
+
+// NFO 文件名（Kodi/Jellyfin/Emby/Plex 的本地元数据约定）
+const (
+	AlbumNFOName  = "album.nfo"
+	ArtistNFOName = "artist.nfo"
+)
+
+// appleIDType NFO 中 Apple Music ID 的 uniqueid 类型
+const appleIDType = "applemusic"
+
+type nfoUniqueID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr,omitempty"`
+	Value   string `xml:",chardata"`
+}
+
+type nfoThumb struct {
+	Aspect string `xml:"aspect,attr,omitempty"`
+	URL    string `xml:",chardata"`
+}
+
+type nfoTrack struct {
+	Disc     int    `xml:"disc,omitempty"`
+	Position int    `xml:"position"`
+	Title    string `xml:"title"`
+	Duration string `xml:"duration,omitempty"`
+	ISRC     string `xml:"isrc,omitempty"`
+}
+
+type albumNFO struct {
+	XMLName     xml.Name      `xml:"album"`
+	Title       string        `xml:"title"`
+	Artist      string        `xml:"artist,omitempty"`
+	AlbumArtist string        `xml:"albumartist,omitempty"`
+	Genres      []string      `xml:"genre"`
+	Review      string        `xml:"review,omitempty"`
+	Year        string        `xml:"year,omitempty"`
+	ReleaseDate string        `xml:"releasedate,omitempty"`
+	Label       string        `xml:"label,omitempty"`
+	Copyright   string        `xml:"copyright,omitempty"`
+	UPC         string        `xml:"upc,omitempty"`
+	Compilation bool          `xml:"compilation,omitempty"`
+	UniqueIDs   []nfoUniqueID `xml:"uniqueid"`
+	Thumbs      []nfoThumb    `xml:"thumb"`
+	Tracks      []nfoTrack    `xml:"track"`
+}
+
+type artistNFO struct {
+	XMLName   xml.Name      `xml:"artist"`
+	Name      string        `xml:"name"`
+	Genres    []string      `xml:"genre"`
+	Biography string        `xml:"biography,omitempty"`
+	UniqueIDs []nfoUniqueID `xml:"uniqueid"`
+	Thumbs    []nfoThumb    `xml:"thumb"`
+}
+
+type musicVideoNFO struct {
+	XMLName   xml.Name      `xml:"musicvideo"`
+	Title     string        `xml:"title"`
+	Artist    string        `xml:"artist,omitempty"`
+	Album     string        `xml:"album,omitempty"`
+	Genres    []string      `xml:"genre"`
+	Year      string        `xml:"year,omitempty"`
+	Premiered string        `xml:"premiered,omitempty"`
+	Runtime   int           `xml:"runtime,omitempty"` // 分钟
+	Track     int           `xml:"track,omitempty"`
+	ISRC      string        `xml:"isrc,omitempty"`
+	UniqueIDs []nfoUniqueID `xml:"uniqueid"`
+	Thumbs    []nfoThumb    `xml:"thumb"`
+}
+
+// artworkURL 把目录中的封面模板地址替换为 cover-size 指定的尺寸
+func artworkURL(url string) string {
+	if url == "" {
+		return ""
+	}
+	return strings.Replace(url, "{w}x{h}", core.Config.CoverSize, 1)
+}
+
+func year(date string) string {
+	if len(date) >= 4 {
+		return date[:4]
+	}
+	return ""
+}
+
+// formatDuration 毫秒 -> m:ss
+func formatDuration(millis int) string {
+	if millis <= 0 {
+		return ""
+	}
+	seconds := millis / 1000
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// writeNFO 以 UTF-8 XML 写入 NFO，先写临时文件再替换，避免媒体服务器读到写了一半的文件
+func writeNFO(path string, v interface{}) error {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+"\n"), data...)
+	data = append(data, '\n')
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// WriteAlbumNFO 根据专辑元数据在专辑文件夹中写入 album.nfo（播放列表不生成）
+func WriteAlbumNFO(albumFolder string, meta *structs.AutoGenerated) error {
+	data := meta.Data[0]
+	if strings.Contains(data.ID, "pl.") {
+		return nil
+	}
+	albumArtist, _ := ResolveAlbumArtist(meta)
+	nfo := albumNFO{
+		Title:       data.Attributes.Name,
+		Artist:      data.Attributes.ArtistName,
+		AlbumArtist: albumArtist,
+		Genres:      data.Attributes.GenreNames,
+		Year:        year(data.Attributes.ReleaseDate),
+		ReleaseDate: data.Attributes.ReleaseDate,
+		Label:       data.Attributes.RecordLabel,
+		Copyright:   data.Attributes.Copyright,
+		UPC:         data.Attributes.Upc,
+		Compilation: data.Attributes.IsCompilation,
+		UniqueIDs:   []nfoUniqueID{{Type: appleIDType, Default: true, Value: data.ID}},
+	}
+	if data.Attributes.EditorialNotes != nil {
+		nfo.Review = cleanEditorialNotes(data.Attributes.EditorialNotes.Standard)
+	}
+	if url := artworkURL(data.Attributes.Artwork.URL); url != "" {
+		nfo.Thumbs = append(nfo.Thumbs, nfoThumb{Aspect: "cover", URL: url})
+	}
+	multiDisc := false
+	for _, track := range data.Relationships.Tracks.Data {
+		multiDisc = multiDisc || track.Attributes.DiscNumber > 1
+	}
+	for _, track := range data.Relationships.Tracks.Data {
+		t := nfoTrack{
+			Position: track.Attributes.TrackNumber,
+			Title:    track.Attributes.Name,
+			Duration: formatDuration(track.Attributes.DurationInMillis),
+			ISRC:     track.Attributes.Isrc,
+		}
+		if multiDisc {
+			t.Disc = track.Attributes.DiscNumber
+		}
+		nfo.Tracks = append(nfo.Tracks, t)
+	}
+	return writeNFO(filepath.Join(albumFolder, AlbumNFOName), nfo)
+}
+
+// WriteArtistNFO 在歌手文件夹中写入 artist.nfo
+func WriteArtistNFO(artistFolder string, artist *structs.AutoGeneratedArtistInfo) error {
+	data := artist.Data[0]
+	nfo := artistNFO{
+		Name:      data.Attributes.Name,
+		Genres:    data.Attributes.GenreNames,
+		Biography: cleanEditorialNotes(data.Attributes.ArtistBio),
+		UniqueIDs: []nfoUniqueID{{Type: appleIDType, Default: true, Value: data.ID}},
+	}
+	if nfo.Biography == "" && data.Attributes.EditorialNotes != nil {
+		nfo.Biography = cleanEditorialNotes(data.Attributes.EditorialNotes.Standard)
+	}
+	if url := artworkURL(data.Attributes.Artwork.URL); url != "" {
+		nfo.Thumbs = append(nfo.Thumbs, nfoThumb{URL: url})
+	}
+	return writeNFO(filepath.Join(artistFolder, ArtistNFOName), nfo)
+}
+
+// MusicVideoNFOPath MV 的 NFO 与视频文件同名
+func MusicVideoNFOPath(mvPath string) string {
+	return strings.TrimSuffix(mvPath, filepath.Ext(mvPath)) + ".nfo"
+}
+
+// WriteMusicVideoNFO 在 MV 文件旁写入同名 .nfo
+func WriteMusicVideoNFO(mvPath string, mv *structs.AutoGeneratedMusicVideo) error {
+	data := mv.Data[0]
+	nfo := musicVideoNFO{
+		Title:     data.Attributes.Name,
+		Artist:    data.Attributes.ArtistName,
+		Album:     data.Attributes.AlbumName,
+		Genres:    data.Attributes.GenreNames,
+		Year:      year(data.Attributes.ReleaseDate),
+		Premiered: data.Attributes.ReleaseDate,
+		Runtime:   (data.Attributes.DurationInMillis + 30000) / 60000,
+		Track:     data.Attributes.TrackNumber,
+		ISRC:      data.Attributes.Isrc,
+		UniqueIDs: []nfoUniqueID{{Type: appleIDType, Default: true, Value: data.ID}},
+	}
+	if url := artworkURL(data.Attributes.Artwork.URL); url != "" {
+		nfo.Thumbs = append(nfo.Thumbs, nfoThumb{URL: url})
+	}
+	return writeNFO(MusicVideoNFOPath(mvPath), nfo)
+}
+
+// ReadNFOAppleID 读取 NFO 的类型（album/artist/musicvideo）和其中的 Apple Music ID
+func ReadNFOAppleID(path string) (string, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	var doc struct {
+		XMLName   xml.Name
+		UniqueIDs []nfoUniqueID `xml:"uniqueid"`
+	}
+	if err := xml.NewDecoder(f).Decode(&doc); err != nil {
+		return "", "", err
+	}
+	for _, id := range doc.UniqueIDs {
+		if id.Type == appleIDType && strings.TrimSpace(id.Value) != "" {
+			return doc.XMLName.Local, strings.TrimSpace(id.Value), nil
+		}
+	}
+	return doc.XMLName.Local, "", errors.New("NFO 中没有 Apple Music ID")
+}
@@ -0,0 +1,42 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteAlbumNFO(t *testing.T) {
+	meta := loadTestAlbum(t)
+	meta.Data[0].Attributes.GenreNames = []string{"J-Pop", "Music"}
+	dir := t.TempDir()
+
+	if err := WriteAlbumNFO(dir, meta); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, AlbumNFOName)
+	kind, id, err := ReadNFOAppleID(path)
+	if err != nil || kind != "album" || id != "1500000000" {
+		t.Fatalf("ReadNFOAppleID = %q, %q, %v", kind, id, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<title>Album</title>", "<year>2020</year>", "<label>Label</label>", "<genre>J-Pop</genre>", "<position>2</position>"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("album.nfo missing %s:\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "<disc>") {
+		t.Error("single-disc album should not write disc numbers")
+	}
+}
+
+func TestCleanEditorialNotes(t *testing.T) {
+	got := cleanEditorialNotes("<p>First<br/>line</p>\n\n\n<b>Second</b>")
+	if strings.Contains(got, "<") || !strings.Contains(got, "Second") {
+		t.Errorf("cleanEditorialNotes = %q", got)
+	}
+}
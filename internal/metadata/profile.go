@@ -0,0 +1,311 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"main/internal/core"
+	"main/internal/utils"
+	"main/utils/structs"
+
+	"github.com/zhaarey/go-mp4tag"
+)
+
+// freeformPrefix 目标标签前缀：写入 ----:com.apple.iTunes:名称 自定义标签
+const freeformPrefix = "freeform:"
+
+// TagSources 可映射的目录字段
+var TagSources = []string{
+	"title", "title-sort", "artist", "artist-sort", "album", "album-sort", "album-artist", "album-artist-sort",
+	"composer", "composer-sort", "genre", "date", "release-date", "copyright", "label", "upc", "isrc",
+	"performer", "quality", "quality-variant", "comment", "lyrics-source",
+}
+
+// defaultFields default 方案的映射，与之前固定写入的标签一致
+var defaultFields = map[string]string{
+	"title":             "title",
+	"title-sort":        "title-sort",
+	"artist":            "artist",
+	"artist-sort":       "artist-sort",
+	"album":             "album",
+	"album-sort":        "album-sort",
+	"album-artist":      "album-artist",
+	"album-artist-sort": "album-artist-sort",
+	"composer":          "composer",
+	"composer-sort":     "composer-sort",
+	"genre":             "genre",
+	"date":              "date",
+	"release-date":      "freeform:RELEASETIME",
+	"copyright":         "copyright",
+	"label":             "publisher,freeform:LABEL",
+	"upc":               "freeform:UPC",
+	"isrc":              "freeform:ISRC",
+	"performer":         "freeform:PERFORMER",
+	"quality":           "freeform:QUALITY",
+	"quality-variant":   "freeform:QUALITY_VARIANT",
+	"comment":           "comment",
+	"lyrics-source":     "freeform:LYRICS_SOURCE",
+}
+
+func strPtr(s string) *string { return &s }
+
+// presetProfiles 预设方案，只列出与 default 不同的字段
+var presetProfiles = map[string]structs.TagProfile{
+	"default": {},
+	// foobar2000 按名称显示 freeform 标签，多值字段以 "; " 分隔
+	"foobar2000": {
+		GenreSeparator: strPtr("; "),
+		GenreExclude:   []string{"Music"},
+		Fields: map[string]string{
+			"title-sort":   "",
+			"release-date": "freeform:RELEASEDATE",
+			"upc":          "freeform:BARCODE",
+			"performer":    "",
+		},
+	},
+	// Navidrome（TagLib）读取 LABEL/RELEASEDATE/BARCODE 等 freeform 标签，流派按 ";" 拆分
+	"navidrome": {
+		GenreSeparator: strPtr(";"),
+		GenreExclude:   []string{"Music"},
+		Fields: map[string]string{
+			"release-date": "freeform:RELEASEDATE",
+			"label":        "freeform:LABEL",
+			"upc":          "freeform:BARCODE",
+			"performer":    "",
+		},
+	},
+	// Plex 只读取标准标签，自定义标签只保留 ISRC 与音质
+	"plex": {
+		GenreSeparator: strPtr(";"),
+		GenreExclude:   []string{"Music"},
+		Fields: map[string]string{
+			"release-date": "",
+			"label":        "publisher",
+			"upc":          "",
+			"performer":    "",
+		},
+	},
+}
+
+// tagProfile 解析后的标签映射方案
+type tagProfile struct {
+	name           string
+	genreSeparator string
+	genreExclude   []string
+	sortLanguage   string
+	fields         map[string][]string // 目录字段 -> 目标标签
+}
+
+var (
+	activeProfile   *tagProfile
+	activeProfileMu sync.RWMutex
+)
+
+// InitTagProfile 解析并校验配置中的 tag-profile，之后写入的标签都使用该方案
+func InitTagProfile() error {
+	p, err := resolveTagProfile(core.Config.TagProfile, core.Config.TagProfiles)
+	if err != nil {
+		return err
+	}
+	activeProfileMu.Lock()
+	activeProfile = p
+	activeProfileMu.Unlock()
+	return nil
+}
+
+// currentProfile 返回当前方案，未初始化时使用 default
+func currentProfile() *tagProfile {
+	activeProfileMu.RLock()
+	p := activeProfile
+	activeProfileMu.RUnlock()
+	if p == nil {
+		p, _ = resolveTagProfile("default", nil)
+	}
+	return p
+}
+
+// SortLanguage 当前方案要求的排序标签语言，与 language 相同或未设置时返回空字符串
+func SortLanguage() string {
+	lang := currentProfile().sortLanguage
+	if strings.EqualFold(lang, core.Config.Language) {
+		return ""
+	}
+	return lang
+}
+
+// resolveTagProfile 按 default -> 预设方案 -> 自定义方案的顺序合并出最终方案
+func resolveTagProfile(name string, custom map[string]structs.TagProfile) (*tagProfile, error) {
+	if name == "" {
+		name = "default"
+	}
+	layers := []structs.TagProfile{presetProfiles["default"]}
+	if preset, ok := presetProfiles[name]; ok {
+		layers = append(layers, preset)
+	} else if c, ok := custom[name]; ok {
+		base := c.Base
+		if base == "" {
+			base = "default"
+		}
+		preset, ok := presetProfiles[base]
+		if !ok {
+			return nil, fmt.Errorf("标签方案 %s 的 base '%s' 不是预设方案（可选：%s）", name, base, strings.Join(presetNames(), ", "))
+		}
+		layers = append(layers, preset, c)
+	} else {
+		return nil, fmt.Errorf("未知的标签方案 '%s'（预设：%s，或在 tag-profiles 中定义）", name, strings.Join(presetNames(), ", "))
+	}
+
+	p := &tagProfile{name: name, fields: make(map[string][]string)}
+	merged := make(map[string]string)
+	for k, v := range defaultFields {
+		merged[k] = v
+	}
+	for _, layer := range layers {
+		if layer.GenreSeparator != nil {
+			p.genreSeparator = *layer.GenreSeparator
+		}
+		if layer.GenreExclude != nil {
+			p.genreExclude = layer.GenreExclude
+		}
+		if layer.SortLanguage != nil {
+			p.sortLanguage = *layer.SortLanguage
+		}
+		for k, v := range layer.Fields {
+			merged[strings.ToLower(strings.TrimSpace(k))] = v
+		}
+	}
+
+	for source, targets := range merged {
+		if !utils.Contains(TagSources, source) {
+			return nil, fmt.Errorf("标签方案 %s 中的字段 '%s' 无效（可选：%s）", name, source, strings.Join(TagSources, ", "))
+		}
+		for _, target := range strings.Split(targets, ",") {
+			target = strings.TrimSpace(target)
+			if target == "" {
+				continue
+			}
+			if strings.HasPrefix(strings.ToLower(target), freeformPrefix) {
+				key := strings.ToUpper(strings.TrimSpace(target[len(freeformPrefix):]))
+				if key == "" {
+					return nil, fmt.Errorf("标签方案 %s 中字段 %s 的 freeform 标签缺少名称", name, source)
+				}
+				p.fields[source] = append(p.fields[source], freeformPrefix+key)
+				continue
+			}
+			target = strings.ToLower(target)
+			if findTagField(target) == nil {
+				return nil, fmt.Errorf("标签方案 %s 中字段 %s 的目标标签 '%s' 无效（可选：%s 或 freeform:名称）", name, source, target, strings.Join(stringTagFields(), ", "))
+			}
+			p.fields[source] = append(p.fields[source], target)
+		}
+	}
+	return p, nil
+}
+
+func presetNames() []string {
+	names := make([]string, 0, len(presetProfiles))
+	for name := range presetProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// freeformKeys 方案中写入的 freeform 标签名
+func (p *tagProfile) freeformKeys() []string {
+	var keys []string
+	for _, targets := range p.fields {
+		for _, target := range targets {
+			if strings.HasPrefix(target, freeformPrefix) {
+				keys = append(keys, target[len(freeformPrefix):])
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// joinGenres 按方案的连接符合并流派，去掉排除的流派；连接符为空时只取第一个
+func (p *tagProfile) joinGenres(genres []string) string {
+	var kept []string
+	for _, g := range genres {
+		if g == "" || utils.Contains(kept, g) {
+			continue
+		}
+		excluded := false
+		for _, ex := range p.genreExclude {
+			excluded = excluded || strings.EqualFold(ex, g)
+		}
+		if !excluded {
+			kept = append(kept, g)
+		}
+	}
+	if len(kept) == 0 {
+		return ""
+	}
+	if p.genreSeparator == "" {
+		return kept[0]
+	}
+	return strings.Join(kept, p.genreSeparator)
+}
+
+// apply 把目录字段的值按映射写入标签（按 TagSources 的顺序，多个字段映射到同一标签时后者优先）
+func (p *tagProfile) apply(t *mp4tag.MP4Tags, values map[string]string) {
+	for _, source := range TagSources {
+		targets := p.fields[source]
+		v := values[source]
+		if v == "" {
+			continue
+		}
+		for _, target := range targets {
+			if strings.HasPrefix(target, freeformPrefix) {
+				t.Custom[target[len(freeformPrefix):]] = v
+				continue
+			}
+			findTagField(target).set(t, v)
+		}
+	}
+}
+
+// sortNames 用于排序标签的本地化名称（来自 sort-language 的目录元数据）
+type sortNames struct {
+	Title       string
+	Artist      string
+	Composer    string
+	Album       string
+	AlbumArtist string
+}
+
+var sortNameCache sync.Map // 曲目ID -> sortNames
+
+// SetSortMetadata 记录以 sort-language 获取的专辑或播放列表元数据，生成标签时用作排序名称
+func SetSortMetadata(meta *structs.AutoGenerated) {
+	if meta == nil || len(meta.Data) == 0 {
+		return
+	}
+	isPlaylist := strings.Contains(meta.Data[0].ID, "pl.")
+	for _, track := range meta.Data[0].Relationships.Tracks.Data {
+		names := sortNames{
+			Title:    track.Attributes.Name,
+			Artist:   track.Attributes.ArtistName,
+			Composer: track.Attributes.ComposerName,
+			Album:    utils.StripCodecSuffix(track.Attributes.AlbumName),
+		}
+		if !isPlaylist {
+			names.AlbumArtist = meta.Data[0].Attributes.ArtistName
+		} else if len(track.Relationships.Albums.Data) > 0 {
+			names.AlbumArtist = track.Relationships.Albums.Data[0].Attributes.ArtistName
+		}
+		sortNameCache.Store(track.ID, names)
+	}
+}
+
+func lookupSortNames(trackID string) (sortNames, bool) {
+	v, ok := sortNameCache.Load(trackID)
+	if !ok {
+		return sortNames{}, false
+	}
+	return v.(sortNames), true
+}
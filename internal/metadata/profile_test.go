@@ -0,0 +1,121 @@
+package metadata
+
+import (
+	"encoding/json"
+	"testing"
+
+	"main/utils/structs"
+)
+
+// testAlbum 两首曲目的专辑元数据，第二首没有流派
+const testAlbum = `{"data":[{"id":"1500000000","attributes":{"artistName":"Artist","name":"Album","releaseDate":"2020-01-01",
+"recordLabel":"Label","upc":"0123","copyright":"℗ 2020 Label"},"relationships":{"tracks":{"data":[
+{"id":"1500000001","attributes":{"name":"Song","artistName":"Artist","albumName":"Album","genreNames":["J-Pop","Music","Pop"],
+"isrc":"JPXX02000001","discNumber":1,"trackNumber":1,"releaseDate":"2020-01-01"}},
+{"id":"1500000002","attributes":{"name":"Interlude","artistName":"Artist","albumName":"Album","genreNames":[],
+"discNumber":1,"trackNumber":2}}]}}}]}`
+
+func loadTestAlbum(t *testing.T) *structs.AutoGenerated {
+	t.Helper()
+	meta := new(structs.AutoGenerated)
+	if err := json.Unmarshal([]byte(testAlbum), meta); err != nil {
+		t.Fatal(err)
+	}
+	return meta
+}
+
+func useProfile(t *testing.T, name string, custom map[string]structs.TagProfile) {
+	t.Helper()
+	p, err := resolveTagProfile(name, custom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeProfile = p
+	t.Cleanup(func() { activeProfile = nil })
+}
+
+func TestBuildMP4TagsDefaultProfile(t *testing.T) {
+	meta := loadTestAlbum(t)
+	useProfile(t, "default", nil)
+
+	tags := BuildMP4Tags("song.m4a", "", "apple", meta, 1, 2, "Alac")
+	if tags.CustomGenre != "J-Pop" || tags.TitleSort != "Song" || tags.Publisher != "Label" {
+		t.Errorf("unexpected standard tags: %+v", tags)
+	}
+	for key, want := range map[string]string{"LABEL": "Label", "PERFORMER": "Artist", "RELEASETIME": "2020-01-01", "QUALITY": "Alac", "APPLE_TRACK_ID": "1500000001", "LYRICS_SOURCE": "apple"} {
+		if tags.Custom[key] != want {
+			t.Errorf("custom %s = %q, want %q", key, tags.Custom[key], want)
+		}
+	}
+
+	// 没有流派的曲目不再 panic
+	tags = BuildMP4Tags("interlude.m4a", "", "", meta, 2, 2, "Alac")
+	if tags.CustomGenre != "" {
+		t.Errorf("expected empty genre, got %q", tags.CustomGenre)
+	}
+}
+
+func TestBuildMP4TagsNavidromeProfile(t *testing.T) {
+	meta := loadTestAlbum(t)
+	useProfile(t, "navidrome", nil)
+
+	tags := BuildMP4Tags("song.m4a", "", "", meta, 1, 2, "Alac")
+	if tags.CustomGenre != "J-Pop;Pop" {
+		t.Errorf("genre = %q", tags.CustomGenre)
+	}
+	if tags.Publisher != "" || tags.Custom["LABEL"] != "Label" || tags.Custom["BARCODE"] != "0123" || tags.Custom["RELEASEDATE"] != "2020-01-01" {
+		t.Errorf("unexpected tags: %+v", tags)
+	}
+	if _, ok := tags.Custom["PERFORMER"]; ok {
+		t.Error("performer should be disabled")
+	}
+}
+
+func TestCustomProfile(t *testing.T) {
+	meta := loadTestAlbum(t)
+	sep, lang := " / ", "en-US"
+	useProfile(t, "mine", map[string]structs.TagProfile{
+		"mine": {
+			Base:           "plex",
+			GenreSeparator: &sep,
+			SortLanguage:   &lang,
+			Fields:         map[string]string{"isrc": "", "copyright": "freeform:copyright,comment"},
+		},
+	})
+
+	SetSortMetadata(func() *structs.AutoGenerated {
+		m := loadTestAlbum(t)
+		m.Data[0].Relationships.Tracks.Data[0].Attributes.Name = "Song (EN)"
+		m.Data[0].Attributes.ArtistName = "Artist (EN)"
+		return m
+	}())
+
+	tags := BuildMP4Tags("song.m4a", "", "", meta, 1, 2, "Alac")
+	if tags.CustomGenre != "J-Pop / Pop" {
+		t.Errorf("genre = %q", tags.CustomGenre)
+	}
+	if _, ok := tags.Custom["ISRC"]; ok {
+		t.Error("isrc should be disabled")
+	}
+	if tags.Custom["COPYRIGHT"] != "℗ 2020 Label" || tags.Comment != "℗ 2020 Label" {
+		t.Errorf("copyright not mapped: %+v", tags)
+	}
+	if tags.TitleSort != "Song (EN)" || tags.AlbumArtistSort != "Artist (EN)" || tags.Title != "Song" {
+		t.Errorf("unexpected sort tags: title-sort %q album-artist-sort %q", tags.TitleSort, tags.AlbumArtistSort)
+	}
+}
+
+func TestResolveTagProfileErrors(t *testing.T) {
+	cases := map[string]map[string]structs.TagProfile{
+		"missing":   nil,
+		"bad-base":  {"bad-base": {Base: "winamp"}},
+		"bad-field": {"bad-field": {Fields: map[string]string{"mood": "comment"}}},
+		"bad-atom":  {"bad-atom": {Fields: map[string]string{"label": "grouping"}}},
+		"empty-ff":  {"empty-ff": {Fields: map[string]string{"label": "freeform:"}}},
+	}
+	for name, custom := range cases {
+		if _, err := resolveTagProfile(name, custom); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
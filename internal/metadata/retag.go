@@ -0,0 +1,269 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"main/internal/utils"
+	"main/utils/structs"
+
+	"github.com/zhaarey/go-mp4tag"
+)
+
+// tagField 可比较、可单独重写的标签字段
+type tagField struct {
+	name string
+	get  func(t *mp4tag.MP4Tags) string
+	copy func(dst, src *mp4tag.MP4Tags)
+	set  func(t *mp4tag.MP4Tags, v string) // 仅字符串字段可作为标签方案的目标
+}
+
+// stringField 由字段指针生成字符串标签字段
+func stringField(name string, ptr func(t *mp4tag.MP4Tags) *string) tagField {
+	return tagField{
+		name: name,
+		get:  func(t *mp4tag.MP4Tags) string { return *ptr(t) },
+		copy: func(d, s *mp4tag.MP4Tags) { *ptr(d) = *ptr(s) },
+		set:  func(t *mp4tag.MP4Tags, v string) { *ptr(t) = v },
+	}
+}
+
+// tagFields 标准标签字段（自定义字段以小写键名表示，如 isrc、label、quality）
+var tagFields = []tagField{
+	stringField("title", func(t *mp4tag.MP4Tags) *string { return &t.Title }),
+	stringField("title-sort", func(t *mp4tag.MP4Tags) *string { return &t.TitleSort }),
+	stringField("artist", func(t *mp4tag.MP4Tags) *string { return &t.Artist }),
+	stringField("artist-sort", func(t *mp4tag.MP4Tags) *string { return &t.ArtistSort }),
+	stringField("album", func(t *mp4tag.MP4Tags) *string { return &t.Album }),
+	stringField("album-sort", func(t *mp4tag.MP4Tags) *string { return &t.AlbumSort }),
+	stringField("album-artist", func(t *mp4tag.MP4Tags) *string { return &t.AlbumArtist }),
+	stringField("album-artist-sort", func(t *mp4tag.MP4Tags) *string { return &t.AlbumArtistSort }),
+	stringField("composer", func(t *mp4tag.MP4Tags) *string { return &t.Composer }),
+	stringField("composer-sort", func(t *mp4tag.MP4Tags) *string { return &t.ComposerSort }),
+	stringField("genre", func(t *mp4tag.MP4Tags) *string { return &t.CustomGenre }),
+	stringField("date", func(t *mp4tag.MP4Tags) *string { return &t.Date }),
+	stringField("copyright", func(t *mp4tag.MP4Tags) *string { return &t.Copyright }),
+	stringField("publisher", func(t *mp4tag.MP4Tags) *string { return &t.Publisher }),
+	stringField("comment", func(t *mp4tag.MP4Tags) *string { return &t.Comment }),
+	stringField("description", func(t *mp4tag.MP4Tags) *string { return &t.Description }),
+	{name: "track", get: func(t *mp4tag.MP4Tags) string { return numberPair(t.TrackNumber, t.TrackTotal) }, copy: func(d, s *mp4tag.MP4Tags) {
+		d.TrackNumber, d.TrackTotal = s.TrackNumber, s.TrackTotal
+	}},
+	{name: "disc", get: func(t *mp4tag.MP4Tags) string { return numberPair(t.DiscNumber, t.DiscTotal) }, copy: func(d, s *mp4tag.MP4Tags) {
+		d.DiscNumber, d.DiscTotal = s.DiscNumber, s.DiscTotal
+	}},
+	{name: "advisory", get: func(t *mp4tag.MP4Tags) string { return advisoryName(t.ItunesAdvisory) }, copy: func(d, s *mp4tag.MP4Tags) { d.ItunesAdvisory = s.ItunesAdvisory }},
+	{name: "album-id", get: func(t *mp4tag.MP4Tags) string { return idString(t.ItunesAlbumID) }, copy: func(d, s *mp4tag.MP4Tags) { d.ItunesAlbumID = s.ItunesAlbumID }},
+	{name: "artist-id", get: func(t *mp4tag.MP4Tags) string { return idString(t.ItunesArtistID) }, copy: func(d, s *mp4tag.MP4Tags) { d.ItunesArtistID = s.ItunesArtistID }},
+}
+
+// findTagField 按名称查找可作为映射目标的字符串字段
+func findTagField(name string) *tagField {
+	for i := range tagFields {
+		if tagFields[i].name == name && tagFields[i].set != nil {
+			return &tagFields[i]
+		}
+	}
+	return nil
+}
+
+// stringTagFields 可作为映射目标的字段名
+func stringTagFields() []string {
+	var names []string
+	for _, f := range tagFields {
+		if f.set != nil {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+func numberPair(n, total int16) string {
+	if n == 0 && total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", n, total)
+}
+
+func idString(id int32) string {
+	if id == 0 {
+		return ""
+	}
+	return fmt.Sprint(id)
+}
+
+func advisoryName(a mp4tag.ItunesAdvisory) string {
+	switch a {
+	case mp4tag.ItunesAdvisoryExplicit:
+		return "explicit"
+	case mp4tag.ItunesAdvisoryClean:
+		return "clean"
+	}
+	return ""
+}
+
+// TagChange 一个字段的新旧值
+type TagChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// customFieldName 自定义字段在 --fields 和差异输出中的名称
+func customFieldName(key string) string {
+	return strings.ToLower(key)
+}
+
+// DiffTags 比较文件中的标签与新生成的标签，返回会被改写的字段
+// 写入时空值保留原值，所以只列出新值非空且与原值不同的字段；fields 非空时只比较其中的字段
+func DiffTags(old, fresh *mp4tag.MP4Tags, fields []string) []TagChange {
+	allowed := func(name string) bool {
+		return len(fields) == 0 || utils.Contains(fields, name)
+	}
+
+	var changes []TagChange
+	for _, f := range tagFields {
+		if !allowed(f.name) {
+			continue
+		}
+		if n, o := f.get(fresh), f.get(old); n != "" && n != o {
+			changes = append(changes, TagChange{Field: f.name, Old: o, New: n})
+		}
+	}
+
+	keys := make([]string, 0, len(fresh.Custom))
+	for k := range fresh.Custom {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name := customFieldName(k)
+		if !allowed(name) {
+			continue
+		}
+		n := fresh.Custom[k]
+		var o string
+		if old.Custom != nil {
+			o = old.Custom[strings.ToUpper(k)]
+		}
+		if n != "" && n != o {
+			changes = append(changes, TagChange{Field: name, Old: o, New: n})
+		}
+	}
+	return changes
+}
+
+// FilterTags 返回只包含 fields 中字段的标签副本，fields 为空时原样返回
+func FilterTags(t *mp4tag.MP4Tags, fields []string) *mp4tag.MP4Tags {
+	if len(fields) == 0 {
+		return t
+	}
+	filtered := &mp4tag.MP4Tags{Custom: map[string]string{}}
+	for _, f := range tagFields {
+		if utils.Contains(fields, f.name) {
+			f.copy(filtered, t)
+		}
+	}
+	for k, v := range t.Custom {
+		if utils.Contains(fields, customFieldName(k)) {
+			filtered.Custom[k] = v
+		}
+	}
+	return filtered
+}
+
+// TagFieldNames 返回 retag 可选的字段名（标准字段加上 default 方案与当前方案写入的自定义字段）
+func TagFieldNames() []string {
+	names := make([]string, 0, len(tagFields)+16)
+	for _, f := range tagFields {
+		names = append(names, f.name)
+	}
+	names = append(names, "apple_track_id")
+	defaultProfile, _ := resolveTagProfile("default", nil)
+	for _, p := range []*tagProfile{defaultProfile, currentProfile()} {
+		for _, key := range p.freeformKeys() {
+			if name := customFieldName(key); !utils.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// ParseTagFields 解析逗号分隔的字段列表并校验字段名
+func ParseTagFields(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	valid := TagFieldNames()
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		if !utils.Contains(valid, f) {
+			return nil, fmt.Errorf("未知的标签字段 %q（可选：%s）", f, strings.Join(valid, ", "))
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// FindTrack 在专辑元数据中按曲目ID（优先）或 ISRC 查找曲目，返回从 1 开始的曲目序号
+func FindTrack(meta *structs.AutoGenerated, trackID, isrc string) (int, bool) {
+	tracks := meta.Data[0].Relationships.Tracks.Data
+	if trackID != "" {
+		for i, track := range tracks {
+			if track.ID == trackID {
+				return i + 1, true
+			}
+		}
+	}
+	if isrc != "" {
+		for i, track := range tracks {
+			if strings.EqualFold(track.Attributes.Isrc, isrc) {
+				return i + 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// FileQuality 返回文件自身的音质描述：优先使用已有的音质标签（default 方案或当前方案的名称），否则按实际编码推断
+// 两者都无法获得时返回空字符串
+func FileQuality(path string, tags *mp4tag.MP4Tags) string {
+	if tags.Custom != nil {
+		keys := []string{"QUALITY"}
+		for _, target := range currentProfile().fields["quality"] {
+			if strings.HasPrefix(target, freeformPrefix) {
+				keys = append(keys, target[len(freeformPrefix):])
+			}
+		}
+		for _, key := range keys {
+			if q := tags.Custom[key]; q != "" {
+				return q
+			}
+		}
+	}
+	if info, err := ReadStreamInfo(path); err == nil {
+		return QualityFromStream(info)
+	}
+	return ""
+}
+
+// QualityFromStream 按文件的实际编码推断 QUALITY 标签，用于旧版本下载的没有 QUALITY 标签的文件
+func QualityFromStream(info StreamInfo) string {
+	switch {
+	case info.Codec == "alac" && info.SampleRate > 48000:
+		return utils.FormatQualityTag("Hi-Res Lossless")
+	case info.Codec == "alac":
+		return utils.FormatQualityTag("Alac")
+	case info.Codec == "ec-3":
+		return utils.FormatQualityTag("Dolby Atmos")
+	case info.Codec == "ac-3":
+		return "Dolby Audio"
+	}
+	return utils.FormatQualityTag("Aac 256")
+}
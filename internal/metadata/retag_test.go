@@ -0,0 +1,70 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/zhaarey/go-mp4tag"
+)
+
+func TestDiffTags(t *testing.T) {
+	old := &mp4tag.MP4Tags{
+		Title:       "Song",
+		CustomGenre: "Pop",
+		Copyright:   "℗ 2019",
+		Custom:      map[string]string{"ISRC": "USRC11900001", "QUALITY": "Alac"},
+	}
+	fresh := &mp4tag.MP4Tags{
+		Title:       "Song",
+		CustomGenre: "Alternative",
+		Copyright:   "℗ 2020",
+		Comment:     "",
+		Custom:      map[string]string{"ISRC": "USRC11900001", "QUALITY": "Alac", "LABEL": "Label"},
+	}
+
+	changes := DiffTags(old, fresh, nil)
+	got := make(map[string]TagChange)
+	for _, c := range changes {
+		got[c.Field] = c
+	}
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", changes)
+	}
+	if c := got["genre"]; c.Old != "Pop" || c.New != "Alternative" {
+		t.Errorf("unexpected genre change %+v", c)
+	}
+	if c := got["label"]; c.Old != "" || c.New != "Label" {
+		t.Errorf("unexpected label change %+v", c)
+	}
+
+	changes = DiffTags(old, fresh, []string{"genre"})
+	if len(changes) != 1 || changes[0].Field != "genre" {
+		t.Fatalf("expected only genre, got %+v", changes)
+	}
+}
+
+func TestFilterTags(t *testing.T) {
+	tags := &mp4tag.MP4Tags{
+		Title:       "Song",
+		CustomGenre: "Alternative",
+		Copyright:   "℗ 2020",
+		TrackNumber: 3,
+		Custom:      map[string]string{"LABEL": "Label", "ISRC": "USRC11900001"},
+	}
+	filtered := FilterTags(tags, []string{"genre", "copyright", "label"})
+	if filtered.Title != "" || filtered.TrackNumber != 0 || filtered.Custom["ISRC"] != "" {
+		t.Errorf("unexpected fields kept: %+v", filtered)
+	}
+	if filtered.CustomGenre != "Alternative" || filtered.Copyright != "℗ 2020" || filtered.Custom["LABEL"] != "Label" {
+		t.Errorf("allowed fields dropped: %+v", filtered)
+	}
+}
+
+func TestParseTagFields(t *testing.T) {
+	fields, err := ParseTagFields(" Genre, copyright ")
+	if err != nil || len(fields) != 2 || fields[0] != "genre" {
+		t.Fatalf("unexpected result %v %v", fields, err)
+	}
+	if _, err := ParseTagFields("genre,bogus"); err == nil {
+		t.Fatal("expected error for unknown field")
+	}
+}
@@ -0,0 +1,83 @@
+package metadata
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// StreamInfo 音频文件的实际流信息（来自 stsd 采样描述）
+type StreamInfo struct {
+	Codec      string // 采样描述类型，如 alac、mp4a、ec-3
+	SampleRate int
+	BitDepth   int
+	Channels   int
+}
+
+// Lossless 判断是否为无损编码
+func (s StreamInfo) Lossless() bool {
+	return s.Codec == "alac"
+}
+
+// ReadStreamInfo 读取 M4A 文件第一条音轨的流信息
+func ReadStreamInfo(path string) (StreamInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return StreamInfo{}, err
+	}
+	defer f.Close()
+
+	parsed, err := mp4.DecodeFile(f, mp4.WithDecodeMode(mp4.DecModeLazyMdat))
+	if err != nil {
+		return StreamInfo{}, fmt.Errorf("解析 MP4 失败: %w", err)
+	}
+	if parsed.Moov == nil || len(parsed.Moov.Traks) == 0 {
+		return StreamInfo{}, errors.New("文件中没有音轨")
+	}
+	trak := parsed.Moov.Traks[0]
+	if trak.Mdia == nil || trak.Mdia.Minf == nil || trak.Mdia.Minf.Stbl == nil || trak.Mdia.Minf.Stbl.Stsd == nil {
+		return StreamInfo{}, errors.New("缺少采样描述 (stsd)")
+	}
+	stsd := trak.Mdia.Minf.Stbl.Stsd
+	if len(stsd.Children) == 0 {
+		return StreamInfo{}, errors.New("采样描述为空")
+	}
+
+	switch entry := stsd.Children[0].(type) {
+	case *mp4.AudioSampleEntryBox:
+		return StreamInfo{
+			Codec:      entry.Type(),
+			SampleRate: int(entry.SampleRate),
+			BitDepth:   int(entry.SampleSize),
+			Channels:   int(entry.ChannelCount),
+		}, nil
+	case *mp4.UnknownBox:
+		// mp4ff 不解析 alac 采样描述，这里直接读取 ALACSpecificConfig
+		if entry.Type() == "alac" {
+			return parseAlacSampleEntry(entry.Payload())
+		}
+		return StreamInfo{Codec: entry.Type()}, nil
+	default:
+		return StreamInfo{Codec: entry.Type()}, nil
+	}
+}
+
+// parseAlacSampleEntry 解析 alac 采样描述
+// 结构: AudioSampleEntry 公共字段（28 字节）+ alac 子box（8 字节头 + 4 字节版本 + ALACSpecificConfig）
+// 外层 SampleRate 为 16.16 定点数，无法表示 65535Hz 以上的采样率，因此使用 ALACSpecificConfig 中的值
+func parseAlacSampleEntry(payload []byte) (StreamInfo, error) {
+	const configOffset = 28 + 8 + 4
+	if len(payload) < configOffset+24 {
+		return StreamInfo{}, errors.New("alac 采样描述长度不足")
+	}
+	config := payload[configOffset:]
+	return StreamInfo{
+		Codec:      "alac",
+		BitDepth:   int(config[5]),
+		Channels:   int(config[9]),
+		SampleRate: int(binary.BigEndian.Uint32(config[20:24])),
+	}, nil
+}
@@ -13,6 +13,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 
 	"main/internal/core"
 	"main/internal/utils"
@@ -42,6 +43,25 @@ func getQualityString(audioTraits []string) string {
 	return utils.FormatQualityTag("Aac 256")
 }
 
+// StreamVariant 按音质回退链实际下载的变体，写入标签时覆盖按音频特性推断的 QUALITY
+type StreamVariant struct {
+	Tier    string // 命中的回退等级，如 hires-96
+	Quality string // QUALITY 标签值，如 Hi-Res Lossless
+	Detail  string // 实际音质，如 24bit/96.0kHz
+}
+
+var streamVariants sync.Map // 文件路径 -> StreamVariant
+
+// SetStreamVariant 记录文件实际下载的变体，供 WriteMP4Tags 使用
+func SetStreamVariant(trackPath string, v StreamVariant) {
+	streamVariants.Store(trackPath, v)
+}
+
+// ClearStreamVariant 清除文件的变体记录
+func ClearStreamVariant(trackPath string) {
+	streamVariants.Delete(trackPath)
+}
+
 func WriteCover(sanAlbumFolder, name string, url string) (string, error) {
 	covPath := filepath.Join(sanAlbumFolder, name+"."+core.Config.CoverFormat)
 	if core.Config.CoverFormat == "original" {
@@ -91,20 +111,6 @@ func WriteCover(sanAlbumFolder, name string, url string) (string, error) {
 	return covPath, nil
 }
 
-func WriteLyrics(sanAlbumFolder, filename string, lrc string) error {
-	lyricspath := filepath.Join(sanAlbumFolder, filename)
-	f, err := os.Create(lyricspath)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	_, err = f.WriteString(lrc)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 // fixIlstBoxMissing 使用 FFmpeg 重新封装 MP4 文件以添加缺失的 ilst box
 // 参数:
 //   - trackPath: 需要修复的音频文件路径
@@ -161,9 +167,9 @@ func fixIlstBoxMissing(trackPath string) error {
 // 参数与 WriteMP4Tags 相同
 // 返回:
 //   - error: 写入或修复过程中的错误
-func WriteMP4TagsWithRetry(trackPath, lrc string, meta *structs.AutoGenerated, trackNum, trackTotal int) error {
+func WriteMP4TagsWithRetry(trackPath, lrc, lyricsSource string, meta *structs.AutoGenerated, trackNum, trackTotal int) error {
 	// 第一次尝试写入标签
-	err := WriteMP4Tags(trackPath, lrc, meta, trackNum, trackTotal)
+	err := WriteMP4Tags(trackPath, lrc, lyricsSource, meta, trackNum, trackTotal)
 
 	// 如果没有错误，直接返回
 	if err == nil {
@@ -183,7 +189,7 @@ func WriteMP4TagsWithRetry(trackPath, lrc string, meta *structs.AutoGenerated, t
 	}
 
 	// 修复成功后重试写入标签
-	retryErr := WriteMP4Tags(trackPath, lrc, meta, trackNum, trackTotal)
+	retryErr := WriteMP4Tags(trackPath, lrc, lyricsSource, meta, trackNum, trackTotal)
 	if retryErr != nil {
 		return fmt.Errorf("修复后标签写入仍失败: %w", retryErr)
 	}
@@ -191,40 +197,76 @@ func WriteMP4TagsWithRetry(trackPath, lrc string, meta *structs.AutoGenerated, t
 	return nil
 }
 
-func WriteMP4Tags(trackPath, lrc string, meta *structs.AutoGenerated, trackNum, trackTotal int) error {
-	index := trackNum - 1
+func WriteMP4Tags(trackPath, lrc, lyricsSource string, meta *structs.AutoGenerated, trackNum, trackTotal int) error {
+	return WriteTags(trackPath, BuildMP4Tags(trackPath, lrc, lyricsSource, meta, trackNum, trackTotal, ""))
+}
+
+// WriteTags 将标签合并写入文件（空值字段保留文件中的原值）
+func WriteTags(trackPath string, t *mp4tag.MP4Tags) error {
+	mp4, err := mp4tag.Open(trackPath)
+	if err != nil {
+		return err
+	}
+	defer mp4.Close()
+	return mp4.Write(t, []string{})
+}
+
+// LyricsTags 为已下载的文件补充歌词时使用的标签：内嵌歌词 lrc（可为空）和歌词来源（apple、directory、http），
+// 来源写入 tag-profile 中 lyrics-source 映射的标签。两者通过 WriteTags 一次写入
+func LyricsTags(lrc, source string) *mp4tag.MP4Tags {
+	t := &mp4tag.MP4Tags{Custom: map[string]string{}, Lyrics: lrc}
+	currentProfile().apply(t, map[string]string{"lyrics-source": source})
+	return t
+}
 
-	// Get quality string for metadata embedding
-	qualityString := getQualityString(meta.Data[0].Relationships.Tracks.Data[index].Attributes.AudioTraits)
+// BuildMP4Tags 根据目录元数据生成曲目的标签，trackNum 从 1 开始
+// 文本字段按当前标签方案（tag-profile）映射；quality 为空时按下载的变体或音频特性推断；
+// lyricsSource 为歌词来源（apple、directory、http），没有歌词时为空
+func BuildMP4Tags(trackPath, lrc, lyricsSource string, meta *structs.AutoGenerated, trackNum, trackTotal int, quality string) *mp4tag.MP4Tags {
+	index := trackNum - 1
+	track := meta.Data[0].Relationships.Tracks.Data[index]
+	profile := currentProfile()
+
+	values := map[string]string{
+		"title":         track.Attributes.Name,
+		"title-sort":    track.Attributes.Name,
+		"artist":        track.Attributes.ArtistName,
+		"artist-sort":   track.Attributes.ArtistName,
+		"composer":      track.Attributes.ComposerName,
+		"genre":         profile.joinGenres(track.Attributes.GenreNames),
+		"date":          meta.Data[0].Attributes.ReleaseDate,
+		"release-date":  track.Attributes.ReleaseDate,
+		"copyright":     meta.Data[0].Attributes.Copyright,
+		"label":         meta.Data[0].Attributes.RecordLabel,
+		"upc":           meta.Data[0].Attributes.Upc,
+		"isrc":          track.Attributes.Isrc,
+		"performer":     track.Attributes.ArtistName,
+		"quality":       quality,
+		"lyrics-source": lyricsSource,
+	}
+	values["composer-sort"] = values["composer"]
+	if values["quality"] == "" {
+		values["quality"] = getQualityString(track.Attributes.AudioTraits)
+	}
 
 	t := &mp4tag.MP4Tags{
-		Title:      meta.Data[0].Relationships.Tracks.Data[index].Attributes.Name,
-		TitleSort:  meta.Data[0].Relationships.Tracks.Data[index].Attributes.Name,
-		Artist:     meta.Data[0].Relationships.Tracks.Data[index].Attributes.ArtistName,
-		ArtistSort: meta.Data[0].Relationships.Tracks.Data[index].Attributes.ArtistName,
 		Custom: map[string]string{
-			"PERFORMER":   meta.Data[0].Relationships.Tracks.Data[index].Attributes.ArtistName,
-			"RELEASETIME": meta.Data[0].Relationships.Tracks.Data[index].Attributes.ReleaseDate,
-			"ISRC":        meta.Data[0].Relationships.Tracks.Data[index].Attributes.Isrc,
-			"LABEL":       meta.Data[0].Attributes.RecordLabel,
-			"UPC":         meta.Data[0].Attributes.Upc,
-			"QUALITY":     qualityString, // Add quality tag to metadata
+			// Apple Music 曲目ID，曲库索引据此识别已下载曲目（与文件路径无关），不受标签方案影响
+			"APPLE_TRACK_ID": track.ID,
 		},
-		Composer:     meta.Data[0].Relationships.Tracks.Data[index].Attributes.ComposerName,
-		ComposerSort: meta.Data[0].Relationships.Tracks.Data[index].Attributes.ComposerName,
-		Date:         meta.Data[0].Attributes.ReleaseDate,
-		CustomGenre:  meta.Data[0].Relationships.Tracks.Data[index].Attributes.GenreNames[0],
-		Copyright:    meta.Data[0].Attributes.Copyright,
-		Publisher:    meta.Data[0].Attributes.RecordLabel,
-		Lyrics:       lrc,
+		Lyrics: lrc,
 	}
 
-	if meta.Data[0].Attributes.EditorialNotes != nil && meta.Data[0].Attributes.EditorialNotes.Standard != "" {
-		reHTML := regexp.MustCompile("<[^>]*>")
-		textWithoutHTML := reHTML.ReplaceAllString(meta.Data[0].Attributes.EditorialNotes.Standard, "")
-		reNewlines := regexp.MustCompile(`\n{2,}`)
-		cleanComment := reNewlines.ReplaceAllString(textWithoutHTML, "\n")
-		t.Comment = strings.TrimSpace(cleanComment)
+	if v, ok := streamVariants.Load(trackPath); ok {
+		variant := v.(StreamVariant)
+		if quality == "" {
+			values["quality"] = variant.Quality
+		}
+		values["quality-variant"] = fmt.Sprintf("%s (%s)", variant.Tier, variant.Detail)
+	}
+
+	if meta.Data[0].Attributes.EditorialNotes != nil {
+		values["comment"] = cleanEditorialNotes(meta.Data[0].Attributes.EditorialNotes.Standard)
 	}
 
 	if !strings.Contains(meta.Data[0].ID, "pl.") {
@@ -235,15 +277,18 @@ func WriteMP4Tags(trackPath, lrc string, meta *structs.AutoGenerated, trackNum,
 	}
 
 	if len(meta.Data[0].Relationships.Artists.Data) > 0 {
-		if len(meta.Data[0].Relationships.Tracks.Data[index].Relationships.Artists.Data) > 0 {
-			artistID, err := strconv.ParseUint(meta.Data[0].Relationships.Tracks.Data[index].Relationships.Artists.Data[0].ID, 10, 32)
+		if len(track.Relationships.Artists.Data) > 0 {
+			artistID, err := strconv.ParseUint(track.Relationships.Artists.Data[0].ID, 10, 32)
 			if err == nil && artistID <= math.MaxInt32 {
 				t.ItunesArtistID = int32(artistID)
 			}
 		}
 	}
 
+	// 播放列表（不使用歌曲信息）时专辑名为播放列表名，不使用本地化排序名
+	playlistAlbum := false
 	if strings.Contains(meta.Data[0].ID, "pl.") && !core.Config.UseSongInfoForPlaylist {
+		playlistAlbum = true
 		t.DiscNumber = 1
 		t.DiscTotal = 1
 		// 安全转换，防止溢出
@@ -254,13 +299,10 @@ func WriteMP4Tags(trackPath, lrc string, meta *structs.AutoGenerated, trackNum,
 			t.TrackTotal = int16(trackTotal)
 		}
 		// 专辑名称不再添加音质标签，保持原始专辑名称
-		albumName := utils.StripCodecSuffix(meta.Data[0].Attributes.Name)
-		t.Album = albumName
-		t.AlbumSort = albumName
-		t.AlbumArtist = meta.Data[0].Attributes.ArtistName
-		t.AlbumArtistSort = meta.Data[0].Attributes.ArtistName
-	} else if strings.Contains(meta.Data[0].ID, "pl.") && core.Config.UseSongInfoForPlaylist {
-		discNum := meta.Data[0].Relationships.Tracks.Data[index].Attributes.DiscNumber
+		values["album"] = utils.StripCodecSuffix(meta.Data[0].Attributes.Name)
+		values["album-artist"], _ = ResolveAlbumArtist(meta)
+	} else {
+		discNum := track.Attributes.DiscNumber
 		if discNum <= math.MaxInt16 {
 			t.DiscNumber = int16(discNum)
 		}
@@ -268,7 +310,7 @@ func WriteMP4Tags(trackPath, lrc string, meta *structs.AutoGenerated, trackNum,
 		if discTotal <= math.MaxInt16 {
 			t.DiscTotal = int16(discTotal)
 		}
-		trackNumber := meta.Data[0].Relationships.Tracks.Data[index].Attributes.TrackNumber
+		trackNumber := track.Attributes.TrackNumber
 		if trackNumber <= math.MaxInt16 {
 			t.TrackNumber = int16(trackNumber)
 		}
@@ -276,51 +318,55 @@ func WriteMP4Tags(trackPath, lrc string, meta *structs.AutoGenerated, trackNum,
 			t.TrackTotal = int16(trackTotal)
 		}
 		// 专辑名称不再添加音质标签，保持原始专辑名称
-		albumName := utils.StripCodecSuffix(meta.Data[0].Relationships.Tracks.Data[index].Attributes.AlbumName)
-		t.Album = albumName
-		t.AlbumSort = albumName
-		t.AlbumArtist = meta.Data[0].Relationships.Tracks.Data[index].Relationships.Albums.Data[0].Attributes.ArtistName
-		t.AlbumArtistSort = meta.Data[0].Relationships.Tracks.Data[index].Relationships.Albums.Data[0].Attributes.ArtistName
-	} else {
-		discNum := meta.Data[0].Relationships.Tracks.Data[index].Attributes.DiscNumber
-		if discNum <= math.MaxInt16 {
-			t.DiscNumber = int16(discNum)
-		}
-		discTotal := meta.Data[0].Relationships.Tracks.Data[trackTotal-1].Attributes.DiscNumber
-		if discTotal <= math.MaxInt16 {
-			t.DiscTotal = int16(discTotal)
-		}
-		trackNumber := meta.Data[0].Relationships.Tracks.Data[index].Attributes.TrackNumber
-		if trackNumber <= math.MaxInt16 {
-			t.TrackNumber = int16(trackNumber)
+		values["album"] = utils.StripCodecSuffix(track.Attributes.AlbumName)
+		if strings.Contains(meta.Data[0].ID, "pl.") {
+			if len(track.Relationships.Albums.Data) > 0 {
+				values["album-artist"] = track.Relationships.Albums.Data[0].Attributes.ArtistName
+			}
+		} else {
+			// 与歌手文件夹使用同一解析结果（合辑、主歌手策略、别名）
+			values["album-artist"], _ = ResolveAlbumArtist(meta)
 		}
-		if trackTotal <= math.MaxInt16 {
-			t.TrackTotal = int16(trackTotal)
+	}
+	values["album-sort"] = values["album"]
+	values["album-artist-sort"] = values["album-artist"]
+
+	// 排序标签优先使用 sort-language 的本地化名称
+	if names, ok := lookupSortNames(track.ID); ok && profile.sortLanguage != "" {
+		setIfNotEmpty(values, "title-sort", names.Title)
+		setIfNotEmpty(values, "artist-sort", names.Artist)
+		setIfNotEmpty(values, "composer-sort", names.Composer)
+		if !playlistAlbum {
+			setIfNotEmpty(values, "album-sort", names.Album)
+			setIfNotEmpty(values, "album-artist-sort", names.AlbumArtist)
 		}
-		// 专辑名称不再添加音质标签，保持原始专辑名称
-		albumName := utils.StripCodecSuffix(meta.Data[0].Relationships.Tracks.Data[index].Attributes.AlbumName)
-		t.Album = albumName
-		t.AlbumSort = albumName
-		t.AlbumArtist = meta.Data[0].Attributes.ArtistName
-		t.AlbumArtistSort = meta.Data[0].Attributes.ArtistName
 	}
 
-	if meta.Data[0].Relationships.Tracks.Data[index].Attributes.ContentRating == "explicit" {
+	profile.apply(t, values)
+
+	if track.Attributes.ContentRating == "explicit" {
 		t.ItunesAdvisory = mp4tag.ItunesAdvisoryExplicit
-	} else if meta.Data[0].Relationships.Tracks.Data[index].Attributes.ContentRating == "clean" {
+	} else if track.Attributes.ContentRating == "clean" {
 		t.ItunesAdvisory = mp4tag.ItunesAdvisoryClean
 	} else {
 		t.ItunesAdvisory = mp4tag.ItunesAdvisoryNone
 	}
+	return t
+}
 
-	mp4, err := mp4tag.Open(trackPath)
-	if err != nil {
-		return err
-	}
-	defer mp4.Close()
-	err = mp4.Write(t, []string{})
-	if err != nil {
-		return err
+var (
+	reHTMLTag   = regexp.MustCompile("<[^>]*>")
+	reBlankLine = regexp.MustCompile(`\n{2,}`)
+)
+
+// cleanEditorialNotes 去掉编辑推荐中的 HTML 标签和多余空行
+func cleanEditorialNotes(notes string) string {
+	text := reHTMLTag.ReplaceAllString(notes, "")
+	return strings.TrimSpace(reBlankLine.ReplaceAllString(text, "\n"))
+}
+
+func setIfNotEmpty(values map[string]string, key, v string) {
+	if v != "" {
+		values[key] = v
 	}
-	return nil
 }
@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"main/internal/progress"
+)
+
+// Listener 根据专辑开始/结束和曲目结果发送 album-done、album-failed 通知，并检查令牌失效的错误
+type Listener struct {
+	mu     sync.Mutex
+	lookup func(index int) (progress.TrackInfo, bool)
+
+	started      time.Time
+	tracks       int
+	completed    int
+	skipped      int
+	failed       int
+	failedTracks []string
+}
+
+// NewListener 创建通知监听器，lookup 根据批次内索引返回曲目编号和名称
+func NewListener(lookup func(index int) (progress.TrackInfo, bool)) *Listener {
+	return &Listener{lookup: lookup}
+}
+
+// OnProgress 实现 progress.ProgressListener
+func (l *Listener) OnProgress(event progress.ProgressEvent) {
+	switch event.Stage {
+	case progress.StageAlbumStart:
+		l.mu.Lock()
+		l.started = time.Now()
+		l.tracks, _ = event.Metadata["tracks"].(int)
+		l.completed, l.skipped, l.failed = 0, 0, 0
+		l.failedTracks = nil
+		l.mu.Unlock()
+	case progress.StageAlbumEnd:
+		l.mu.Lock()
+		ev := Event{
+			Type:         AlbumDone,
+			Tracks:       l.tracks,
+			Completed:    l.completed,
+			Skipped:      l.skipped,
+			Failed:       l.failed,
+			FailedTracks: l.failedTracks,
+			Seconds:      time.Since(l.started).Seconds(),
+		}
+		l.mu.Unlock()
+		ev.AlbumID, _ = event.Metadata["album_id"].(string)
+		ev.Album, _ = event.Metadata["album"].(string)
+		if event.Error != nil {
+			ev.Error = event.Error.Error()
+			CheckError(event.Error)
+		}
+		if ev.Error != "" || ev.Failed > 0 {
+			ev.Type = AlbumFailed
+		}
+		Send(ev)
+	case "skipped":
+		l.mu.Lock()
+		l.skipped++
+		l.mu.Unlock()
+	}
+}
+
+// OnComplete 实现 progress.ProgressListener
+func (l *Listener) OnComplete(trackIndex int) {
+	l.mu.Lock()
+	l.completed++
+	l.mu.Unlock()
+}
+
+// OnError 实现 progress.ProgressListener
+func (l *Listener) OnError(trackIndex int, err error) {
+	name := fmt.Sprintf("#%d", trackIndex+1)
+	if l.lookup != nil {
+		if info, ok := l.lookup(trackIndex); ok {
+			name = fmt.Sprintf("%d. %s", info.Num, info.Name)
+		}
+	}
+	if err != nil {
+		name += ": " + err.Error()
+		CheckError(err)
+	}
+	l.mu.Lock()
+	l.failed++
+	l.failedTracks = append(l.failedTracks, name)
+	l.mu.Unlock()
+}
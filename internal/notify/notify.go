@@ -0,0 +1,393 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"main/internal/core"
+	"main/internal/logger"
+	"main/utils/structs"
+)
+
+// 通知事件类型
+const (
+	AlbumDone    = "album-done"    // 专辑下载完成且没有失败的曲目
+	AlbumFailed  = "album-failed"  // 专辑下载出错或有曲目失败
+	JobDone      = "job-done"      // 整个任务结束（命令行运行结束，或 serve 模式的一个任务结束）
+	TokenExpired = "token-expired" // 开发者 token 获取失败或 API 返回 401、media-user-token 失效
+)
+
+// EventTypes 可在 events 中使用的事件类型
+var EventTypes = []string{AlbumDone, AlbumFailed, JobDone, TokenExpired}
+
+// sendTimeout 单个通知的超时时间
+const sendTimeout = 30 * time.Second
+
+// defaultTemplate 未设置 template 时的消息内容
+const defaultTemplate = `{{.Title}}{{if .Error}}
+错误: {{.Error}}{{end}}{{range .FailedTracks}}
+失败: {{.}}{{end}}`
+
+// Event 一个通知事件，字段可在模板中使用（如 {{.Album}}），webhook 默认发送其 JSON
+type Event struct {
+	Type         string    `json:"event"`
+	Time         time.Time `json:"time"`
+	Title        string    `json:"title"` // 一行说明，如 "✅ 专辑下载完成: xxx (12/12)"
+	AlbumID      string    `json:"album_id,omitempty"`
+	Album        string    `json:"album,omitempty"`
+	URL          string    `json:"url,omitempty"`
+	Tracks       int       `json:"tracks,omitempty"` // 专辑曲目数，job-done 为处理的曲目数
+	Completed    int       `json:"completed"`
+	Skipped      int       `json:"skipped,omitempty"` // 已存在或被跳过的曲目
+	Failed       int       `json:"failed"`
+	Warnings     int       `json:"warnings,omitempty"`
+	FailedTracks []string  `json:"failed_tracks,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Seconds      float64   `json:"seconds,omitempty"` // 耗时
+}
+
+// title 生成事件的一行说明
+func (e *Event) title() string {
+	name := e.Album
+	if name == "" {
+		name = e.URL
+	}
+	switch e.Type {
+	case AlbumDone:
+		if e.Skipped > 0 {
+			return fmt.Sprintf("✅ 专辑下载完成: %s (下载 %d, 跳过 %d)", name, e.Completed, e.Skipped)
+		}
+		return fmt.Sprintf("✅ 专辑下载完成: %s (%d/%d)", name, e.Completed, e.Tracks)
+	case AlbumFailed:
+		return fmt.Sprintf("❌ 专辑下载失败: %s (成功 %d, 失败 %d)", name, e.Completed, e.Failed)
+	case JobDone:
+		t := fmt.Sprintf("📦 任务结束: 已完成 %d/%d | 警告: %d | 错误: %d", e.Completed, e.Tracks, e.Warnings, e.Failed)
+		if name != "" {
+			t += " - " + name
+		}
+		return t
+	case TokenExpired:
+		return "🔑 令牌失效，请更新配置中的 token"
+	}
+	return e.Type
+}
+
+// target 一个已校验的通知目标
+type target struct {
+	cfg      structs.NotificationConfig
+	events   map[string]bool // 为空时接收全部事件
+	template *template.Template
+	subject  *template.Template
+}
+
+var (
+	targets       []*target
+	pending       sync.WaitGroup
+	tokenNotified atomic.Bool // 令牌失效每次运行只通知一次
+)
+
+// Init 解析并校验配置中的 notifications
+func Init() error {
+	var parsed []*target
+	for i, cfg := range core.Config.Notifications {
+		t, err := newTarget(cfg)
+		if err != nil {
+			return fmt.Errorf("notifications[%d]: %v", i, err)
+		}
+		parsed = append(parsed, t)
+	}
+	targets = parsed
+	return nil
+}
+
+func newTarget(cfg structs.NotificationConfig) (*target, error) {
+	switch cfg.Type {
+	case "webhook":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("webhook 需要设置 url")
+		}
+	case "command":
+		if cfg.Command == "" {
+			return nil, fmt.Errorf("command 需要设置 command")
+		}
+	case "email":
+		if cfg.SMTPHost == "" || cfg.From == "" || len(cfg.To) == 0 {
+			return nil, fmt.Errorf("email 需要设置 smtp-host、from 和 to")
+		}
+	default:
+		return nil, fmt.Errorf("无效的 type: %q（可选：webhook, command, email）", cfg.Type)
+	}
+
+	t := &target{cfg: cfg, events: make(map[string]bool)}
+	for _, ev := range cfg.Events {
+		known := false
+		for _, name := range EventTypes {
+			known = known || ev == name
+		}
+		if !known {
+			return nil, fmt.Errorf("无效的事件: %q（可选：%s）", ev, strings.Join(EventTypes, ", "))
+		}
+		t.events[ev] = true
+	}
+
+	text := cfg.Template
+	if text == "" {
+		text = defaultTemplate
+	}
+	var err error
+	if t.template, err = template.New("template").Funcs(templateFuncs).Parse(text); err != nil {
+		return nil, fmt.Errorf("template 无效: %v", err)
+	}
+	subject := cfg.Subject
+	if subject == "" {
+		subject = "{{.Title}}"
+	}
+	if t.subject, err = template.New("subject").Funcs(templateFuncs).Parse(subject); err != nil {
+		return nil, fmt.Errorf("subject 无效: %v", err)
+	}
+	return t, nil
+}
+
+// Enabled 是否配置了通知
+func Enabled() bool {
+	return len(targets) > 0
+}
+
+// Send 向订阅了该事件的目标发送通知，在后台进行，不阻塞下载
+func Send(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	if ev.Title == "" {
+		ev.Title = ev.title()
+	}
+	for _, t := range targets {
+		if len(t.events) > 0 && !t.events[ev.Type] {
+			continue
+		}
+		pending.Add(1)
+		go func(t *target) {
+			defer pending.Done()
+			if err := t.send(ev); err != nil {
+				logger.Warn("发送 %s 通知失败 (%s): %v", ev.Type, t.cfg.Type, err)
+			}
+		}(t)
+	}
+}
+
+// Wait 等待尚未发送完的通知，程序退出前调用
+func Wait() {
+	pending.Wait()
+}
+
+// CheckError 错误表明令牌失效时发送 token-expired 通知（每次运行只发送一次）
+func CheckError(err error) {
+	if err == nil || !isTokenError(err) {
+		return
+	}
+	TokenFailure(err)
+}
+
+// TokenFailure 发送 token-expired 通知（每次运行只发送一次）
+func TokenFailure(err error) {
+	if !tokenNotified.CompareAndSwap(false, true) {
+		return
+	}
+	Send(Event{Type: TokenExpired, Error: err.Error()})
+}
+
+// isTokenError 判断错误是否由令牌失效引起：API 返回 401，或 media-user-token 失效
+func isTokenError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "401 Unauthorized") || strings.Contains(msg, "token may be wrong or expired")
+}
+
+// render 用模板生成文本
+func render(tmpl *template.Template, ev Event) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, ev); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (t *target) send(ev Event) error {
+	message, err := render(t.template, ev)
+	if err != nil {
+		return fmt.Errorf("生成消息失败: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	switch t.cfg.Type {
+	case "webhook":
+		return t.sendWebhook(ctx, ev, message)
+	case "command":
+		return t.runCommand(ctx, ev, message)
+	case "email":
+		subject, err := render(t.subject, ev)
+		if err != nil {
+			return fmt.Errorf("生成主题失败: %v", err)
+		}
+		return t.sendMail(subject, message)
+	}
+	return nil
+}
+
+// templateFuncs 模板中可用的函数：json 将值转换为 JSON 字面量（字符串带引号并转义），
+// 用于在 JSON 模板中插入名称、错误信息等可能包含引号或换行的字段，如 {"text": {{json .Title}}}
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(buf.String(), "\n"), nil
+	},
+}
+
+// sendWebhook POST 事件 JSON，设置了 template 时发送模板内容
+// 模板内容不是有效的 JSON 时按纯文本发送，不声明为 application/json
+func (t *target) sendWebhook(ctx context.Context, ev Event, message string) error {
+	body := []byte(message)
+	contentType := "application/json"
+	if t.cfg.Template == "" {
+		var err error
+		if body, err = json.Marshal(ev); err != nil {
+			return err
+		}
+	} else if !json.Valid(body) {
+		contentType = "text/plain; charset=utf-8"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range t.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("服务器返回 %s", resp.Status)
+	}
+	return nil
+}
+
+// runCommand 通过 shell 执行命令，事件信息在 AMD_* 环境变量中
+func (t *target) runCommand(ctx context.Context, ev Event, message string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", t.cfg.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", t.cfg.Command)
+	}
+	cmd.Env = append(os.Environ(), commandEnv(ev, message)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// commandEnv 命令可使用的环境变量
+func commandEnv(ev Event, message string) []string {
+	data, _ := json.Marshal(ev)
+	return []string{
+		"AMD_EVENT=" + ev.Type,
+		"AMD_TITLE=" + ev.Title,
+		"AMD_MESSAGE=" + message,
+		"AMD_ALBUM=" + ev.Album,
+		"AMD_ALBUM_ID=" + ev.AlbumID,
+		"AMD_URL=" + ev.URL,
+		"AMD_TRACKS=" + strconv.Itoa(ev.Tracks),
+		"AMD_COMPLETED=" + strconv.Itoa(ev.Completed),
+		"AMD_SKIPPED=" + strconv.Itoa(ev.Skipped),
+		"AMD_FAILED=" + strconv.Itoa(ev.Failed),
+		"AMD_WARNINGS=" + strconv.Itoa(ev.Warnings),
+		"AMD_FAILED_TRACKS=" + strings.Join(ev.FailedTracks, "\n"),
+		"AMD_ERROR=" + ev.Error,
+		"AMD_SECONDS=" + strconv.FormatFloat(ev.Seconds, 'f', 0, 64),
+		"AMD_JSON=" + string(data),
+	}
+}
+
+// sendMail 通过 SMTP 发送纯文本邮件，服务器支持时使用 STARTTLS
+func (t *target) sendMail(subject, message string) error {
+	host, _, err := net.SplitHostPort(t.cfg.SMTPHost)
+	if err != nil {
+		return fmt.Errorf("smtp-host 需要包含端口: %v", err)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", t.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(t.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(message, "\n", "\r\n"))
+
+	// 与 smtp.SendMail 相同的流程，但连接有超时，避免服务器无响应时退出前一直等待
+	conn, err := net.DialTimeout("tcp", t.cfg.SMTPHost, sendTimeout)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(sendTimeout))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if t.cfg.SMTPUser != "" {
+		if err := c.Auth(smtp.PlainAuth("", t.cfg.SMTPUser, t.cfg.SMTPPass, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(t.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range t.cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
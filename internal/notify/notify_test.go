@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"main/internal/progress"
+	"main/utils/structs"
+)
+
+func mustTarget(t *testing.T, cfg structs.NotificationConfig) *target {
+	t.Helper()
+	tg, err := newTarget(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tg
+}
+
+func TestNewTargetValidation(t *testing.T) {
+	for _, cfg := range []structs.NotificationConfig{
+		{Type: "pager"},
+		{Type: "webhook"},
+		{Type: "command"},
+		{Type: "email", SMTPHost: "smtp.example.com:587"},
+		{Type: "webhook", URL: "http://x", Events: []string{"album-finished"}},
+		{Type: "webhook", URL: "http://x", Template: "{{.Album"},
+	} {
+		if _, err := newTarget(cfg); err == nil {
+			t.Errorf("newTarget(%+v) succeeded", cfg)
+		}
+	}
+}
+
+func TestWebhookAndFilters(t *testing.T) {
+	bodies := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodies <- r.Header.Get("X-Token") + " " + string(data)
+	}))
+	defer srv.Close()
+
+	targets = []*target{
+		mustTarget(t, structs.NotificationConfig{Type: "webhook", URL: srv.URL, Events: []string{AlbumFailed}, Headers: map[string]string{"X-Token": "t"}}),
+		mustTarget(t, structs.NotificationConfig{Type: "webhook", URL: srv.URL, Events: []string{JobDone}, Template: `{"text": "{{.Completed}}/{{.Tracks}}"}`}),
+	}
+	defer func() { targets = nil }()
+
+	l := NewListener(func(index int) (progress.TrackInfo, bool) {
+		return progress.TrackInfo{Num: index + 1, Name: "Song"}, true
+	})
+	notifier := progress.NewNotifier()
+	notifier.AddListener(l)
+	notifier.NotifyAlbumStart("1", "Good", 1)
+	notifier.NotifyComplete(0)
+	notifier.NotifyAlbumEnd("1", "Good", nil) // album-done 没有订阅
+	notifier.NotifyAlbumStart("2", "Bad", 2)
+	notifier.NotifyComplete(0)
+	notifier.NotifyError(1, errors.New("boom"))
+	notifier.NotifyAlbumEnd("2", "Bad", nil)
+	Send(Event{Type: JobDone, Tracks: 3, Completed: 2})
+	Wait()
+	close(bodies)
+
+	var got []string
+	for b := range bodies {
+		got = append(got, b)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d requests: %q", len(got), got)
+	}
+	for _, b := range got {
+		if strings.HasPrefix(b, " ") {
+			if b != ` {"text": "2/3"}` {
+				t.Errorf("templated body = %q", b)
+			}
+			continue
+		}
+		var ev Event
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(b, "t ")), &ev); err != nil {
+			t.Fatalf("body %q: %v", b, err)
+		}
+		if ev.Type != AlbumFailed || ev.Album != "Bad" || ev.Completed != 1 || ev.Failed != 1 || ev.FailedTracks[0] != "2. Song: boom" {
+			t.Errorf("event = %+v", ev)
+		}
+	}
+}
+
+func TestWebhookTemplateJSON(t *testing.T) {
+	type request struct{ contentType, body string }
+	requests := make(chan request, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		requests <- request{r.Header.Get("Content-Type"), string(data)}
+	}))
+	defer srv.Close()
+
+	ev := Event{Type: AlbumFailed, Album: `Say "Hello" & <Bye>`, Error: "line 1\nline 2"}
+	for _, tmpl := range []string{`{"text": {{json .Album}}, "error": {{json .Error}}}`, `{{.Album}} 失败`} {
+		if err := mustTarget(t, structs.NotificationConfig{Type: "webhook", URL: srv.URL, Template: tmpl}).send(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := <-requests
+	var payload map[string]string
+	if err := json.Unmarshal([]byte(got.body), &payload); err != nil {
+		t.Fatalf("body %q: %v", got.body, err)
+	}
+	if got.contentType != "application/json" || payload["text"] != ev.Album || payload["error"] != ev.Error {
+		t.Errorf("json template: %q %q", got.contentType, got.body)
+	}
+	// 模板内容不是 JSON 时按纯文本发送
+	if got = <-requests; !strings.HasPrefix(got.contentType, "text/plain") || got.body != ev.Album+" 失败" {
+		t.Errorf("text template: %q %q", got.contentType, got.body)
+	}
+}
+
+func TestCommandEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "out.txt")
+	tg := mustTarget(t, structs.NotificationConfig{
+		Type:     "command",
+		Command:  `printf '%s|%s|%s' "$AMD_EVENT" "$AMD_ALBUM" "$AMD_MESSAGE" > "` + out + `"`,
+		Template: "{{.Album}} 完成",
+	})
+	if err := tg.send(Event{Type: AlbumDone, Album: "专辑"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "album-done|专辑|专辑 完成" {
+		t.Errorf("output = %q", data)
+	}
+}
+
+func TestTokenError(t *testing.T) {
+	for msg, want := range map[string]bool{
+		"401 Unauthorized":                         true,
+		"media-user-token may be wrong or expired": true,
+		"404 Not Found":                            false,
+		"album 1401234 failed":                     false,
+	} {
+		if got := isTokenError(errors.New(msg)); got != want {
+			t.Errorf("isTokenError(%q) = %v", msg, got)
+		}
+	}
+}
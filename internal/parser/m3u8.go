@@ -22,77 +22,6 @@ import (
 	"github.com/olekukonko/tablewriter"
 )
 
-// ExtractMvAudio extracts the best audio stream URL from a music video's master m3u8
-func ExtractMvAudio(c string) (string, error) {
-	MediaUrl, err := url.Parse(c)
-	if err != nil {
-		return "", err
-	}
-	resp, err := http.Get(c)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return "", errors.New(resp.Status)
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-	audioString := string(body)
-	from, listType, err := m3u8.DecodeFrom(strings.NewReader(audioString), true)
-	if err != nil || listType != m3u8.MASTER {
-		return "", errors.New("m3u8 not of media type")
-	}
-	audio := from.(*m3u8.MasterPlaylist)
-
-	var audioPriority = []string{"audio-atmos", "audio-ac3", "audio-stereo-256"}
-	if *core.Mv_audio_type == "ac3" {
-		audioPriority = []string{"audio-ac3", "audio-stereo-256"}
-	} else if *core.Mv_audio_type == "aac" {
-		audioPriority = []string{"audio-stereo-256"}
-	}
-
-	re := regexp.MustCompile(`_gr(\d+)_`)
-
-	type AudioStream struct {
-		URL     string
-		Rank    int
-		GroupID string
-	}
-	var audioStreams []AudioStream
-
-	for _, variant := range audio.Variants {
-		for _, audiov := range variant.Alternatives {
-			if audiov.URI != "" {
-				for _, priority := range audioPriority {
-					if audiov.GroupId == priority {
-						matches := re.FindStringSubmatch(audiov.URI)
-						if len(matches) == 2 {
-							var rank int
-							fmt.Sscanf(matches[1], "%d", &rank)
-							streamUrl, _ := MediaUrl.Parse(audiov.URI)
-							audioStreams = append(audioStreams, AudioStream{
-								URL:     streamUrl.String(),
-								Rank:    rank,
-								GroupID: audiov.GroupId,
-							})
-						}
-					}
-				}
-			}
-		}
-	}
-	if len(audioStreams) == 0 {
-		return "", errors.New("no suitable audio stream found")
-	}
-	sort.Slice(audioStreams, func(i, j int) bool {
-		return audioStreams[i].Rank > audioStreams[j].Rank
-	})
-	return audioStreams[0].URL, nil
-}
-
 // CheckM3u8 retrieves the m3u8 URL from a connected device
 func CheckM3u8(b string, f string, account *structs.Account) (string, error) {
 	var EnhancedHls string
@@ -299,6 +228,10 @@ func ExtractMedia(b string, more_mode bool) (string, string, string, error) {
 		}
 	}
 	if streamUrl == nil {
+		if core.Config.QualityStrict {
+			// 严格模式：没有符合要求的变体时直接失败，不降级到其他音质
+			return "", "", qualityForDisplay, errors.New("no variant matches the requested quality (strict mode)")
+		}
 		if len(master.Variants) > 0 {
 			streamUrl, _ = masterUrl.Parse(master.Variants[0].URI)
 		} else {
@@ -0,0 +1,425 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"main/internal/core"
+
+	"github.com/grafov/m3u8"
+)
+
+// VideoVariant MV 主播放列表中的一个视频变体
+type VideoVariant struct {
+	URL       string
+	Codec     string // hevc / avc
+	Codecs    string // 原始 CODECS，如 hvc1.2.4.L153.B0,ec-3
+	Width     int
+	Height    int
+	Range     string // sdr / hdr10 / hlg / dovi
+	FrameRate float64
+	Bandwidth int // 平均码率 kbps
+}
+
+// Resolution 返回便于阅读的分辨率描述，如 3840x2160 (4K)
+func (v VideoVariant) Resolution() string {
+	var label string
+	switch {
+	case v.Height >= 2160:
+		label = "4K"
+	case v.Height >= 1080:
+		label = "1080P"
+	case v.Height >= 720:
+		label = "720P"
+	case v.Height >= 480:
+		label = "480P"
+	default:
+		label = fmt.Sprintf("%dP", v.Height)
+	}
+	return fmt.Sprintf("%dx%d (%s)", v.Width, v.Height, label)
+}
+
+// RangeName 返回 {Range} 使用的动态范围名称
+func (v VideoVariant) RangeName() string {
+	switch v.Range {
+	case "dovi":
+		return "Dolby Vision"
+	case "hdr10":
+		return "HDR10"
+	case "hlg":
+		return "HLG"
+	default:
+		return "SDR"
+	}
+}
+
+// MVAudioVariant MV 主播放列表中的一个音轨
+type MVAudioVariant struct {
+	URL     string
+	GroupID string // audio-atmos / audio-ac3 / audio-stereo-256
+	Codec   string // atmos / ac3 / aac
+	Rank    int    // URI 中 _grN_ 的数值，越大音质越好
+}
+
+// CodecName 返回 {AudioCodec} 使用的音轨名称
+func (a MVAudioVariant) CodecName() string {
+	switch a.Codec {
+	case "atmos":
+		return "Atmos"
+	case "ac3":
+		return "AC3"
+	default:
+		return "AAC"
+	}
+}
+
+// MVFormats MV 可用的视频和音轨格式
+type MVFormats struct {
+	Video []VideoVariant
+	Audio []MVAudioVariant
+}
+
+// ListMVFormats 获取 MV 主播放列表中的全部视频变体和音轨，均按码率从高到低排列
+func ListMVFormats(masterM3u8 string) (*MVFormats, error) {
+	masterUrl, err := url.Parse(masterM3u8)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.Get(masterM3u8)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseMVFormats(masterUrl, string(body))
+}
+
+var reMvAudioRank = regexp.MustCompile(`_gr(\d+)_`)
+
+func parseMVFormats(masterUrl *url.URL, body string) (*MVFormats, error) {
+	from, listType, err := m3u8.DecodeFrom(strings.NewReader(body), true)
+	if err != nil || listType != m3u8.MASTER {
+		return nil, errors.New("m3u8 not of master type")
+	}
+	master := from.(*m3u8.MasterPlaylist)
+
+	formats := &MVFormats{}
+	seenAudio := make(map[string]bool)
+	for _, variant := range master.Variants {
+		if variant.Iframe {
+			continue
+		}
+		streamUrl, err := masterUrl.Parse(variant.URI)
+		if err != nil {
+			continue
+		}
+		v := VideoVariant{
+			URL:       streamUrl.String(),
+			Codecs:    variant.Codecs,
+			FrameRate: variant.FrameRate,
+			Bandwidth: int(variant.AverageBandwidth) / 1000,
+			Range:     "sdr",
+		}
+		if v.Bandwidth == 0 {
+			v.Bandwidth = int(variant.Bandwidth) / 1000
+		}
+		if w, h, ok := strings.Cut(variant.Resolution, "x"); ok {
+			v.Width, _ = strconv.Atoi(w)
+			v.Height, _ = strconv.Atoi(h)
+		}
+		switch strings.ToUpper(variant.VideoRange) {
+		case "PQ":
+			v.Range = "hdr10"
+		case "HLG":
+			v.Range = "hlg"
+		}
+		for _, codec := range strings.Split(variant.Codecs, ",") {
+			switch codec = strings.TrimSpace(codec); {
+			case strings.HasPrefix(codec, "dvh1"), strings.HasPrefix(codec, "dvhe"):
+				v.Codec, v.Range = "hevc", "dovi"
+			case strings.HasPrefix(codec, "hvc1"), strings.HasPrefix(codec, "hev1"):
+				v.Codec = "hevc"
+			case strings.HasPrefix(codec, "avc1"), strings.HasPrefix(codec, "avc3"):
+				v.Codec = "avc"
+			}
+		}
+		formats.Video = append(formats.Video, v)
+
+		for _, alt := range variant.Alternatives {
+			if alt.URI == "" || !strings.EqualFold(alt.Type, "AUDIO") || seenAudio[alt.URI] {
+				continue
+			}
+			seenAudio[alt.URI] = true
+			audioUrl, err := masterUrl.Parse(alt.URI)
+			if err != nil {
+				continue
+			}
+			a := MVAudioVariant{URL: audioUrl.String(), GroupID: alt.GroupId}
+			switch {
+			case strings.Contains(alt.GroupId, "atmos"):
+				a.Codec = "atmos"
+			case strings.Contains(alt.GroupId, "ac3"):
+				a.Codec = "ac3"
+			case strings.Contains(alt.GroupId, "stereo"):
+				a.Codec = "aac"
+			default:
+				continue
+			}
+			if m := reMvAudioRank.FindStringSubmatch(alt.URI); len(m) == 2 {
+				a.Rank, _ = strconv.Atoi(m[1])
+			}
+			formats.Audio = append(formats.Audio, a)
+		}
+	}
+	sort.SliceStable(formats.Video, func(i, j int) bool {
+		return formats.Video[i].Bandwidth > formats.Video[j].Bandwidth
+	})
+	sort.SliceStable(formats.Audio, func(i, j int) bool {
+		return formats.Audio[i].Rank > formats.Audio[j].Rank
+	})
+	return formats, nil
+}
+
+// mvFormatValues mv-format 中可用的取值及其所属的属性
+var mvFormatValues = map[string]string{
+	"hevc": "codec", "avc": "codec",
+	"sdr": "range", "hdr": "range", "hdr10": "range", "hlg": "range", "dovi": "range",
+	"atmos": "audio", "ac3": "audio", "aac": "audio",
+}
+
+// mvRule mv-format 中的一项：偏好顺序（a>b）、必须条件（单个取值）或数值比较
+type mvRule struct {
+	key   string   // codec / range / audio / height / fps / kbps
+	order []string // 按偏好排列的取值
+	op    string   // 数值比较：<= >= < > =
+	value float64
+}
+
+// MVFormatPref 由 mv-format 表达式解析出的 MV 格式偏好
+type MVFormatPref struct {
+	rules []mvRule
+}
+
+var reMvCompare = regexp.MustCompile(`^(height|fps|kbps)?\s*(<=|>=|<|>|=)\s*(\d+(?:\.\d+)?)p?$`)
+
+// ParseMVFormat 解析 mv-format 表达式，如 "hevc>avc, sdr, <=2160"
+// 逗号分隔的每一项为：a>b>c（偏好顺序，未列出的取值排在最后）、单个取值（必须满足）、
+// 或数值比较（<=2160 比较分辨率高度，fps<=30 比较帧率，kbps<=20000 比较码率）
+func ParseMVFormat(expr string) (*MVFormatPref, error) {
+	pref := &MVFormatPref{}
+	for _, item := range strings.Split(expr, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		if m := reMvCompare.FindStringSubmatch(item); m != nil {
+			key := m[1]
+			if key == "" {
+				key = "height"
+			}
+			value, _ := strconv.ParseFloat(m[3], 64)
+			pref.rules = append(pref.rules, mvRule{key: key, op: m[2], value: value})
+			continue
+		}
+		rule := mvRule{}
+		for _, value := range strings.Split(item, ">") {
+			value = strings.TrimSpace(value)
+			key, ok := mvFormatValues[value]
+			if !ok {
+				return nil, fmt.Errorf("mv-format 中无法识别 '%s'（可选：hevc, avc, sdr, hdr, hdr10, hlg, dovi, atmos, ac3, aac 或 <=2160、fps<=30、kbps<=20000）", value)
+			}
+			if rule.key != "" && rule.key != key {
+				return nil, fmt.Errorf("mv-format 的 '%s' 混用了不同类型的取值", item)
+			}
+			rule.key = key
+			rule.order = append(rule.order, value)
+		}
+		pref.rules = append(pref.rules, rule)
+	}
+	return pref, nil
+}
+
+var (
+	activeMVFormat   *MVFormatPref
+	activeMVFormatMu sync.RWMutex
+)
+
+// InitMVFormat 解析 mv-format（--mv-format 优先），启动时调用以便尽早报告表达式错误
+func InitMVFormat() error {
+	p, err := ParseMVFormat(core.MVFormat)
+	if err != nil {
+		return err
+	}
+	activeMVFormatMu.Lock()
+	activeMVFormat = p
+	activeMVFormatMu.Unlock()
+	return nil
+}
+
+// CurrentMVFormat 返回当前的 MV 格式偏好，未初始化时为空表达式（沿用 mv-max/mv-audio-type）
+func CurrentMVFormat() *MVFormatPref {
+	activeMVFormatMu.RLock()
+	p := activeMVFormat
+	activeMVFormatMu.RUnlock()
+	if p == nil {
+		p = &MVFormatPref{}
+	}
+	return p
+}
+
+func (r mvRule) required() bool {
+	return r.op == "" && len(r.order) == 1
+}
+
+// rank 返回取值在偏好顺序中的位置，未列出时排在最后
+func (r mvRule) rank(value string) int {
+	for i, v := range r.order {
+		if v == value || (v == "hdr" && value != "sdr") {
+			return i
+		}
+	}
+	return len(r.order)
+}
+
+func (r mvRule) compare(n float64) bool {
+	switch r.op {
+	case "<=":
+		return n <= r.value
+	case ">=":
+		return n >= r.value
+	case "<":
+		return n < r.value
+	case ">":
+		return n > r.value
+	default:
+		return n == r.value
+	}
+}
+
+func (v VideoVariant) attr(key string) string {
+	if key == "codec" {
+		return v.Codec
+	}
+	return v.Range
+}
+
+func (v VideoVariant) number(key string) float64 {
+	switch key {
+	case "fps":
+		return v.FrameRate
+	case "kbps":
+		return float64(v.Bandwidth)
+	default:
+		return float64(v.Height)
+	}
+}
+
+// hasRules 是否包含指定属性的规则
+func (p *MVFormatPref) hasRules(keys ...string) bool {
+	for _, r := range p.rules {
+		for _, k := range keys {
+			if r.key == k {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SelectVideo 按偏好选择视频变体
+// 表达式中没有分辨率条件时沿用 mv-max 作为高度上限；表达式为空且没有满足 mv-max 的变体时回退到码率最高的变体
+func (p *MVFormatPref) SelectVideo(variants []VideoVariant) (VideoVariant, error) {
+	var candidates []VideoVariant
+	for _, v := range variants {
+		ok := true
+		for _, r := range p.rules {
+			switch {
+			case r.key == "audio":
+			case r.op != "":
+				ok = ok && r.compare(v.number(r.key))
+			case r.required():
+				ok = ok && r.rank(v.attr(r.key)) == 0
+			}
+		}
+		if !p.hasRules("height") && *core.Mv_max > 0 && v.Height > *core.Mv_max {
+			ok = false
+		}
+		if ok {
+			candidates = append(candidates, v)
+		}
+	}
+	if len(candidates) == 0 {
+		if !p.hasRules("codec", "range", "height", "fps", "kbps") && len(variants) > 0 {
+			return variants[0], nil
+		}
+		return VideoVariant{}, errors.New("没有符合 mv-format 的视频格式（可使用 --list-formats 查看可用格式）")
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		for _, r := range p.rules {
+			if r.key != "codec" && r.key != "range" || r.required() {
+				continue
+			}
+			ri, rj := r.rank(candidates[i].attr(r.key)), r.rank(candidates[j].attr(r.key))
+			if ri != rj {
+				return ri < rj
+			}
+		}
+		return candidates[i].Bandwidth > candidates[j].Bandwidth
+	})
+	return candidates[0], nil
+}
+
+// SelectAudio 按偏好选择音轨；表达式中没有音轨条件时按 mv-audio-type 选择
+func (p *MVFormatPref) SelectAudio(audios []MVAudioVariant) (MVAudioVariant, error) {
+	var rule mvRule
+	for _, r := range p.rules {
+		if r.key == "audio" {
+			rule = r
+		}
+	}
+	if rule.key == "" {
+		// 与之前的行为一致：mv-audio-type 决定可接受的音轨，其中取音质最高的
+		switch *core.Mv_audio_type {
+		case "ac3":
+			rule.order = []string{"ac3", "aac"}
+		case "aac":
+			rule.order = []string{"aac"}
+		default:
+			rule.order = []string{"atmos", "ac3", "aac"}
+		}
+		for _, a := range audios {
+			if rule.rank(a.Codec) < len(rule.order) {
+				return a, nil
+			}
+		}
+		return MVAudioVariant{}, errors.New("no suitable audio stream found")
+	}
+
+	var candidates []MVAudioVariant
+	for _, a := range audios {
+		if !rule.required() || rule.rank(a.Codec) == 0 {
+			candidates = append(candidates, a)
+		}
+	}
+	if len(candidates) == 0 {
+		return MVAudioVariant{}, errors.New("没有符合 mv-format 的音轨（可使用 --list-formats 查看可用格式）")
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return rule.rank(candidates[i].Codec) < rule.rank(candidates[j].Codec)
+	})
+	return candidates[0], nil
+}
@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"net/url"
+	"testing"
+
+	"main/internal/core"
+)
+
+const testMVMaster = `#EXTM3U
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio-stereo-256",NAME="Stereo",URI="P1_audio_gr256_stereo.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio-atmos",NAME="Atmos",URI="P1_audio_gr2768_atmos.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=30000000,AVERAGE-BANDWIDTH=25000000,CODECS="dvh1.05.06,ec-3",RESOLUTION=3840x2160,FRAME-RATE=23.976,VIDEO-RANGE=PQ,AUDIO="audio-atmos"
+P1_dovi_3840x2160.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=22000000,AVERAGE-BANDWIDTH=18000000,CODECS="hvc1.2.4.L153.B0,mp4a.40.2",RESOLUTION=3840x2160,FRAME-RATE=23.976,VIDEO-RANGE=SDR,AUDIO="audio-stereo-256"
+P1_hevc_3840x2160.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=9000000,AVERAGE-BANDWIDTH=7000000,CODECS="avc1.640028,mp4a.40.2",RESOLUTION=1920x1080,FRAME-RATE=23.976,VIDEO-RANGE=SDR,AUDIO="audio-stereo-256"
+P1_avc_1920x1080.m3u8
+`
+
+func loadTestFormats(t *testing.T) *MVFormats {
+	t.Helper()
+	base, _ := url.Parse("https://example.com/mv/master.m3u8")
+	formats, err := parseMVFormats(base, testMVMaster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return formats
+}
+
+func useMVDefaults(t *testing.T, maxHeight int, audioType string) {
+	t.Helper()
+	oldMax, oldAudio := core.Mv_max, core.Mv_audio_type
+	core.Mv_max, core.Mv_audio_type = &maxHeight, &audioType
+	t.Cleanup(func() { core.Mv_max, core.Mv_audio_type = oldMax, oldAudio })
+}
+
+func TestParseMVFormats(t *testing.T) {
+	formats := loadTestFormats(t)
+	if len(formats.Video) != 3 || len(formats.Audio) != 2 {
+		t.Fatalf("got %d video / %d audio variants", len(formats.Video), len(formats.Audio))
+	}
+	v := formats.Video[0]
+	if v.Codec != "hevc" || v.Range != "dovi" || v.Height != 2160 || v.Bandwidth != 25000 || v.URL != "https://example.com/mv/P1_dovi_3840x2160.m3u8" {
+		t.Errorf("unexpected first variant: %+v", v)
+	}
+	if formats.Audio[0].Codec != "atmos" || formats.Audio[0].Rank != 2768 {
+		t.Errorf("unexpected first audio: %+v", formats.Audio[0])
+	}
+}
+
+func TestSelectMVFormat(t *testing.T) {
+	formats := loadTestFormats(t)
+	useMVDefaults(t, 1080, "atmos")
+
+	cases := []struct {
+		expr, url string
+	}{
+		{"", "P1_avc_1920x1080.m3u8"},        // 与之前一样受 mv-max 限制
+		{"<=2160", "P1_dovi_3840x2160.m3u8"}, // 表达式中的分辨率条件取代 mv-max
+		{"hevc>avc, sdr, <=2160", "P1_hevc_3840x2160.m3u8"},
+		{"avc>hevc, <=2160", "P1_avc_1920x1080.m3u8"},
+		{"hdr, kbps<=20000", ""},
+	}
+	for _, c := range cases {
+		pref, err := ParseMVFormat(c.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", c.expr, err)
+		}
+		v, err := pref.SelectVideo(formats.Video)
+		if c.url == "" {
+			if err == nil {
+				t.Errorf("%q: expected no match, got %s", c.expr, v.URL)
+			}
+			continue
+		}
+		if err != nil || v.URL != "https://example.com/mv/"+c.url {
+			t.Errorf("%q: got %s (%v), want %s", c.expr, v.URL, err, c.url)
+		}
+	}
+
+	pref, _ := ParseMVFormat("aac>atmos")
+	if a, err := pref.SelectAudio(formats.Audio); err != nil || a.Codec != "aac" {
+		t.Errorf("audio preference: got %+v (%v)", a, err)
+	}
+	if _, err := ParseMVFormat("hevc>sdr"); err == nil {
+		t.Error("expected error for mixed clause")
+	}
+	if _, err := ParseMVFormat("vp9"); err == nil {
+		t.Error("expected error for unknown value")
+	}
+}
@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"main/internal/core"
+
+	"github.com/grafov/m3u8"
+)
+
+// AudioVariant 主播放列表中的一个音频变体
+type AudioVariant struct {
+	URL        string
+	Codec      string // alac、ec-3、ac-3、mp4a.40.2
+	Audio      string // 原始 AUDIO 分组名，如 audio-alac-stereo-96000-24
+	Atmos      bool
+	SampleRate int
+	BitDepth   int
+	Bitrate    int // kbps（有损编码）
+}
+
+// Quality 返回用于 {Quality} 的音质描述（与 ExtractMedia 的格式一致）
+func (v AudioVariant) Quality() string {
+	if v.Codec == "alac" {
+		return fmt.Sprintf("%dB-%.1fkHz", v.BitDepth, float64(v.SampleRate)/1000.0)
+	}
+	return fmt.Sprintf("%d kbps", v.Bitrate)
+}
+
+// Detail 返回便于阅读的音质描述，用于标签和报告
+func (v AudioVariant) Detail() string {
+	switch {
+	case v.Codec == "alac":
+		return fmt.Sprintf("%dbit/%.1fkHz", v.BitDepth, float64(v.SampleRate)/1000.0)
+	case v.Atmos:
+		// 分组名中的码率带有前缀 2（如 2768 表示 768 kbps）
+		bitrate := v.Bitrate
+		if bitrate >= 2000 && bitrate < 3000 {
+			bitrate -= 2000
+		}
+		return fmt.Sprintf("Dolby Atmos %d kbps", bitrate)
+	case v.Codec == "ac-3":
+		return fmt.Sprintf("Dolby Audio %d kbps", v.Bitrate)
+	default:
+		return fmt.Sprintf("AAC %d kbps", v.Bitrate)
+	}
+}
+
+// ListAudioVariants 获取主播放列表中的全部音频变体，按平均码率从高到低排列
+func ListAudioVariants(masterM3u8 string) ([]AudioVariant, error) {
+	masterUrl, err := url.Parse(masterM3u8)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.Get(masterM3u8)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	from, listType, err := m3u8.DecodeFrom(strings.NewReader(string(body)), true)
+	if err != nil || listType != m3u8.MASTER {
+		return nil, errors.New("m3u8 not of master type")
+	}
+	master := from.(*m3u8.MasterPlaylist)
+	sort.Slice(master.Variants, func(i, j int) bool {
+		return master.Variants[i].AverageBandwidth > master.Variants[j].AverageBandwidth
+	})
+
+	var variants []AudioVariant
+	for _, variant := range master.Variants {
+		streamUrl, err := masterUrl.Parse(variant.URI)
+		if err != nil {
+			continue
+		}
+		v := AudioVariant{URL: streamUrl.String(), Codec: variant.Codecs, Audio: variant.Audio}
+		split := strings.Split(variant.Audio, "-")
+		last := split[len(split)-1]
+		switch variant.Codecs {
+		case "alac":
+			if len(split) < 3 {
+				continue
+			}
+			v.BitDepth, _ = strconv.Atoi(last)
+			v.SampleRate, _ = strconv.Atoi(split[len(split)-2])
+		case "ec-3":
+			// 与 ExtractMedia 一致，直接使用分组名末尾的数字（如 2768）与 atmos-max 比较
+			v.Atmos = strings.Contains(variant.Audio, "atmos")
+			v.Bitrate, _ = strconv.Atoi(last)
+		case "ac-3":
+			v.Bitrate, _ = strconv.Atoi(last)
+		case "mp4a.40.2":
+			if len(split) >= 3 {
+				v.Bitrate, _ = strconv.Atoi(split[2])
+			}
+		default:
+			continue
+		}
+		variants = append(variants, v)
+	}
+	return variants, nil
+}
+
+// MatchTier 在变体列表中查找满足指定等级的最佳变体
+// hires-192: 176.4/192kHz 无损；hires-96: 88.2/96kHz 无损；hires: 任意高解析度无损；
+// lossless: 44.1/48kHz 无损；alac: 不超过 alac-max 的最佳无损；aac-256: 256kbps 立体声 AAC
+func MatchTier(variants []AudioVariant, tier string) (AudioVariant, bool) {
+	for _, v := range variants {
+		var ok bool
+		switch tier {
+		case "atmos":
+			ok = v.Codec == "ec-3" && v.Atmos && v.Bitrate <= *core.Atmos_max
+		case "dolby-audio":
+			ok = v.Codec == "ac-3"
+		case "hires-192":
+			ok = v.Codec == "alac" && v.SampleRate > 96000
+		case "hires-96":
+			ok = v.Codec == "alac" && v.SampleRate > 48000 && v.SampleRate <= 96000
+		case "hires":
+			ok = v.Codec == "alac" && v.SampleRate > 48000
+		case "lossless":
+			ok = v.Codec == "alac" && v.SampleRate <= 48000
+		case "alac":
+			ok = v.Codec == "alac" && v.SampleRate <= *core.Alac_max
+		case "aac-256":
+			ok = v.Codec == "mp4a.40.2" && strings.HasPrefix(v.Audio, "audio-stereo-") && v.Bitrate == 256
+		}
+		if ok {
+			return v, true
+		}
+	}
+	return AudioVariant{}, false
+}
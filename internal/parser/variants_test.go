@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"main/internal/core"
+)
+
+const testSongMaster = `#EXTM3U
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-STREAM-INF:BANDWIDTH=300000,AVERAGE-BANDWIDTH=260000,CODECS="mp4a.40.2",AUDIO="audio-stereo-256"
+P1_aac_256.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=5200000,AVERAGE-BANDWIDTH=4800000,CODECS="alac",AUDIO="audio-alac-stereo-192000-24"
+P1_alac_192.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2800000,AVERAGE-BANDWIDTH=2500000,CODECS="alac",AUDIO="audio-alac-stereo-96000-24"
+P1_alac_96.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1500000,AVERAGE-BANDWIDTH=1200000,CODECS="alac",AUDIO="audio-alac-stereo-44100-16"
+P1_alac_44.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=800000,AVERAGE-BANDWIDTH=770000,CODECS="ec-3",AUDIO="audio-atmos-2768"
+P1_atmos_768.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=700000,AVERAGE-BANDWIDTH=640000,CODECS="ac-3",AUDIO="audio-ac3-640"
+P1_ac3_640.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=100000,AVERAGE-BANDWIDTH=90000,CODECS="avc1.640028",AUDIO="audio-video"
+P1_video.m3u8
+`
+
+func loadTestVariants(t *testing.T) []AudioVariant {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/song/master.m3u8" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(testSongMaster))
+	}))
+	t.Cleanup(srv.Close)
+
+	variants, err := ListAudioVariants(srv.URL + "/song/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return variants
+}
+
+func useQualityLimits(t *testing.T, alacMax, atmosMax int) {
+	t.Helper()
+	oldAlac, oldAtmos := core.Alac_max, core.Atmos_max
+	core.Alac_max, core.Atmos_max = &alacMax, &atmosMax
+	t.Cleanup(func() { core.Alac_max, core.Atmos_max = oldAlac, oldAtmos })
+}
+
+func TestListAudioVariants(t *testing.T) {
+	variants := loadTestVariants(t)
+
+	// 按平均码率从高到低排列，忽略非音频编码的变体
+	var audio []string
+	for _, v := range variants {
+		audio = append(audio, v.Audio)
+	}
+	want := "audio-alac-stereo-192000-24,audio-alac-stereo-96000-24,audio-alac-stereo-44100-16,audio-atmos-2768,audio-ac3-640,audio-stereo-256"
+	if got := strings.Join(audio, ","); got != want {
+		t.Fatalf("variants = %s", got)
+	}
+
+	hires := variants[0]
+	if hires.SampleRate != 192000 || hires.BitDepth != 24 || !strings.HasSuffix(hires.URL, "/song/P1_alac_192.m3u8") {
+		t.Errorf("hires variant = %+v", hires)
+	}
+	if hires.Quality() != "24B-192.0kHz" || hires.Detail() != "24bit/192.0kHz" {
+		t.Errorf("hires quality = %q, detail = %q", hires.Quality(), hires.Detail())
+	}
+	if atmos := variants[3]; !atmos.Atmos || atmos.Bitrate != 2768 || atmos.Detail() != "Dolby Atmos 768 kbps" {
+		t.Errorf("atmos variant = %+v (%s)", atmos, atmos.Detail())
+	}
+	if aac := variants[5]; aac.Bitrate != 256 || aac.Quality() != "256 kbps" {
+		t.Errorf("aac variant = %+v", aac)
+	}
+}
+
+func TestMatchTier(t *testing.T) {
+	useQualityLimits(t, 96000, 2768)
+	variants := loadTestVariants(t)
+
+	for tier, want := range map[string]string{
+		"hires-192":   "audio-alac-stereo-192000-24",
+		"hires-96":    "audio-alac-stereo-96000-24",
+		"hires":       "audio-alac-stereo-192000-24",
+		"lossless":    "audio-alac-stereo-44100-16",
+		"alac":        "audio-alac-stereo-96000-24", // 受 alac-max 限制
+		"atmos":       "audio-atmos-2768",
+		"dolby-audio": "audio-ac3-640",
+		"aac-256":     "audio-stereo-256",
+	} {
+		v, ok := MatchTier(variants, tier)
+		if !ok || v.Audio != want {
+			t.Errorf("MatchTier(%s) = %q, %v, want %q", tier, v.Audio, ok, want)
+		}
+	}
+
+	// atmos-max 低于可用码率时没有匹配的全景声版本
+	useQualityLimits(t, 96000, 2448)
+	if v, ok := MatchTier(variants, "atmos"); ok {
+		t.Errorf("atmos matched %q above atmos-max", v.Audio)
+	}
+	if _, ok := MatchTier(variants[3:], "hires"); ok {
+		t.Error("hires matched without a hi-res variant")
+	}
+	if _, ok := MatchTier(variants, "skip"); ok {
+		t.Error("skip matched a variant")
+	}
+}
@@ -39,3 +39,22 @@ func (n *ProgressNotifier) NotifyStatus(trackIndex int, status string, stage str
 		Status:     status,
 	})
 }
+
+// NotifyAlbumStart 发送专辑开始事件（TrackIndex 为 -1，专辑信息在 Metadata 中）
+func (n *ProgressNotifier) NotifyAlbumStart(albumID, album string, tracks int) {
+	n.Notify(ProgressEvent{
+		TrackIndex: -1,
+		Stage:      StageAlbumStart,
+		Metadata:   map[string]interface{}{"album_id": albumID, "album": album, "tracks": tracks},
+	})
+}
+
+// NotifyAlbumEnd 发送专辑结束事件，err 为专辑下载的错误（如有）
+func (n *ProgressNotifier) NotifyAlbumEnd(albumID, album string, err error) {
+	n.Notify(ProgressEvent{
+		TrackIndex: -1,
+		Stage:      StageAlbumEnd,
+		Error:      err,
+		Metadata:   map[string]interface{}{"album_id": albumID, "album": album},
+	})
+}
@@ -0,0 +1,283 @@
+package progress
+
+import (
+	"sync"
+	"time"
+)
+
+// speedWindow 超过该时间没有新的下载事件的曲目不再计入实时速度
+const speedWindow = 3 * time.Second
+
+// maxSampleGap 两次下载事件间隔超过该值时只按该值估算下载量，避免暂停后一次性累加
+const maxSampleGap = 2 * time.Second
+
+// JobSnapshot 任务整体进度的快照，用于渲染
+type JobSnapshot struct {
+	Position     int           // 当前任务编号（从 1 开始，考虑 --start）
+	Total        int           // 任务总数
+	Album        string        // 当前专辑名
+	AlbumDone    int           // 当前专辑已结束的曲目数（成功、跳过或失败）
+	AlbumTotal   int           // 当前专辑选中的曲目数
+	TracksDone   int           // 整个任务已完成的曲目数
+	TracksFailed int           // 整个任务失败的曲目数
+	SpeedBPS     float64       // 当前所有曲目的合计速度
+	Bytes        int64         // 已下载字节数（按速度估算）
+	Elapsed      time.Duration // 已用时间（含休息）
+	ETA          time.Duration // 预计剩余时间（含休息），未知时为 -1
+	Rests        int           // ETA 中计入的休息次数
+	Resting      bool          // 是否正在休息
+}
+
+// trackSample 单个曲目最近一次下载事件
+type trackSample struct {
+	at    time.Time
+	speed float64
+}
+
+// JobTracker 汇总整个下载任务的进度：任务位置、专辑进度、合计速度、下载量和 ETA
+// 实现 ProgressListener，曲目级事件来自 ProgressNotifier，任务/专辑/批次边界由调用方通知
+type JobTracker struct {
+	mu  sync.Mutex
+	now func() time.Time
+
+	start     time.Time
+	total     int
+	skipped   int
+	queued    int  // 动态队列中尚未开始的任务数
+	dynamic   bool // 任务总数 = 已开始的任务数 + queued
+	position  int
+	tasksDone int
+
+	album      string
+	albumDone  int
+	albumTotal int
+	finished   map[int]bool // 当前批次已结束的曲目索引，避免重复计数
+
+	tracksDone   int
+	tracksFailed int
+	samples      map[int]trackSample
+	bytes        float64
+
+	work, rest time.Duration
+	cycleStart time.Time
+	restStart  time.Time
+	restTotal  time.Duration
+}
+
+// NewJobTracker 创建任务进度汇总器
+func NewJobTracker() *JobTracker {
+	return &JobTracker{
+		now:      time.Now,
+		finished: make(map[int]bool),
+		samples:  make(map[int]trackSample),
+	}
+}
+
+// StartJob 开始新的任务，total 为任务总数，skipped 为 --start 跳过的任务数
+func (j *JobTracker) StartJob(total, skipped int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := j.now()
+	j.start, j.cycleStart = now, now
+	j.total, j.skipped = total, skipped
+	j.queued, j.dynamic = 0, false
+	j.position, j.tasksDone = skipped, 0
+	j.album, j.albumDone, j.albumTotal = "", 0, 0
+	j.tracksDone, j.tracksFailed, j.bytes = 0, 0, 0
+	j.restStart, j.restTotal = time.Time{}, 0
+	j.finished = make(map[int]bool)
+	j.samples = make(map[int]trackSample)
+}
+
+// SetQueued 用于可随时添加任务的队列：任务总数按已开始的任务数加上 queued 计算
+func (j *JobTracker) SetQueued(queued int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.queued, j.dynamic = queued, true
+}
+
+// SetWorkRest 设置工作-休息循环，用于估算 ETA 中的休息时间；work 为 0 表示未启用
+func (j *JobTracker) SetWorkRest(work, rest time.Duration) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.work, j.rest = work, rest
+}
+
+// StartTask 开始第 position 个任务（一个链接）
+func (j *JobTracker) StartTask(position int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.position = position
+	j.album, j.albumDone, j.albumTotal = "", 0, 0
+}
+
+// FinishTask 当前任务结束（无论成功与否）
+func (j *JobTracker) FinishTask() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.tasksDone++
+	j.albumTotal = 0
+}
+
+// StartAlbum 当前任务开始下载专辑（或播放列表），tracks 为选中的曲目数
+func (j *JobTracker) StartAlbum(name string, tracks int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.album, j.albumDone, j.albumTotal = name, 0, tracks
+}
+
+// StartBatch 开始新的批次，曲目索引从 0 重新计算
+func (j *JobTracker) StartBatch() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.finished = make(map[int]bool)
+	j.samples = make(map[int]trackSample)
+}
+
+// StartRest 进入休息
+func (j *JobTracker) StartRest() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.restStart = j.now()
+}
+
+// EndRest 休息结束，开始新一轮工作
+func (j *JobTracker) EndRest() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := j.now()
+	if !j.restStart.IsZero() {
+		j.restTotal += now.Sub(j.restStart)
+		j.restStart = time.Time{}
+	}
+	j.cycleStart = now
+}
+
+// OnProgress 实现 ProgressListener：累计下载量并记录速度，complete/skipped/error 阶段视为曲目结束
+func (j *JobTracker) OnProgress(event ProgressEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	switch event.Stage {
+	case "download":
+		now := j.now()
+		if prev, ok := j.samples[event.TrackIndex]; ok {
+			gap := now.Sub(prev.at)
+			if gap > maxSampleGap {
+				gap = maxSampleGap
+			}
+			j.bytes += prev.speed * gap.Seconds()
+		}
+		j.samples[event.TrackIndex] = trackSample{at: now, speed: event.SpeedBPS}
+	case StageAlbumStart, StageAlbumEnd:
+	case "complete", "skipped":
+		j.finishTrack(event.TrackIndex, false)
+	case "error":
+		j.finishTrack(event.TrackIndex, true)
+	default:
+		// 解密、写标签等阶段不再下载，停止计入速度
+		delete(j.samples, event.TrackIndex)
+	}
+}
+
+// OnComplete 实现 ProgressListener
+func (j *JobTracker) OnComplete(trackIndex int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.finishTrack(trackIndex, false)
+}
+
+// OnError 实现 ProgressListener
+func (j *JobTracker) OnError(trackIndex int, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.finishTrack(trackIndex, true)
+}
+
+// finishTrack 记录曲目结束，调用方需持有锁
+func (j *JobTracker) finishTrack(index int, failed bool) {
+	delete(j.samples, index)
+	if j.finished[index] {
+		return
+	}
+	j.finished[index] = true
+	if j.albumDone < j.albumTotal {
+		j.albumDone++
+	}
+	if failed {
+		j.tracksFailed++
+	} else {
+		j.tracksDone++
+	}
+}
+
+// Snapshot 返回当前进度
+func (j *JobTracker) Snapshot() JobSnapshot {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := j.now()
+	total := j.total
+	if j.dynamic {
+		total = j.position + j.queued
+	}
+	s := JobSnapshot{
+		Position:     j.position,
+		Total:        total,
+		Album:        j.album,
+		AlbumDone:    j.albumDone,
+		AlbumTotal:   j.albumTotal,
+		TracksDone:   j.tracksDone,
+		TracksFailed: j.tracksFailed,
+		Bytes:        int64(j.bytes),
+		ETA:          -1,
+		Resting:      !j.restStart.IsZero(),
+	}
+	if j.start.IsZero() {
+		return s
+	}
+	s.Elapsed = now.Sub(j.start)
+	for _, sample := range j.samples {
+		if now.Sub(sample.at) <= speedWindow {
+			s.SpeedBPS += sample.speed
+		}
+	}
+	s.ETA, s.Rests = j.eta(now, total)
+	return s
+}
+
+// eta 按已完成任务（含当前专辑的曲目进度）的平均耗时估算剩余工作时间，
+// 启用工作-休息循环时再加上剩余工作时间内会触发的休息；调用方需持有锁
+func (j *JobTracker) eta(now time.Time, total int) (time.Duration, int) {
+	active := now.Sub(j.start) - j.restTotal
+	var restLeft time.Duration
+	if !j.restStart.IsZero() {
+		resting := now.Sub(j.restStart)
+		active -= resting
+		if restLeft = j.rest - resting; restLeft < 0 {
+			restLeft = 0
+		}
+	}
+
+	done := float64(j.tasksDone)
+	if j.albumTotal > 0 {
+		done += float64(j.albumDone) / float64(j.albumTotal)
+	}
+	remaining := float64(total-j.skipped) - done
+	if done <= 0 || active <= 0 {
+		return -1, 0
+	}
+	if remaining <= 0 {
+		return 0, 0
+	}
+	work := time.Duration(float64(active) / done * remaining)
+
+	rests := 0
+	if j.work > 0 && j.rest > 0 {
+		// 休息只在任务之间触发，最后一个任务结束后不再休息，这里按整轮工作时长近似
+		inCycle := now.Sub(j.cycleStart)
+		if !j.restStart.IsZero() {
+			inCycle = 0
+		}
+		rests = int((inCycle + work) / j.work)
+	}
+	return work + restLeft + time.Duration(rests)*j.rest, rests
+}
@@ -0,0 +1,96 @@
+package progress
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock 可手动推进的时钟
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestJobTrackerProgress(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	j := NewJobTracker()
+	j.now = clock.now
+
+	j.StartJob(4, 0)
+	if s := j.Snapshot(); s.ETA != -1 {
+		t.Errorf("ETA before any progress = %v, want -1", s.ETA)
+	}
+
+	j.StartTask(1)
+	j.StartAlbum("Album", 2)
+	j.StartBatch()
+	j.OnProgress(ProgressEvent{TrackIndex: 0, Stage: "download", SpeedBPS: 1000})
+	j.OnProgress(ProgressEvent{TrackIndex: 1, Stage: "download", SpeedBPS: 500})
+	clock.advance(time.Second)
+	j.OnProgress(ProgressEvent{TrackIndex: 0, Stage: "download", SpeedBPS: 2000})
+	j.OnProgress(ProgressEvent{TrackIndex: 1, Stage: "download", SpeedBPS: 500})
+
+	s := j.Snapshot()
+	if s.SpeedBPS != 2500 || s.Bytes != 1500 {
+		t.Errorf("speed %v bytes %d, want 2500 / 1500", s.SpeedBPS, s.Bytes)
+	}
+
+	// 同一曲目的完成事件可能重复到达，只计一次
+	clock.advance(9 * time.Second)
+	j.OnComplete(0)
+	j.OnProgress(ProgressEvent{TrackIndex: 0, Stage: "complete"})
+	j.OnError(1, errors.New("boom"))
+	s = j.Snapshot()
+	if s.AlbumDone != 2 || s.TracksDone != 1 || s.TracksFailed != 1 || s.SpeedBPS != 0 {
+		t.Errorf("snapshot = %+v", s)
+	}
+	j.FinishTask()
+
+	// 1 个任务用时 10s，剩余 3 个任务约 30s
+	if s := j.Snapshot(); s.Position != 1 || s.Total != 4 || s.ETA != 30*time.Second {
+		t.Errorf("position %d/%d ETA %v, want 1/4 30s", s.Position, s.Total, s.ETA)
+	}
+
+	// 当前专辑完成一半时按 1.5 个任务计算
+	j.StartTask(2)
+	j.StartAlbum("Next", 2)
+	j.StartBatch()
+	clock.advance(5 * time.Second)
+	j.OnComplete(0)
+	if s := j.Snapshot(); s.ETA != 25*time.Second {
+		t.Errorf("ETA mid-album = %v, want 25s", s.ETA)
+	}
+}
+
+func TestJobTrackerWorkRestETA(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	j := NewJobTracker()
+	j.now = clock.now
+
+	// --start 3：跳过前 2 个任务，本次运行还有 10 个
+	j.StartJob(12, 2)
+	j.SetWorkRest(30*time.Minute, 10*time.Minute)
+	j.StartTask(3)
+	clock.advance(10 * time.Minute)
+	j.FinishTask()
+
+	// 剩余 9 个任务 × 10 分钟 = 90 分钟工作，本轮已工作 10 分钟，还会休息 3 次
+	s := j.Snapshot()
+	if s.Position != 3 || s.Rests != 3 || s.ETA != 120*time.Minute {
+		t.Errorf("position %d rests %d ETA %v, want 3 / 3 / 120m", s.Position, s.Rests, s.ETA)
+	}
+
+	// 休息中：剩余休息时间计入 ETA，休息时间不计入平均耗时
+	j.StartRest()
+	clock.advance(4 * time.Minute)
+	s = j.Snapshot()
+	if !s.Resting || s.Rests != 3 || s.ETA != 6*time.Minute+90*time.Minute+30*time.Minute {
+		t.Errorf("resting %v rests %d ETA %v", s.Resting, s.Rests, s.ETA)
+	}
+	clock.advance(6 * time.Minute)
+	j.EndRest()
+	if s := j.Snapshot(); s.Resting || s.ETA != 90*time.Minute+30*time.Minute {
+		t.Errorf("after rest: resting %v ETA %v", s.Resting, s.ETA)
+	}
+}
@@ -0,0 +1,163 @@
+package progress
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TrackInfo 曲目在专辑中的编号和名称
+type TrackInfo struct {
+	Num  int
+	Name string
+}
+
+// JSONListener 把进度事件逐行输出为 JSON 对象（JSON Lines），供外部工具解析
+// 事件类型：album_start、album_end、track（阶段/进度变化）、track_done、track_skipped、track_error
+type JSONListener struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	lookup func(index int) (TrackInfo, bool)
+	now    func() time.Time
+
+	last      map[int]string // 曲目索引 -> 上次输出的阶段和进度，未变化时不重复输出
+	finished  map[int]bool   // 当前专辑已输出结束事件的曲目编号
+	completed int
+	failed    int
+}
+
+// NewJSONListener 创建 JSON 进度监听器，lookup 根据批次内索引返回曲目编号和名称
+func NewJSONListener(w io.Writer, lookup func(index int) (TrackInfo, bool)) *JSONListener {
+	return &JSONListener{
+		enc:      json.NewEncoder(w),
+		lookup:   lookup,
+		now:      time.Now,
+		last:     make(map[int]string),
+		finished: make(map[int]bool),
+	}
+}
+
+// emit 输出一个事件，调用方需持有锁
+func (l *JSONListener) emit(event string, fields map[string]interface{}) {
+	fields["time"] = l.now().Format("2006-01-02T15:04:05.000Z07:00")
+	fields["event"] = event
+	_ = l.enc.Encode(fields)
+}
+
+// trackFields 曲目事件的公共字段，调用方需持有锁
+func (l *JSONListener) trackFields(index int) (map[string]interface{}, int) {
+	fields := map[string]interface{}{"index": index}
+	key := -1 - index
+	if l.lookup != nil {
+		if info, ok := l.lookup(index); ok {
+			fields["track"] = info.Num
+			fields["name"] = info.Name
+			key = info.Num
+		}
+	}
+	return fields, key
+}
+
+// finish 输出曲目结束事件，同一曲目只输出一次，调用方需持有锁
+func (l *JSONListener) finish(index int, event string, fields map[string]interface{}, key int) {
+	delete(l.last, index)
+	if l.finished[key] {
+		return
+	}
+	l.finished[key] = true
+	switch event {
+	case "track_done":
+		l.completed++
+	case "track_error":
+		l.failed++
+	}
+	l.emit(event, fields)
+}
+
+// OnProgress 实现 ProgressListener
+func (l *JSONListener) OnProgress(event ProgressEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch event.Stage {
+	case StageAlbumStart:
+		l.last = make(map[int]string)
+		l.finished = make(map[int]bool)
+		l.completed, l.failed = 0, 0
+		fields := map[string]interface{}{}
+		for k, v := range event.Metadata {
+			fields[k] = v
+		}
+		l.emit("album_start", fields)
+		return
+	case StageAlbumEnd:
+		fields := map[string]interface{}{"completed": l.completed, "failed": l.failed}
+		for k, v := range event.Metadata {
+			fields[k] = v
+		}
+		if event.Error != nil {
+			fields["error"] = event.Error.Error()
+		}
+		l.emit("album_end", fields)
+		return
+	}
+
+	fields, key := l.trackFields(event.TrackIndex)
+	if event.Status != "" {
+		fields["status"] = event.Status
+	}
+	switch event.Stage {
+	case "complete":
+		l.finish(event.TrackIndex, "track_done", fields, key)
+		return
+	case "skipped":
+		l.finish(event.TrackIndex, "track_skipped", fields, key)
+		return
+	case "error":
+		if event.Error != nil {
+			fields["error"] = event.Error.Error()
+		}
+		l.finish(event.TrackIndex, "track_error", fields, key)
+		return
+	}
+
+	// 适配器异步转发的进度可能晚于完成事件到达
+	if l.finished[key] {
+		return
+	}
+	fields["stage"] = event.Stage
+	state := event.Stage + "|" + event.Status
+	if event.Stage == "download" || event.Stage == "decrypt" {
+		fields["percent"] = event.Percentage
+		state += "|" + strconv.Itoa(event.Percentage)
+	}
+	if event.Stage == "download" {
+		fields["speed_bps"] = int64(event.SpeedBPS)
+	}
+	if l.last[event.TrackIndex] == state {
+		return
+	}
+	l.last[event.TrackIndex] = state
+	l.emit("track", fields)
+}
+
+// OnComplete 实现 ProgressListener
+func (l *JSONListener) OnComplete(trackIndex int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fields, key := l.trackFields(trackIndex)
+	l.finish(trackIndex, "track_done", fields, key)
+}
+
+// OnError 实现 ProgressListener
+func (l *JSONListener) OnError(trackIndex int, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fields, key := l.trackFields(trackIndex)
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	l.finish(trackIndex, "track_error", fields, key)
+}
@@ -0,0 +1,65 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONListener(t *testing.T) {
+	var buf bytes.Buffer
+	names := []TrackInfo{{Num: 3, Name: "Intro"}, {Num: 4, Name: "Outro"}}
+	l := NewJSONListener(&buf, func(index int) (TrackInfo, bool) {
+		if index < 0 || index >= len(names) {
+			return TrackInfo{}, false
+		}
+		return names[index], true
+	})
+	l.now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	notifier := NewNotifier()
+	notifier.AddListener(l)
+	notifier.NotifyAlbumStart("123", "Album", 2)
+	notifier.NotifyDownloadProgress(0, 10, 2048)
+	notifier.NotifyDownloadProgress(0, 10, 4096) // 进度未变化，不重复输出
+	notifier.NotifyDecryptProgress(0, 50)
+	notifier.NotifyComplete(0)
+	notifier.NotifyDownloadProgress(0, 100, 4096) // 完成后迟到的进度被忽略
+	notifier.NotifyError(1, errors.New("boom"))
+	notifier.NotifyAlbumEnd("123", "Album", nil)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var events []map[string]interface{}
+	for _, line := range lines {
+		var e map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		events = append(events, e)
+	}
+
+	want := []string{"album_start", "track", "track", "track_done", "track_error", "album_end"}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d:\n%s", len(events), len(want), buf.String())
+	}
+	for i, e := range events {
+		if e["event"] != want[i] {
+			t.Errorf("event %d = %v, want %s", i, e["event"], want[i])
+		}
+	}
+	if e := events[0]; e["album"] != "Album" || e["tracks"] != float64(2) || e["time"] != "2024-01-01T00:00:00.000Z" {
+		t.Errorf("album_start = %v", e)
+	}
+	if e := events[1]; e["track"] != float64(3) || e["name"] != "Intro" || e["stage"] != "download" || e["percent"] != float64(10) || e["speed_bps"] != float64(2048) {
+		t.Errorf("track = %v", e)
+	}
+	if e := events[4]; e["track"] != float64(4) || e["error"] != "boom" {
+		t.Errorf("track_error = %v", e)
+	}
+	if e := events[5]; e["completed"] != float64(1) || e["failed"] != float64(1) {
+		t.Errorf("album_end = %v", e)
+	}
+}
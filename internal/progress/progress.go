@@ -15,6 +15,12 @@ type ProgressEvent struct {
 	Metadata   map[string]interface{} // 额外元数据
 }
 
+// 专辑级事件的阶段，TrackIndex 为 -1，曲目级监听器应忽略
+const (
+	StageAlbumStart = "album-start"
+	StageAlbumEnd   = "album-end"
+)
+
 // ProgressListener 进度监听器接口
 type ProgressListener interface {
 	OnProgress(event ProgressEvent)
@@ -0,0 +1,514 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// State 队列条目状态
+type State string
+
+const (
+	Pending  State = "pending"  // 等待下载
+	Running  State = "running"  // 正在下载
+	Paused   State = "paused"   // 已暂停（等待中的条目不会被取出，下载中的条目不再开始新曲目）
+	Done     State = "done"     // 完成
+	Failed   State = "failed"   // 失败
+	Canceled State = "canceled" // 已取消
+)
+
+// StateNames 状态的显示名称
+var StateNames = map[State]string{
+	Pending:  "等待中",
+	Running:  "下载中",
+	Paused:   "已暂停",
+	Done:     "已完成",
+	Failed:   "失败",
+	Canceled: "已取消",
+}
+
+// TrackError 单首曲目的错误
+type TrackError struct {
+	TrackNum int    `json:"track"`
+	Name     string `json:"name"`
+	Error    string `json:"error"`
+}
+
+// ReportEntry 运行报告中的一条记录（音质回退、完整性校验等）
+type ReportEntry struct {
+	Section string `json:"section"`
+	Track   string `json:"track"`
+	Detail  string `json:"detail"`
+}
+
+// Report 条目下载结束后的运行报告
+type Report struct {
+	Total    int           `json:"total"`
+	Success  int           `json:"success"`
+	Warnings int           `json:"warnings"`
+	Errors   int           `json:"errors"`
+	Entries  []ReportEntry `json:"entries,omitempty"`
+}
+
+// Item 队列中的一个链接（专辑、播放列表、单曲或 MV）
+type Item struct {
+	ID          int          `json:"id"`
+	URL         string       `json:"url"`
+	Title       string       `json:"title,omitempty"` // 开始下载后填入专辑名
+	State       State        `json:"state"`
+	Formats     []string     `json:"formats,omitempty"`    // 下载格式，为空时使用全局设置
+	ArtistDir   string       `json:"artist_dir,omitempty"` // 歌手页面展开时确定的歌手文件夹格式，为空时使用全局设置
+	Skip        map[int]bool `json:"skip,omitempty"`       // 跳过的曲目编号
+	Error       string       `json:"error,omitempty"`      // 整个条目的错误
+	TrackErrors []TrackError `json:"track_errors,omitempty"`
+	Report      *Report      `json:"report,omitempty"`
+	Added       time.Time    `json:"added"`
+	Started     time.Time    `json:"started"`
+	Finished    time.Time    `json:"finished"`
+}
+
+// clone 返回条目的副本，避免调用方修改队列内部状态
+func (it *Item) clone() Item {
+	c := *it
+	c.Skip = make(map[int]bool, len(it.Skip))
+	for k, v := range it.Skip {
+		c.Skip[k] = v
+	}
+	c.Formats = append([]string(nil), it.Formats...)
+	c.TrackErrors = append([]TrackError(nil), it.TrackErrors...)
+	if it.Report != nil {
+		r := *it.Report
+		r.Entries = append([]ReportEntry(nil), it.Report.Entries...)
+		c.Report = &r
+	}
+	return c
+}
+
+// Queue 下载队列，可在下载过程中添加、调整顺序、暂停或取消条目
+type Queue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	items   []*Item
+	nextID  int
+	closed  bool
+	running *Item
+	// paused 下载中的条目被暂停时为 true，Gate 会阻塞到恢复或取消
+	paused   bool
+	onChange func()
+}
+
+// New 创建空队列
+func New() *Queue {
+	q := &Queue{nextID: 1}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// OnChange 设置队列变化时的回调（在锁外调用）
+func (q *Queue) OnChange(f func()) {
+	q.mu.Lock()
+	q.onChange = f
+	q.mu.Unlock()
+}
+
+// changed 通知队列变化，调用方不能持有锁
+func (q *Queue) changed() {
+	q.mu.Lock()
+	f := q.onChange
+	q.mu.Unlock()
+	if f != nil {
+		f()
+	}
+}
+
+// Add 把链接加入队列末尾，返回条目 ID
+func (q *Queue) Add(url string) int {
+	return q.AddWith(url, nil, nil)
+}
+
+// AddWith 把链接加入队列末尾并指定下载格式和跳过的曲目，返回条目 ID
+func (q *Queue) AddWith(url string, formats []string, skip []int) int {
+	q.mu.Lock()
+	it := &Item{ID: q.nextID, URL: url, State: Pending, Formats: formats, Skip: make(map[int]bool), Added: time.Now()}
+	for _, n := range skip {
+		it.Skip[n] = true
+	}
+	q.nextID++
+	q.items = append(q.items, it)
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	q.changed()
+	return it.ID
+}
+
+// Insert 把链接插入到指定条目之后（歌手页面展开为专辑时使用），after 不存在时加到末尾
+// 插入的条目沿用 after 的下载格式，并使用 artistDir 作为歌手文件夹格式
+func (q *Queue) Insert(after int, urls []string, artistDir string) {
+	q.mu.Lock()
+	pos := len(q.items)
+	var formats []string
+	if i := q.index(after); i >= 0 {
+		pos = i + 1
+		formats = q.items[i].Formats
+	}
+	added := make([]*Item, 0, len(urls))
+	for _, url := range urls {
+		added = append(added, &Item{ID: q.nextID, URL: url, State: Pending, Formats: formats, ArtistDir: artistDir, Skip: make(map[int]bool), Added: time.Now()})
+		q.nextID++
+	}
+	q.items = append(q.items[:pos], append(added, q.items[pos:]...)...)
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	q.changed()
+}
+
+// Get 返回指定条目的快照
+func (q *Queue) Get(id int) (Item, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if i := q.index(id); i >= 0 {
+		return q.items[i].clone(), true
+	}
+	return Item{}, false
+}
+
+// Items 返回所有条目的快照
+func (q *Queue) Items() []Item {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]Item, len(q.items))
+	for i, it := range q.items {
+		out[i] = it.clone()
+	}
+	return out
+}
+
+// Running 返回下载中的条目
+func (q *Queue) Running() (Item, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.running == nil {
+		return Item{}, false
+	}
+	return q.running.clone(), true
+}
+
+// index 返回条目位置，调用方需持有锁
+func (q *Queue) index(id int) int {
+	for i, it := range q.items {
+		if it.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Next 取出下一个等待中的条目并标记为下载中，队列为空时阻塞，Close 后返回 false
+func (q *Queue) Next() (Item, bool) {
+	q.mu.Lock()
+	for {
+		for _, it := range q.items {
+			if it.State == Pending {
+				it.State = Running
+				it.Started = time.Now()
+				q.running = it
+				q.paused = false
+				c := it.clone()
+				q.mu.Unlock()
+				q.changed()
+				return c, true
+			}
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return Item{}, false
+		}
+		q.cond.Wait()
+	}
+}
+
+// Finish 结束下载中的条目，err 非空时标记为失败；已取消的条目保持取消状态
+func (q *Queue) Finish(id int, err error) {
+	q.mu.Lock()
+	if i := q.index(id); i >= 0 {
+		it := q.items[i]
+		it.Finished = time.Now()
+		switch {
+		case it.State == Canceled:
+		case err != nil:
+			it.State = Failed
+			it.Error = err.Error()
+		default:
+			it.State = Done
+		}
+	}
+	if q.running != nil && q.running.ID == id {
+		q.running = nil
+		q.paused = false
+	}
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	q.changed()
+}
+
+// SetTitle 设置条目标题（专辑名）
+func (q *Queue) SetTitle(id int, title string) {
+	q.mu.Lock()
+	if i := q.index(id); i >= 0 {
+		q.items[i].Title = title
+	}
+	q.mu.Unlock()
+	q.changed()
+}
+
+// SetReport 记录条目的运行报告
+func (q *Queue) SetReport(id int, r Report) {
+	q.mu.Lock()
+	if i := q.index(id); i >= 0 {
+		q.items[i].Report = &r
+	}
+	q.mu.Unlock()
+	q.changed()
+}
+
+// Move 调整条目位置，delta 为负数时上移；只能在等待中的条目之间移动
+func (q *Queue) Move(id, delta int) bool {
+	q.mu.Lock()
+	movable := func(k int) bool {
+		return k >= 0 && k < len(q.items) && q.items[k] != q.running &&
+			(q.items[k].State == Pending || q.items[k].State == Paused)
+	}
+	i := q.index(id)
+	j := i + delta
+	ok := i >= 0 && movable(i) && movable(j)
+	if ok {
+		q.items[i], q.items[j] = q.items[j], q.items[i]
+	}
+	q.mu.Unlock()
+	if ok {
+		q.changed()
+	}
+	return ok
+}
+
+// TogglePause 暂停或恢复条目：等待中的条目不会被取出，下载中的条目不再开始新的曲目
+func (q *Queue) TogglePause(id int) {
+	q.mu.Lock()
+	if i := q.index(id); i >= 0 {
+		it := q.items[i]
+		switch {
+		case it.State == Pending:
+			it.State = Paused
+		case it.State == Paused && it != q.running:
+			it.State = Pending
+		case it == q.running && (it.State == Running || it.State == Paused):
+			q.paused = !q.paused
+			if q.paused {
+				it.State = Paused
+			} else {
+				it.State = Running
+			}
+		}
+	}
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	q.changed()
+}
+
+// Cancel 取消条目：等待中的条目不再下载，下载中的条目跳过尚未开始的曲目
+func (q *Queue) Cancel(id int) {
+	q.mu.Lock()
+	if i := q.index(id); i >= 0 {
+		it := q.items[i]
+		if it.State != Done && it.State != Failed && it.State != Canceled {
+			it.State = Canceled
+			if it == q.running {
+				q.paused = false
+			} else {
+				it.Finished = time.Now()
+			}
+		}
+	}
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	q.changed()
+}
+
+// Retry 把失败或取消的条目重新放回等待状态
+func (q *Queue) Retry(id int) {
+	q.mu.Lock()
+	if i := q.index(id); i >= 0 {
+		it := q.items[i]
+		if (it.State == Failed || it.State == Canceled) && it != q.running {
+			it.State = Pending
+			it.Error = ""
+			it.TrackErrors = nil
+			it.Report = nil
+		}
+	}
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	q.changed()
+}
+
+// ToggleTrack 切换条目中某首曲目是否跳过，下载中的条目只对尚未开始的曲目生效
+func (q *Queue) ToggleTrack(id, trackNum int) {
+	q.mu.Lock()
+	if i := q.index(id); i >= 0 {
+		skip := q.items[i].Skip
+		if skip[trackNum] {
+			delete(skip, trackNum)
+		} else {
+			skip[trackNum] = true
+		}
+	}
+	q.mu.Unlock()
+	q.changed()
+}
+
+// SetSkip 设置条目跳过的曲目
+func (q *Queue) SetSkip(id int, tracks []int) {
+	q.mu.Lock()
+	if i := q.index(id); i >= 0 {
+		skip := make(map[int]bool, len(tracks))
+		for _, n := range tracks {
+			skip[n] = true
+		}
+		q.items[i].Skip = skip
+	}
+	q.mu.Unlock()
+	q.changed()
+}
+
+// AddTrackError 记录下载中条目的曲目错误
+func (q *Queue) AddTrackError(e TrackError) {
+	q.mu.Lock()
+	if q.running != nil {
+		q.running.TrackErrors = append(q.running.TrackErrors, e)
+	}
+	q.mu.Unlock()
+	q.changed()
+}
+
+// Gate 在下载中条目的每首曲目开始前调用：暂停时阻塞，条目被取消或曲目被跳过时返回 false
+func (q *Queue) Gate(trackNum int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.running != nil && q.paused && q.running.State != Canceled {
+		q.cond.Wait()
+	}
+	if q.running == nil {
+		return true
+	}
+	return q.running.State != Canceled && !q.running.Skip[trackNum]
+}
+
+// Close 关闭队列，Next 在没有等待中的条目后返回 false
+func (q *Queue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+}
+
+// savedQueue 队列文件的内容
+type savedQueue struct {
+	NextID int     `json:"next_id"`
+	Items  []*Item `json:"items"`
+}
+
+// Save 把队列写入文件（先写临时文件再替换，避免中途退出留下不完整的文件）
+func (q *Queue) Save(path string) error {
+	q.mu.Lock()
+	data, err := json.MarshalIndent(savedQueue{NextID: q.nextID, Items: q.items}, "", "  ")
+	q.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load 从文件恢复队列，文件不存在时返回空队列
+// 上次退出时正在下载的条目重新放回等待状态，从头开始下载（已下载的曲目会被识别为已存在）
+func Load(path string) (*Queue, error) {
+	q := New()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved savedQueue
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("解析队列文件 %s 失败: %v", path, err)
+	}
+	for _, it := range saved.Items {
+		if it.State == Running {
+			it.State = Pending
+		}
+		if it.Skip == nil {
+			it.Skip = make(map[int]bool)
+		}
+		if it.ID >= saved.NextID {
+			saved.NextID = it.ID + 1
+		}
+	}
+	q.items = saved.Items
+	if saved.NextID > q.nextID {
+		q.nextID = saved.NextID
+	}
+	return q, nil
+}
+
+// maxTrack 曲目编号上限，超出的范围视为无效，避免 "1-2000000000" 之类的输入占满内存
+const maxTrack = 999
+
+// ParseTracks 解析曲目编号列表，如 "1-3,5"
+func ParseTracks(spec string) ([]int, error) {
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil || start < 1 || start > maxTrack {
+			return nil, fmt.Errorf("无效的曲目编号: %s", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil || end < start || end > maxTrack {
+				return nil, fmt.Errorf("无效的曲目范围: %s", part)
+			}
+		}
+		for n := start; n <= end; n++ {
+			seen[n] = true
+		}
+	}
+	tracks := make([]int, 0, len(seen))
+	for n := range seen {
+		tracks = append(tracks, n)
+	}
+	sort.Ints(tracks)
+	return tracks, nil
+}
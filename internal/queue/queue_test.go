@@ -0,0 +1,150 @@
+package queue
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func ids(items []Item) []int {
+	var out []int
+	for _, it := range items {
+		out = append(out, it.ID)
+	}
+	return out
+}
+
+func TestQueueOrderAndStates(t *testing.T) {
+	q := New()
+	a, b, c := q.Add("a"), q.Add("b"), q.Add("c")
+
+	if !q.Move(c, -1) || q.Move(a, -1) {
+		t.Fatal("unexpected Move result")
+	}
+	if got := ids(q.Items()); !reflect.DeepEqual(got, []int{a, c, b}) {
+		t.Fatalf("order = %v", got)
+	}
+
+	// 暂停的条目不会被取出
+	q.TogglePause(a)
+	item, _ := q.Next()
+	if item.ID != c {
+		t.Fatalf("Next = %d, want %d", item.ID, c)
+	}
+	// 下载中的条目不能移动
+	if q.Move(c, 1) {
+		t.Error("running item moved")
+	}
+	q.Finish(c, errors.New("boom"))
+
+	q.Cancel(b)
+	q.TogglePause(a)
+	if item, _ = q.Next(); item.ID != a {
+		t.Fatalf("Next = %d, want %d", item.ID, a)
+	}
+	q.Finish(a, nil)
+
+	states := map[int]State{}
+	for _, it := range q.Items() {
+		states[it.ID] = it.State
+	}
+	if states[a] != Done || states[b] != Canceled || states[c] != Failed {
+		t.Errorf("states = %v", states)
+	}
+
+	q.Retry(c)
+	if item, _ = q.Next(); item.ID != c || len(item.Skip) != 0 {
+		t.Errorf("retried item = %+v", item)
+	}
+	q.Finish(c, nil)
+
+	q.Close()
+	if _, ok := q.Next(); ok {
+		t.Error("Next after Close returned an item")
+	}
+}
+
+func TestQueueGate(t *testing.T) {
+	q := New()
+	id := q.Add("album")
+	q.SetSkip(id, []int{2})
+	q.Next()
+
+	if !q.Gate(1) || q.Gate(2) {
+		t.Fatal("skip list not applied")
+	}
+	q.ToggleTrack(id, 2)
+	if !q.Gate(2) {
+		t.Error("toggled track still skipped")
+	}
+
+	// 暂停时 Gate 阻塞，取消后返回 false
+	q.TogglePause(id)
+	result := make(chan bool)
+	go func() { result <- q.Gate(3) }()
+	select {
+	case <-result:
+		t.Fatal("Gate returned while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	q.Cancel(id)
+	if <-result {
+		t.Error("Gate allowed a track of a canceled item")
+	}
+	q.Finish(id, nil)
+	if it := q.Items()[0]; it.State != Canceled {
+		t.Errorf("state = %s, want canceled", it.State)
+	}
+}
+
+func TestQueueSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	q := New()
+	a := q.AddWith("a", []string{"atmos"}, []int{2})
+	b := q.Add("b")
+	q.Next()
+	q.Insert(a, []string{"a1"}, "Artist")
+	if err := q.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := loaded.Items()
+	if got := ids(items); !reflect.DeepEqual(got, []int{a, b + 1, b}) {
+		t.Fatalf("order = %v", got)
+	}
+	// 上次正在下载的条目重新等待，展开的条目沿用格式并带有歌手文件夹
+	if items[0].State != Pending || !items[0].Skip[2] || !reflect.DeepEqual(items[1].Formats, []string{"atmos"}) || items[1].ArtistDir != "Artist" {
+		t.Errorf("items = %+v", items)
+	}
+	if id := loaded.Add("c"); id != b+2 {
+		t.Errorf("new ID = %d, want %d", id, b+2)
+	}
+
+	if q, err := Load(filepath.Join(t.TempDir(), "missing.json")); err != nil || len(q.Items()) != 0 {
+		t.Errorf("missing file = %v, %v", q, err)
+	}
+}
+
+func TestParseTracks(t *testing.T) {
+	got, err := ParseTracks("5, 1-3,2")
+	if err != nil || !reflect.DeepEqual(got, []int{1, 2, 3, 5}) {
+		t.Errorf("ParseTracks = %v, %v", got, err)
+	}
+	if got, err := ParseTracks(""); err != nil || len(got) != 0 {
+		t.Errorf("empty = %v, %v", got, err)
+	}
+	if got, err := ParseTracks("998-999"); err != nil || !reflect.DeepEqual(got, []int{998, 999}) {
+		t.Errorf("upper bound = %v, %v", got, err)
+	}
+	for _, bad := range []string{"0", "3-1", "x", "1000", "1-2000000000"} {
+		if _, err := ParseTracks(bad); err == nil {
+			t.Errorf("ParseTracks(%q) succeeded", bad)
+		}
+	}
+}
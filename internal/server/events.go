@@ -0,0 +1,64 @@
+package server
+
+import (
+	"bytes"
+	"sync"
+)
+
+// event 推送给 SSE 客户端的一个事件
+type event struct {
+	name string
+	data []byte
+}
+
+// hub 把事件广播给所有连接中的 SSE 客户端
+type hub struct {
+	mu      sync.Mutex
+	clients map[chan event]struct{}
+}
+
+func newHub() *hub {
+	return &hub{clients: make(map[chan event]struct{})}
+}
+
+// subscribe 注册一个客户端
+func (h *hub) subscribe() chan event {
+	c := make(chan event, 256)
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+	return c
+}
+
+// unsubscribe 注销客户端
+func (h *hub) unsubscribe(c chan event) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+}
+
+// publish 广播事件，客户端来不及接收时丢弃该客户端的这个事件，不阻塞下载
+func (h *hub) publish(name string, data []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		select {
+		case c <- event{name: name, data: data}:
+		default:
+		}
+	}
+}
+
+// progressWriter 把 progress.JSONListener 输出的每一行作为 progress 事件广播
+type progressWriter struct {
+	h *hub
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		if len(line) > 0 {
+			w.h.publish("progress", append([]byte(nil), line...))
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,342 @@
+package server
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"main/internal/core"
+	"main/internal/queue"
+)
+
+//go:embed index.html
+var indexHTML []byte
+
+// Server 本地 HTTP 服务：提交、查看和取消下载任务，通过 SSE 推送进度和任务变化
+type Server struct {
+	q      *queue.Queue
+	token  string
+	listen string
+	hub    *hub
+}
+
+// New 创建服务，token 非空时 /api/ 下的请求需要携带该令牌；listen 为监听地址，用于校验 Host 请求头
+func New(q *queue.Queue, token, listen string) *Server {
+	return &Server{q: q, token: token, listen: listen, hub: newHub()}
+}
+
+// Events 返回进度事件的输出，作为 progress.JSONListener 的 Writer 使用
+func (s *Server) Events() io.Writer {
+	return progressWriter{h: s.hub}
+}
+
+// JobsChanged 在队列变化时调用，向客户端推送最新的任务列表
+func (s *Server) JobsChanged() {
+	data, err := json.Marshal(s.q.Items())
+	if err != nil {
+		return
+	}
+	s.hub.publish("jobs", data)
+}
+
+// Handler 返回 HTTP 路由
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handleIndex)
+	mux.HandleFunc("GET /api/jobs", s.auth(s.handleList))
+	mux.HandleFunc("POST /api/jobs", s.auth(s.handleSubmit))
+	mux.HandleFunc("GET /api/jobs/{id}", s.auth(s.handleGet))
+	mux.HandleFunc("GET /api/jobs/{id}/report", s.auth(s.handleReport))
+	mux.HandleFunc("POST /api/jobs/{id}/cancel", s.auth(s.handleCancel))
+	mux.HandleFunc("POST /api/jobs/{id}/retry", s.auth(s.handleRetry))
+	mux.HandleFunc("GET /api/events", s.auth(s.handleEvents))
+	return s.guard(mux)
+}
+
+// guard 拦截其他网站发起的请求：
+//   - 未设置令牌时只接受以 IP、localhost 或监听地址访问的请求，防止 DNS 重绑定（恶意域名解析到 127.0.0.1）
+//   - POST 请求必须为 application/json，且不能来自其他来源（Origin、Sec-Fetch-Site），
+//     浏览器跨站提交 JSON 需要预检请求，本服务不响应 CORS，因此无法从其他网页提交或取消任务
+func (s *Server) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token == "" && !s.allowedHost(r.Host) {
+			writeError(w, http.StatusForbidden, "不允许的 Host")
+			return
+		}
+		if r.Method == http.MethodPost {
+			if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" && site != "none" {
+				writeError(w, http.StatusForbidden, "不接受跨站请求")
+				return
+			}
+			if origin := r.Header.Get("Origin"); origin != "" {
+				if u, err := url.Parse(origin); err != nil || !strings.EqualFold(u.Host, r.Host) {
+					writeError(w, http.StatusForbidden, "不接受跨站请求")
+					return
+				}
+			}
+			if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, "Content-Type 必须为 application/json")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowedHost 判断 Host 请求头是否为 IP 地址、localhost 或监听地址中的主机名
+func (s *Server) allowedHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	if host == "" {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") || net.ParseIP(host) != nil {
+		return true
+	}
+	listenHost, _, err := net.SplitHostPort(s.listen)
+	return err == nil && listenHost != "" && strings.EqualFold(host, listenHost)
+}
+
+// auth 校验访问令牌：Authorization: Bearer <令牌> 或 ?token=<令牌>（EventSource 无法设置请求头）
+func (s *Server) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if got == "" {
+				got = r.URL.Query().Get("token")
+			}
+			if subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+				writeError(w, http.StatusUnauthorized, "访问令牌无效")
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(indexHTML)
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.q.Items())
+}
+
+// submitRequest POST /api/jobs 的请求内容
+type submitRequest struct {
+	URLs    []string `json:"urls"`
+	Formats string   `json:"formats"` // 同 --formats，如 "alac,atmos"
+	Skip    string   `json:"skip"`    // 跳过的曲目，如 "1-3,5"
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req submitRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("无效的请求: %v", err))
+		return
+	}
+	var urls []string
+	for _, u := range req.URLs {
+		u = strings.TrimSpace(u)
+		if u == "" {
+			continue
+		}
+		if !strings.Contains(u, "music.apple.com/") {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("不是 Apple Music 链接: %s", u))
+			return
+		}
+		urls = append(urls, u)
+	}
+	if len(urls) == 0 {
+		writeError(w, http.StatusBadRequest, "没有提交链接")
+		return
+	}
+	formats, err := core.ParseFormatList(req.Formats)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	skip, err := queue.ParseTracks(req.Skip)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jobs := make([]queue.Item, 0, len(urls))
+	for _, u := range urls {
+		item, _ := s.q.Get(s.q.AddWith(u, formats, skip))
+		jobs = append(jobs, item)
+	}
+	writeJSON(w, http.StatusCreated, jobs)
+}
+
+// job 根据路径中的 id 查找任务，找不到时写入错误
+func (s *Server) job(w http.ResponseWriter, r *http.Request) (queue.Item, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "无效的任务 ID")
+		return queue.Item{}, false
+	}
+	item, ok := s.q.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "任务不存在")
+	}
+	return item, ok
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	if item, ok := s.job(w, r); ok {
+		writeJSON(w, http.StatusOK, item)
+	}
+}
+
+// jobReport GET /api/jobs/{id}/report 的响应内容
+type jobReport struct {
+	ID          int                `json:"id"`
+	URL         string             `json:"url"`
+	Title       string             `json:"title,omitempty"`
+	State       queue.State        `json:"state"`
+	Error       string             `json:"error,omitempty"`
+	Started     time.Time          `json:"started"`
+	Finished    time.Time          `json:"finished"`
+	Seconds     float64            `json:"seconds"`
+	TrackErrors []queue.TrackError `json:"track_errors"`
+	*queue.Report
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.job(w, r)
+	if !ok {
+		return
+	}
+	switch item.State {
+	case queue.Done, queue.Failed, queue.Canceled:
+	default:
+		writeError(w, http.StatusConflict, "任务尚未结束")
+		return
+	}
+	report := jobReport{
+		ID:          item.ID,
+		URL:         item.URL,
+		Title:       item.Title,
+		State:       item.State,
+		Error:       item.Error,
+		Started:     item.Started,
+		Finished:    item.Finished,
+		TrackErrors: item.TrackErrors,
+		Report:      item.Report,
+	}
+	if report.TrackErrors == nil {
+		report.TrackErrors = []queue.TrackError{}
+	}
+	if report.Report == nil {
+		report.Report = &queue.Report{}
+	}
+	if !item.Started.IsZero() {
+		report.Seconds = item.Finished.Sub(item.Started).Seconds()
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.job(w, r)
+	if !ok {
+		return
+	}
+	if item.State == queue.Done || item.State == queue.Failed {
+		writeError(w, http.StatusConflict, "任务已结束")
+		return
+	}
+	s.q.Cancel(item.ID)
+	item, _ = s.q.Get(item.ID)
+	writeJSON(w, http.StatusOK, item)
+}
+
+func (s *Server) handleRetry(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.job(w, r)
+	if !ok {
+		return
+	}
+	if item.State != queue.Failed && item.State != queue.Canceled {
+		writeError(w, http.StatusConflict, "只能重试失败或已取消的任务")
+		return
+	}
+	s.q.Retry(item.ID)
+	item, _ = s.q.Get(item.ID)
+	writeJSON(w, http.StatusOK, item)
+}
+
+// handleEvents Server-Sent Events：连接后先推送一次任务列表，之后推送 jobs 和 progress 事件
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "不支持流式响应")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	c := s.hub.subscribe()
+	defer s.hub.unsubscribe(c)
+
+	if data, err := json.Marshal(s.q.Items()); err == nil {
+		writeEvent(w, event{name: "jobs", data: data})
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-c:
+			writeEvent(w, e)
+			flusher.Flush()
+		case <-keepalive.C:
+			_, _ = io.WriteString(w, ": keepalive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w io.Writer, e event) {
+	_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.name, e.data)
+}
+
+// IsLoopback 判断监听地址是否只接受本机连接
+func IsLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
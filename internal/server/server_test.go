@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"main/internal/queue"
+)
+
+func request(t *testing.T, h http.Handler, method, path, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var out map[string]interface{}
+	if strings.HasPrefix(strings.TrimSpace(rec.Body.String()), "{") {
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return rec, out
+}
+
+func TestJobsAPI(t *testing.T) {
+	q := queue.New()
+	h := New(q, "secret", "127.0.0.1:8080").Handler()
+
+	rec, _ := request(t, h, "POST", "/api/jobs", `{"urls":["https://music.apple.com/cn/album/x/1"],"formats":"ATMOS, aac","skip":"2-3"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("submit = %d %s", rec.Code, rec.Body)
+	}
+	items := q.Items()
+	if len(items) != 1 || !reflect.DeepEqual(items[0].Formats, []string{"atmos", "aac"}) || !items[0].Skip[2] || !items[0].Skip[3] {
+		t.Fatalf("items = %+v", items)
+	}
+
+	for _, body := range []string{`{"urls":["https://example.com/x"]}`, `{"urls":[]}`, `{"urls":["https://music.apple.com/x"],"formats":"flac"}`} {
+		if rec, _ := request(t, h, "POST", "/api/jobs", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("submit %s = %d", body, rec.Code)
+		}
+	}
+
+	if rec, _ := request(t, h, "GET", "/api/jobs/1/report", ""); rec.Code != http.StatusConflict {
+		t.Errorf("report of pending job = %d", rec.Code)
+	}
+	if rec, out := request(t, h, "POST", "/api/jobs/1/cancel", ""); rec.Code != http.StatusOK || out["state"] != "canceled" {
+		t.Errorf("cancel = %d %v", rec.Code, out)
+	}
+	if rec, out := request(t, h, "GET", "/api/jobs/1/report", ""); rec.Code != http.StatusOK || out["state"] != "canceled" || out["total"] != float64(0) {
+		t.Errorf("report = %d %v", rec.Code, out)
+	}
+	if rec, _ := request(t, h, "GET", "/api/jobs/9", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("missing job = %d", rec.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/api/jobs", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("without token = %d", rec.Code)
+	}
+	req = httptest.NewRequest("GET", "/", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "EventSource") {
+		t.Errorf("index = %d", rec.Code)
+	}
+}
+
+func TestRejectsCrossSiteRequests(t *testing.T) {
+	q := queue.New()
+	h := New(q, "", "127.0.0.1:8080").Handler()
+	body := `{"urls":["https://music.apple.com/cn/album/x/1"]}`
+
+	for name, c := range map[string]struct {
+		host, contentType string
+		headers           map[string]string
+		want              int
+	}{
+		"same origin":     {"127.0.0.1:8080", "application/json", map[string]string{"Origin": "http://127.0.0.1:8080", "Sec-Fetch-Site": "same-origin"}, http.StatusCreated},
+		"curl":            {"localhost:8080", "application/json; charset=utf-8", nil, http.StatusCreated},
+		"form post":       {"127.0.0.1:8080", "text/plain", nil, http.StatusUnsupportedMediaType},
+		"no content type": {"127.0.0.1:8080", "", nil, http.StatusUnsupportedMediaType},
+		"other origin":    {"127.0.0.1:8080", "application/json", map[string]string{"Origin": "https://evil.example"}, http.StatusForbidden},
+		"cross site":      {"127.0.0.1:8080", "application/json", map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+		"dns rebinding":   {"evil.example:8080", "application/json", map[string]string{"Origin": "http://evil.example:8080"}, http.StatusForbidden},
+	} {
+		req := httptest.NewRequest("POST", "/api/jobs", strings.NewReader(body))
+		req.Host = c.host
+		if c.contentType != "" {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+		for k, v := range c.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.want {
+			t.Errorf("%s: status = %d, want %d (%s)", name, rec.Code, c.want, rec.Body)
+		}
+	}
+	if n := len(q.Items()); n != 2 {
+		t.Errorf("queued %d jobs, want 2", n)
+	}
+
+	// 设置令牌后可以用主机名访问（如局域网中的 nas.local）
+	h = New(queue.New(), "secret", "0.0.0.0:8080").Handler()
+	req := httptest.NewRequest("GET", "/api/jobs", nil)
+	req.Host = "nas.local:8080"
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("hostname with token = %d", rec.Code)
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:8080": true,
+		"localhost:80":   true,
+		"[::1]:8080":     true,
+		"0.0.0.0:8080":   false,
+		":8080":          false,
+		"192.168.1.2:80": false,
+	} {
+		if got := IsLoopback(addr); got != want {
+			t.Errorf("IsLoopback(%q) = %v", addr, got)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package tui
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// 特殊按键名称，普通字符以其本身表示
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyPageUp    = "pgup"
+	keyPageDown  = "pgdn"
+	keyTab       = "tab"
+	keyShiftTab  = "shift-tab"
+	keyEnter     = "enter"
+	keyEsc       = "esc"
+	keyBackspace = "backspace"
+	keyCtrlC     = "ctrl-c"
+)
+
+// parseKeys 把一次读取到的原始输入拆分为按键
+// 粘贴的文本会拆成多个字符按键，由输入框逐个追加
+func parseKeys(buf []byte) []string {
+	var keys []string
+	for i := 0; i < len(buf); {
+		b := buf[i]
+		switch {
+		case b == 0x1b:
+			if i+1 >= len(buf) {
+				keys = append(keys, keyEsc)
+				i++
+				continue
+			}
+			if buf[i+1] != '[' && buf[i+1] != 'O' {
+				keys = append(keys, keyEsc)
+				i++
+				continue
+			}
+			// CSI / SS3 序列：以 0x40-0x7E 之间的字节结束
+			j := i + 2
+			for j < len(buf) && (buf[j] < 0x40 || buf[j] > 0x7e) {
+				j++
+			}
+			if j >= len(buf) {
+				i = len(buf)
+				continue
+			}
+			switch string(buf[i+2 : j+1]) {
+			case "A":
+				keys = append(keys, keyUp)
+			case "B":
+				keys = append(keys, keyDown)
+			case "Z":
+				keys = append(keys, keyShiftTab)
+			case "5~":
+				keys = append(keys, keyPageUp)
+			case "6~":
+				keys = append(keys, keyPageDown)
+			}
+			i = j + 1
+		case b == '\r' || b == '\n':
+			keys = append(keys, keyEnter)
+			i++
+		case b == '\t':
+			keys = append(keys, keyTab)
+			i++
+		case b == 0x7f || b == 0x08:
+			keys = append(keys, keyBackspace)
+			i++
+		case b == 0x03:
+			keys = append(keys, keyCtrlC)
+			i++
+		case b < 0x20:
+			i++
+		default:
+			r, size := utf8.DecodeRune(buf[i:])
+			if r != utf8.RuneError {
+				keys = append(keys, string(r))
+			}
+			i += size
+		}
+	}
+	return keys
+}
+
+// readKeys 持续读取输入并发送按键，读取失败时关闭通道
+func readKeys(r io.Reader, out chan<- string) {
+	defer close(out)
+	buf := make([]byte, 1024)
+	for {
+		n, err := r.Read(buf)
+		for _, k := range parseKeys(buf[:n]) {
+			out <- k
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package tui
+
+import (
+	"bufio"
+	"io"
+	"main/internal/logger"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// maxLogLines 日志面板保留的行数
+const maxLogLines = 1000
+
+// ansiCode 终端控制序列（颜色、光标移动、清行）
+var ansiCode = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// logBuffer 保存捕获到的输出，供日志面板显示
+type logBuffer struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+// add 追加一行，去掉控制序列；\r 覆盖的进度行只保留最后一段
+func (b *logBuffer) add(line string) {
+	if i := strings.LastIndex(strings.TrimRight(line, "\r"), "\r"); i >= 0 {
+		line = line[i+1:]
+	}
+	line = strings.TrimRight(ansiCode.ReplaceAllString(line, ""), "\r ")
+	if line == "" {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines = append(b.lines, line)
+	if len(b.lines) > maxLogLines {
+		b.lines = append([]string(nil), b.lines[len(b.lines)-maxLogLines:]...)
+	}
+}
+
+// tail 返回最后 n 行
+func (b *logBuffer) tail(n int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n > len(b.lines) {
+		n = len(b.lines)
+	}
+	return append([]string(nil), b.lines[len(b.lines)-n:]...)
+}
+
+// all 返回全部行
+func (b *logBuffer) all() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.lines...)
+}
+
+// captureOutput 把标准输出、标准错误、彩色输出和 logger 重定向到日志面板，
+// 返回真实终端和恢复函数；全屏界面只向真实终端写入，其它输出不会与界面交错
+func captureOutput(buf *logBuffer) (*os.File, func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	colorOut, colorErr := color.Output, color.Error
+	logOut := logger.Output()
+
+	os.Stdout, os.Stderr = w, w
+	color.Output, color.Error = w, w
+	// 日志写入文件时保持不变
+	if logOut == stdout || logOut == stderr {
+		logger.SetOutput(w)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			buf.add(strings.TrimSuffix(line, "\n"))
+			if err != nil {
+				if err != io.EOF {
+					buf.add(err.Error())
+				}
+				return
+			}
+		}
+	}()
+
+	restore := func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		color.Output, color.Error = colorOut, colorErr
+		if logOut == stdout || logOut == stderr {
+			logger.SetOutput(logOut)
+		}
+		w.Close()
+		<-done
+		r.Close()
+	}
+	return stdout, restore, nil
+}
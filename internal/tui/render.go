@@ -0,0 +1,275 @@
+package tui
+
+import (
+	"fmt"
+	"main/internal/queue"
+	"main/internal/ui"
+	"sort"
+	"strings"
+
+	"github.com/rivo/uniseg"
+)
+
+const (
+	styleReset   = "\x1b[0m"
+	styleReverse = "\x1b[7m"
+	styleBold    = "\x1b[1m"
+	styleDim     = "\x1b[2m"
+)
+
+// stateIcons 队列条目状态图标
+var stateIcons = map[queue.State]string{
+	queue.Pending:  "·",
+	queue.Running:  "▶",
+	queue.Paused:   "⏸",
+	queue.Done:     "✓",
+	queue.Failed:   "✗",
+	queue.Canceled: "⊘",
+}
+
+// helpText 底部的按键说明
+const helpText = "Tab 切换面板  ↑↓ 选择  K/J 调整顺序  p 暂停/恢复  x 取消  r 重试  s 跳过曲目  空格 切换曲目  Enter 详情  a 添加  q 退出"
+
+// fit 按显示宽度截断或补齐到 width
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	var b strings.Builder
+	used := 0
+	state := -1
+	rest := s
+	for len(rest) > 0 {
+		var cluster string
+		var w int
+		cluster, rest, w, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		if used+w > width {
+			break
+		}
+		b.WriteString(cluster)
+		used += w
+	}
+	if used < width {
+		b.WriteString(strings.Repeat(" ", width-used))
+	}
+	return b.String()
+}
+
+// scroll 调整滚动位置使光标可见
+func scroll(top, cursor, height, total int) int {
+	if cursor < top {
+		top = cursor
+	}
+	if cursor >= top+height {
+		top = cursor - height + 1
+	}
+	if top > total-height {
+		top = total - height
+	}
+	if top < 0 {
+		top = 0
+	}
+	return top
+}
+
+// render 生成整屏内容：标题栏、队列、当前曲目、日志（或详情）和底部栏
+func (a *app) render(width, height int) string {
+	items := a.q.Items()
+	tracks := trackStatuses()
+	running, hasRunning := a.q.Running()
+	if a.queueCursor >= len(items) {
+		a.queueCursor = len(items) - 1
+	}
+	if a.queueCursor < 0 {
+		a.queueCursor = 0
+	}
+	if a.trackCursor >= len(tracks) {
+		a.trackCursor = len(tracks) - 1
+	}
+	if a.trackCursor < 0 {
+		a.trackCursor = 0
+	}
+
+	// 标题栏、三个面板标题和底部栏各占一行
+	avail := height - 5
+	if avail < 3 {
+		avail = 3
+	}
+	queueHeight := avail * 3 / 10
+	trackHeight := avail * 35 / 100
+	if queueHeight < 1 {
+		queueHeight = 1
+	}
+	if trackHeight < 1 {
+		trackHeight = 1
+	}
+	logHeight := avail - queueHeight - trackHeight
+	if logHeight < 1 {
+		logHeight = 1
+	}
+
+	var rows []string
+	line := func(style, s string) {
+		if style == "" {
+			rows = append(rows, fit(s, width))
+		} else {
+			rows = append(rows, style+fit(s, width)+styleReset)
+		}
+	}
+	title := func(name string, focused bool) {
+		s := "── " + name + " " + strings.Repeat("─", width)
+		if focused {
+			line(styleBold, s)
+		} else {
+			line(styleDim, s)
+		}
+	}
+
+	// 标题栏
+	counts := make(map[queue.State]int)
+	for _, it := range items {
+		counts[it.State]++
+	}
+	header := fmt.Sprintf(" 下载队列  完成 %d/%d", counts[queue.Done], len(items))
+	if counts[queue.Failed] > 0 {
+		header += fmt.Sprintf("  失败 %d", counts[queue.Failed])
+	}
+	if hasRunning {
+		header += "  " + ui.FormatJobLine(ui.Job.Snapshot(), width)
+	}
+	line(styleReverse, header)
+
+	// 队列
+	title(fmt.Sprintf("队列 (%d)", len(items)), a.focus == paneQueue)
+	a.queueTop = scroll(a.queueTop, a.queueCursor, queueHeight, len(items))
+	for i := 0; i < queueHeight; i++ {
+		idx := a.queueTop + i
+		if idx >= len(items) {
+			line("", "")
+			continue
+		}
+		it := items[idx]
+		name := it.Title
+		if name == "" && hasRunning && it.ID == running.ID {
+			name = ui.Job.Snapshot().Album
+		}
+		if name == "" {
+			name = it.URL
+		}
+		s := fmt.Sprintf(" %s #%d %s  [%s]", stateIcons[it.State], it.ID, name, queue.StateNames[it.State])
+		if n := len(it.Skip); n > 0 {
+			s += fmt.Sprintf(" 跳过 %d 首", n)
+		}
+		if n := len(it.TrackErrors); n > 0 {
+			s += fmt.Sprintf(" %d 首出错", n)
+		}
+		if it.Error != "" {
+			s += " " + it.Error
+		}
+		if idx == a.queueCursor && a.focus == paneQueue {
+			line(styleReverse, s)
+		} else {
+			line("", s)
+		}
+	}
+
+	// 当前曲目
+	trackTitle := "当前曲目"
+	if hasRunning {
+		trackTitle += fmt.Sprintf(" #%d", running.ID)
+	}
+	title(trackTitle, a.focus == paneTracks)
+	a.trackTop = scroll(a.trackTop, a.trackCursor, trackHeight, len(tracks))
+	for i := 0; i < trackHeight; i++ {
+		idx := a.trackTop + i
+		if idx >= len(tracks) || !hasRunning {
+			line("", "")
+			continue
+		}
+		ts := tracks[idx]
+		mark := "  "
+		if running.Skip[ts.TrackNum] {
+			mark = "⊘ "
+		}
+		s := " " + mark + ui.FormatTrackLine(ts, width-3)
+		if idx == a.trackCursor && a.focus == paneTracks {
+			line(styleReverse, s)
+		} else {
+			line("", s)
+		}
+	}
+
+	// 日志或详情
+	var body []string
+	if a.detail != 0 {
+		title(fmt.Sprintf("详情 #%d（Esc 关闭）", a.detail), false)
+		body = detailLines(items, a.detail)
+		if len(body) > logHeight {
+			body = body[:logHeight]
+		}
+	} else {
+		title("日志", false)
+		body = a.logs.tail(logHeight)
+	}
+	for i := 0; i < logHeight; i++ {
+		if i < len(body) {
+			line("", " "+body[i])
+		} else {
+			line("", "")
+		}
+	}
+
+	// 底部栏：输入框、提示消息或按键说明
+	switch {
+	case a.input != nil:
+		line("", a.input.prompt+string(a.input.text)+"█")
+	case a.message != "":
+		line(styleBold, a.message)
+	default:
+		line(styleDim, helpText)
+	}
+
+	if len(rows) > height {
+		rows = rows[:height]
+	}
+	return "\x1b[H" + strings.Join(rows, "\r\n")
+}
+
+// detailLines 条目详情：链接、状态、错误和每首曲目的错误
+func detailLines(items []queue.Item, id int) []string {
+	for _, it := range items {
+		if it.ID != id {
+			continue
+		}
+		lines := []string{
+			"链接: " + it.URL,
+			"状态: " + queue.StateNames[it.State],
+		}
+		if it.Title != "" {
+			lines = append(lines, "专辑: "+it.Title)
+		}
+		if len(it.Skip) > 0 {
+			var nums []int
+			for n := range it.Skip {
+				nums = append(nums, n)
+			}
+			sort.Ints(nums)
+			skipped := make([]string, len(nums))
+			for i, n := range nums {
+				skipped[i] = fmt.Sprint(n)
+			}
+			lines = append(lines, "跳过曲目: "+strings.Join(skipped, ","))
+		}
+		if it.Error != "" {
+			lines = append(lines, "错误: "+it.Error)
+		}
+		if len(it.TrackErrors) == 0 {
+			lines = append(lines, "没有曲目错误")
+		}
+		for _, e := range it.TrackErrors {
+			lines = append(lines, fmt.Sprintf("  [%d] %s: %s", e.TrackNum, e.Name, e.Error))
+		}
+		return lines
+	}
+	return []string{"条目不存在"}
+}
@@ -0,0 +1,287 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"main/internal/core"
+	"main/internal/progress"
+	"main/internal/queue"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// refreshInterval 界面刷新间隔
+const refreshInterval = 250 * time.Millisecond
+
+// pane 可获得焦点的面板
+type pane int
+
+const (
+	paneQueue pane = iota
+	paneTracks
+)
+
+// inputState 底部输入框
+type inputState struct {
+	prompt string
+	text   []rune
+	submit func(string)
+}
+
+// app 全屏队列管理界面的状态，只在事件循环所在的 goroutine 中访问
+type app struct {
+	q    *queue.Queue
+	logs *logBuffer
+
+	focus       pane
+	queueCursor int
+	trackCursor int
+	queueTop    int
+	trackTop    int
+
+	input     *inputState
+	detail    int // 显示详情的条目 ID，0 表示显示日志
+	message   string
+	quitArmed bool
+}
+
+// Run 接管终端显示全屏队列管理界面，直到用户退出
+// 运行期间标准输出、标准错误和 logger 的内容都显示在日志面板中；下载由调用方从队列中取出执行
+func Run(q *queue.Queue) error {
+	stdinFd := int(os.Stdin.Fd())
+	if !term.IsTerminal(stdinFd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return errors.New("全屏界面需要在终端中运行")
+	}
+
+	a := &app{q: q, logs: &logBuffer{}}
+	out, restoreOutput, err := captureOutput(a.logs)
+	if err != nil {
+		return err
+	}
+	defer restoreOutput()
+
+	oldState, err := term.MakeRaw(stdinFd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(stdinFd, oldState)
+
+	// 备用屏幕缓冲区 + 隐藏光标，退出后恢复原来的终端内容
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan string, 64)
+	go readKeys(os.Stdin, keys)
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		width, height, err := term.GetSize(int(out.Fd()))
+		if err != nil || width <= 0 || height <= 0 {
+			width, height = 80, 24
+		}
+		fmt.Fprint(out, a.render(width, height))
+
+		select {
+		case k, ok := <-keys:
+			if !ok || a.handleKey(k) {
+				return nil
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// busy 是否有条目正在下载
+func (a *app) busy() bool {
+	_, ok := a.q.Running()
+	return ok
+}
+
+// handleKey 处理按键，返回 true 表示退出
+func (a *app) handleKey(k string) bool {
+	if a.input != nil {
+		a.handleInput(k)
+		return false
+	}
+
+	if k == "q" || k == keyCtrlC {
+		if a.busy() && !a.quitArmed {
+			a.quitArmed = true
+			a.message = "下载仍在进行，再按一次 q 退出（正在下载的曲目会中断）"
+			return false
+		}
+		return true
+	}
+	a.quitArmed = false
+	a.message = ""
+
+	items := a.q.Items()
+	var selected *queue.Item
+	if a.queueCursor < len(items) {
+		selected = &items[a.queueCursor]
+	}
+
+	switch k {
+	case keyTab, keyShiftTab:
+		if a.focus == paneQueue {
+			a.focus = paneTracks
+		} else {
+			a.focus = paneQueue
+		}
+		return false
+	case "a":
+		a.input = &inputState{prompt: "添加链接（多个用空格分隔）: ", submit: func(s string) {
+			for _, url := range strings.Fields(s) {
+				a.q.Add(url)
+			}
+		}}
+		return false
+	case keyEsc:
+		a.detail = 0
+		return false
+	}
+
+	if a.focus == paneTracks {
+		a.handleTrackKey(k)
+		return false
+	}
+
+	switch k {
+	case keyUp, "k":
+		if a.queueCursor > 0 {
+			a.queueCursor--
+		}
+	case keyDown, "j":
+		if a.queueCursor < len(items)-1 {
+			a.queueCursor++
+		}
+	case "K":
+		if selected != nil && a.q.Move(selected.ID, -1) {
+			a.queueCursor--
+		}
+	case "J":
+		if selected != nil && a.q.Move(selected.ID, 1) {
+			a.queueCursor++
+		}
+	case "p":
+		if selected != nil {
+			a.q.TogglePause(selected.ID)
+		}
+	case "x":
+		if selected != nil {
+			a.q.Cancel(selected.ID)
+		}
+	case "r":
+		if selected != nil {
+			a.q.Retry(selected.ID)
+		}
+	case "s":
+		if selected != nil {
+			id := selected.ID
+			a.input = &inputState{prompt: "跳过曲目（如 1-3,5，留空表示全部下载）: ", submit: func(s string) {
+				tracks, err := queue.ParseTracks(s)
+				if err != nil {
+					a.message = err.Error()
+					return
+				}
+				a.q.SetSkip(id, tracks)
+			}}
+		}
+	case keyEnter:
+		if selected != nil {
+			if a.detail == selected.ID {
+				a.detail = 0
+			} else {
+				a.detail = selected.ID
+			}
+		}
+	}
+	return false
+}
+
+// handleTrackKey 当前曲目面板的按键
+func (a *app) handleTrackKey(k string) {
+	tracks := trackStatuses()
+	switch k {
+	case keyUp, "k":
+		if a.trackCursor > 0 {
+			a.trackCursor--
+		}
+	case keyDown, "j":
+		if a.trackCursor < len(tracks)-1 {
+			a.trackCursor++
+		}
+	case " ":
+		running, ok := a.q.Running()
+		if ok && a.trackCursor < len(tracks) {
+			a.q.ToggleTrack(running.ID, tracks[a.trackCursor].TrackNum)
+		}
+	case keyEnter:
+		if running, ok := a.q.Running(); ok {
+			a.detail = running.ID
+		}
+	}
+}
+
+// handleInput 输入框中的按键
+func (a *app) handleInput(k string) {
+	switch k {
+	case keyEnter:
+		in := a.input
+		a.input = nil
+		in.submit(strings.TrimSpace(string(in.text)))
+	case keyEsc, keyCtrlC:
+		a.input = nil
+	case keyBackspace:
+		if n := len(a.input.text); n > 0 {
+			a.input.text = a.input.text[:n-1]
+		}
+	default:
+		if r := []rune(k); len(r) == 1 {
+			a.input.text = append(a.input.text, r[0])
+		}
+	}
+}
+
+// trackStatuses 复制当前批次的曲目状态
+func trackStatuses() []core.TrackStatus {
+	core.UiMutex.Lock()
+	defer core.UiMutex.Unlock()
+	return append([]core.TrackStatus(nil), core.TrackStatuses...)
+}
+
+// Listener 把曲目错误记录到队列中下载中的条目，供详情视图查看
+type Listener struct {
+	q *queue.Queue
+}
+
+// NewListener 创建队列错误监听器
+func NewListener(q *queue.Queue) *Listener {
+	return &Listener{q: q}
+}
+
+// OnProgress 实现 progress.ProgressListener
+func (l *Listener) OnProgress(event progress.ProgressEvent) {
+	if event.Stage == "error" && event.Error != nil {
+		l.OnError(event.TrackIndex, event.Error)
+	}
+}
+
+// OnComplete 实现 progress.ProgressListener
+func (l *Listener) OnComplete(trackIndex int) {}
+
+// OnError 实现 progress.ProgressListener
+func (l *Listener) OnError(trackIndex int, err error) {
+	e := queue.TrackError{Error: err.Error()}
+	core.UiMutex.Lock()
+	if trackIndex >= 0 && trackIndex < len(core.TrackStatuses) {
+		e.TrackNum = core.TrackStatuses[trackIndex].TrackNum
+		e.Name = core.TrackStatuses[trackIndex].TrackName
+	}
+	core.UiMutex.Unlock()
+	l.q.AddTrackError(e)
+}
@@ -0,0 +1,42 @@
+package tui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	got := parseKeys([]byte("\x1b[A\x1b[Bk\t\x1b[Z\r\x7f\x1b中 \x03"))
+	want := []string{keyUp, keyDown, "k", keyTab, keyShiftTab, keyEnter, keyBackspace, keyEsc, "中", " ", keyCtrlC}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseKeys = %q, want %q", got, want)
+	}
+}
+
+func TestFit(t *testing.T) {
+	cases := []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"abc", 5, "abc  "},
+		{"abcdef", 4, "abcd"},
+		{"中文歌名", 5, "中文 "},
+		{"", 0, ""},
+	}
+	for _, c := range cases {
+		if got := fit(c.in, c.width); got != c.want {
+			t.Errorf("fit(%q, %d) = %q, want %q", c.in, c.width, got, c.want)
+		}
+	}
+}
+
+func TestLogBuffer(t *testing.T) {
+	var b logBuffer
+	b.add("\x1b[32mdone\x1b[0m")
+	b.add("下载中 10%\r下载中 100%")
+	b.add("\x1b[2A")
+	if got := b.all(); !reflect.DeepEqual(got, []string{"done", "下载中 100%"}) {
+		t.Errorf("lines = %q", got)
+	}
+}
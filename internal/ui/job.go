@@ -0,0 +1,85 @@
+package ui
+
+import (
+	"fmt"
+	"main/internal/progress"
+	"strings"
+	"time"
+)
+
+// Job 整个下载任务的进度汇总，作为监听器加入 ProgressNotifier，
+// 任务/专辑/批次的边界由 main 和 downloader 通知，PrintUI 在曲目列表上方渲染汇总行
+var Job = progress.NewJobTracker()
+
+// FormatJobLine 格式化任务汇总行（自适应终端宽度）
+// 完整模式: 📊 [12/300] Kind of Blue 5/14 首 | 12.3 MB/s | 已下载 1.2 GB | 剩余约 2h13m (含 2 次休息)
+func FormatJobLine(s progress.JobSnapshot, termWidth int) string {
+	mode := GetDisplayMode(termWidth)
+
+	var parts []string
+	head := "📊"
+	if s.Total > 1 {
+		head += fmt.Sprintf(" [%d/%d]", s.Position, s.Total)
+	}
+	if s.AlbumTotal > 0 {
+		if mode == FullMode && s.Album != "" {
+			head += " " + simplifyTrackName(s.Album, 30)
+		}
+		head += fmt.Sprintf(" %d/%d 首", s.AlbumDone, s.AlbumTotal)
+	}
+	parts = append(parts, head)
+	parts = append(parts, formatSpeed(s.SpeedBPS))
+	if mode == FullMode {
+		parts = append(parts, "已下载 "+formatBytes(s.Bytes))
+	}
+
+	switch {
+	case s.Resting:
+		parts = append(parts, "休息中，剩余约 "+formatDuration(s.ETA))
+	case s.ETA < 0:
+		parts = append(parts, "剩余时间计算中")
+	case s.Rests > 0 && mode != MinimalMode:
+		parts = append(parts, fmt.Sprintf("剩余约 %s (含 %d 次休息)", formatDuration(s.ETA), s.Rests))
+	default:
+		parts = append(parts, "剩余约 "+formatDuration(s.ETA))
+	}
+
+	line := strings.Join(parts, " | ")
+	if getVisualLength(line) > termWidth-2 {
+		line = truncateToWidth(line, termWidth-2)
+	}
+	return line
+}
+
+// formatBytes 格式化字节数
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n) / unit
+	for _, suffix := range []string{"KB", "MB", "GB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+		value /= unit
+	}
+	return fmt.Sprintf("%.1f TB", value)
+}
+
+// formatDuration 格式化时长：1h02m / 5m30s / 45s
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		return "-"
+	}
+	d = d.Round(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%dh%02dm", h, m)
+	case m > 0:
+		return fmt.Sprintf("%dm%02ds", m, s)
+	default:
+		return fmt.Sprintf("%ds", s)
+	}
+}
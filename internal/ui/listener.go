@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fmt"
+	"main/internal/core"
 	"main/internal/progress"
 
 	"github.com/fatih/color"
@@ -21,6 +22,9 @@ func NewUIProgressListener() *UIProgressListener {
 
 // OnProgress 处理进度更新事件
 func (l *UIProgressListener) OnProgress(event progress.ProgressEvent) {
+	if event.TrackIndex < 0 {
+		return
+	}
 	status := formatStatus(event)
 	colorFunc := getColorFunc(event.Stage)
 	UpdateStatus(event.TrackIndex, status, colorFunc)
@@ -136,3 +140,14 @@ func truncateError(err error) string {
 	}
 	return msg
 }
+
+// TrackInfo 根据批次内索引返回曲目编号和名称，供 JSON 等监听器使用
+func TrackInfo(index int) (progress.TrackInfo, bool) {
+	core.UiMutex.Lock()
+	defer core.UiMutex.Unlock()
+	if index < 0 || index >= len(core.TrackStatuses) {
+		return progress.TrackInfo{}, false
+	}
+	ts := core.TrackStatuses[index]
+	return progress.TrackInfo{Num: ts.TrackNum, Name: ts.TrackName}, true
+}
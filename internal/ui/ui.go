@@ -87,18 +87,23 @@ func PrintUI(isFirstUpdate bool) {
 
 	var builder strings.Builder
 
+	// 曲目行上方有一行任务汇总
+	lines := len(core.TrackStatuses) + 1
+
 	// 首次更新时打印占位换行符，后续更新时向上移动光标
 	if isFirstUpdate {
-		builder.WriteString(strings.Repeat("\n", len(core.TrackStatuses)))
+		builder.WriteString(strings.Repeat("\n", lines))
 	}
 
-	// 向上移动N行（N = 曲目数）
+	// 向上移动N行（N = 曲目数 + 汇总行）
 	// 新的formatter保证每个track只占一行，不会换行
-	builder.WriteString(fmt.Sprintf("\033[%dA", len(core.TrackStatuses)))
+	builder.WriteString(fmt.Sprintf("\033[%dA", lines))
 
 	// 获取终端宽度，用于智能格式化
 	terminalWidth := getTerminalWidth()
 
+	builder.WriteString(fmt.Sprintf("\r\033[K%s\n", color.New(color.FgCyan).Sprint(FormatJobLine(Job.Snapshot(), terminalWidth))))
+
 	// 使用新的智能格式化系统
 	for _, ts := range core.TrackStatuses {
 		// 1. 格式化曲目行（自动适应终端宽度，保证不换行）
@@ -118,7 +123,7 @@ func PrintUI(isFirstUpdate bool) {
 func UpdateStatus(index int, status string, sColor func(a ...interface{}) string) {
 	core.UiMutex.Lock()
 	defer core.UiMutex.Unlock()
-	if index < len(core.TrackStatuses) {
+	if index >= 0 && index < len(core.TrackStatuses) {
 		core.TrackStatuses[index].Status = status
 		core.TrackStatuses[index].StatusColor = sColor
 	}
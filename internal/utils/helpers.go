@@ -7,85 +7,12 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
-
-	"main/internal/core"
 )
 
 // EnsureSafePath truncates path components to ensure the total path length does not exceed the limit
+// See EnsureSafeTrackPath for the rules applied
 func EnsureSafePath(basePath, artistDir, albumDir, fileName string) (string, string, string) {
-	truncate := func(s string, n int) string {
-		if n <= 0 {
-			return s
-		}
-		runes := []rune(s)
-		if len(runes) <= n {
-			return ""
-		}
-		return string(runes[:len(runes)-n])
-	}
-
-	for {
-		currentPath := filepath.Join(basePath, artistDir, albumDir, fileName)
-		if len(currentPath) <= core.MaxPathLength {
-			break
-		}
-
-		overage := len(currentPath) - core.MaxPathLength
-		ext := filepath.Ext(fileName)
-		stem := strings.TrimSuffix(fileName, ext)
-
-		var prefixPart string
-		var namePart string
-		re := regexp.MustCompile(`^(\d+[\s.-]*)`)
-		matches := re.FindStringSubmatch(stem)
-
-		if len(matches) > 1 {
-			prefixPart = matches[1]
-			namePart = strings.TrimPrefix(stem, prefixPart)
-		} else {
-			prefixPart = ""
-			namePart = stem
-		}
-
-		if len(namePart) > 0 {
-			canShorten := len(namePart)
-			shortenAmount := overage
-			if shortenAmount > canShorten {
-				shortenAmount = canShorten
-			}
-			namePart = truncate(namePart, shortenAmount)
-
-			if namePart == "" {
-				prefixPart = strings.TrimRight(prefixPart, " .-")
-			}
-
-			fileName = prefixPart + namePart + ext
-			continue
-		}
-
-		if len(albumDir) > 1 { // 至少保留一个字符
-			canShorten := len(albumDir)
-			shortenAmount := overage
-			if shortenAmount > canShorten {
-				shortenAmount = canShorten
-			}
-			albumDir = truncate(albumDir, shortenAmount)
-			continue
-		}
-
-		if len(artistDir) > 1 { // 至少保留一个字符
-			canShorten := len(artistDir)
-			shortenAmount := overage
-			if shortenAmount > canShorten {
-				shortenAmount = canShorten
-			}
-			artistDir = truncate(artistDir, shortenAmount)
-			continue
-		}
-
-		break
-	}
-
+	artistDir, albumDir, _, fileName = EnsureSafeTrackPath(basePath, artistDir, albumDir, "", fileName)
 	return artistDir, albumDir, fileName
 }
 
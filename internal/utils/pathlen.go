@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/rivo/uniseg"
+
+	"main/internal/core"
+)
+
+// trackNumberPrefix 匹配文件名开头的曲目编号（如 "01. "、"1-02 "），截断时保留
+var trackNumberPrefix = regexp.MustCompile(`^(\d+[\s.-]*)`)
+
+// truncatedHashLength 截断名称后追加的哈希长度（十六进制字符数）
+const truncatedHashLength = 6
+
+// existingHashSuffix 匹配名称末尾已有的哈希后缀（之前截断或转写时追加）
+var existingHashSuffix = regexp.MustCompile(fmt.Sprintf(` ?~[0-9a-f]{%d}$`, truncatedHashLength))
+
+// pathUnits 按目标文件系统的计量单位返回字符串长度
+// Windows/NTFS 按 UTF-16 编码单元计算，ext4/btrfs 等按 UTF-8 字节计算
+func pathUnits(s string) int {
+	if core.PathLengthUnit == "utf16" {
+		return len(utf16.Encode([]rune(s)))
+	}
+	return len(s)
+}
+
+// EnsureSafeTrackPath is EnsureSafePath with an optional disc sub-folder between the album folder and the file.
+// Every component is cleaned with SanitizeComponent, kept within the per-component limit
+// (core.MaxComponentLength) and then shortened until the whole path fits core.MaxPathLength.
+// Lengths are measured in the target filesystem's unit. The disc folder is never shortened
+// for the total limit, but it counts toward it.
+func EnsureSafeTrackPath(basePath, artistDir, albumDir, discDir, fileName string) (string, string, string, string) {
+	artistDir = truncateComponent(SanitizeComponent(artistDir), core.MaxComponentLength, false)
+	albumDir = truncateComponent(SanitizeComponent(albumDir), core.MaxComponentLength, false)
+	discDir = truncateComponent(SanitizeComponent(discDir), core.MaxComponentLength, false)
+	fileName = truncateComponent(SanitizeComponent(fileName), core.MaxComponentLength, true)
+
+	overage := func() int {
+		return pathUnits(filepath.Join(basePath, artistDir, albumDir, discDir, fileName)) - core.MaxPathLength
+	}
+
+	// 依次缩短文件名、专辑文件夹、歌手文件夹
+	if over := overage(); over > 0 {
+		fileName = truncateComponent(fileName, pathUnits(fileName)-over, true)
+	}
+	if over := overage(); over > 0 && albumDir != "" {
+		albumDir = truncateComponent(albumDir, pathUnits(albumDir)-over, false)
+	}
+	if over := overage(); over > 0 && artistDir != "" {
+		artistDir = truncateComponent(artistDir, pathUnits(artistDir)-over, false)
+	}
+
+	return artistDir, albumDir, discDir, fileName
+}
+
+// EnsureSafeTrackFile 在已确定的专辑文件夹 albumPath 下处理碟片子文件夹和文件名，规则同 EnsureSafeTrackPath，
+// 但只缩短文件名。专辑文件夹由调用方按整张专辑截断一次后传入，保证同一专辑的曲目落在同一文件夹中
+func EnsureSafeTrackFile(albumPath, discDir, fileName string) (string, string) {
+	discDir = truncateComponent(SanitizeComponent(discDir), core.MaxComponentLength, false)
+	fileName = truncateComponent(SanitizeComponent(fileName), core.MaxComponentLength, true)
+	if over := pathUnits(filepath.Join(albumPath, discDir, fileName)) - core.MaxPathLength; over > 0 {
+		fileName = truncateComponent(fileName, pathUnits(fileName)-over, true)
+	}
+	return discDir, fileName
+}
+
+// hashSuffix 返回名称的短哈希后缀（如 "~1a2b3c"），用于区分截断或转写后可能重名的名称
+func hashSuffix(name string) string {
+	sum := sha1.Sum([]byte(name))
+	return "~" + hex.EncodeToString(sum[:])[:truncatedHashLength]
+}
+
+// truncateComponent 将单个路径组件缩短到 limit 以内（按 pathUnits 计量）
+// 截断在字素簇边界进行，不会拆开组合字符或 emoji；被截断的名称追加原名称的短哈希，
+// 避免多个长名称截断后重名。isFile 为 true 时保留曲目编号前缀和扩展名。
+// 已带哈希后缀的名称（如按总长度再次缩短）沿用原哈希，不追加第二个。
+// 即使已无法继续缩短（仅剩前缀、哈希和扩展名），也返回尽量短的结果
+func truncateComponent(name string, limit int, isFile bool) string {
+	if name == "" || pathUnits(name) <= limit {
+		return name
+	}
+
+	var prefix, ext string
+	stem := name
+	if isFile {
+		ext = filepath.Ext(name)
+		stem = strings.TrimSuffix(name, ext)
+		if matches := trackNumberPrefix.FindStringSubmatch(stem); len(matches) > 1 {
+			prefix = matches[1]
+			stem = strings.TrimPrefix(stem, prefix)
+		}
+	}
+
+	suffix := hashSuffix(name)
+	if existing := existingHashSuffix.FindString(stem); existing != "" {
+		suffix = strings.TrimPrefix(existing, " ")
+		stem = strings.TrimSuffix(stem, existing)
+	}
+
+	// 预留名称与哈希之间的一个空格
+	budget := limit - pathUnits(prefix) - pathUnits(suffix) - pathUnits(ext) - 1
+	var kept strings.Builder
+	used := 0
+	graphemes := uniseg.NewGraphemes(stem)
+	for graphemes.Next() {
+		cluster := graphemes.Str()
+		units := pathUnits(cluster)
+		if used+units > budget {
+			break
+		}
+		kept.WriteString(cluster)
+		used += units
+	}
+
+	// 去除截断处残留的空格和分隔符，Windows 不允许名称以点或空格结尾
+	stem = strings.TrimRight(kept.String(), " .-_")
+	if stem == "" {
+		prefix = strings.TrimRight(prefix, " .-")
+	} else {
+		stem += " "
+	}
+	return prefix + stem + suffix + ext
+}
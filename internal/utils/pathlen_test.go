@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"main/internal/core"
+)
+
+func setPathLimits(t *testing.T, total, component int, unit string) {
+	t.Helper()
+	oldTotal, oldComponent, oldUnit := core.MaxPathLength, core.MaxComponentLength, core.PathLengthUnit
+	core.MaxPathLength, core.MaxComponentLength, core.PathLengthUnit = total, component, unit
+	t.Cleanup(func() {
+		core.MaxPathLength, core.MaxComponentLength, core.PathLengthUnit = oldTotal, oldComponent, oldUnit
+	})
+}
+
+func TestEnsureSafeTrackPathComponentLimit(t *testing.T) {
+	setPathLimits(t, 4096, 255, "bytes")
+
+	// 100 个汉字 = 300 字节，超过 255 字节的单组件限制
+	album := strings.Repeat("专", 100)
+	file := "01. " + strings.Repeat("曲", 100) + ".m4a"
+	_, albumDir, _, fileName := EnsureSafeTrackPath("/music", "Artist", album, "", file)
+
+	if len(albumDir) > 255 {
+		t.Errorf("album dir is %d bytes, want <= 255", len(albumDir))
+	}
+	if len(fileName) > 255 {
+		t.Errorf("file name is %d bytes, want <= 255", len(fileName))
+	}
+	if !utf8.ValidString(albumDir) || !utf8.ValidString(fileName) {
+		t.Errorf("truncation produced invalid UTF-8: %q / %q", albumDir, fileName)
+	}
+	if !strings.HasPrefix(fileName, "01. ") || !strings.HasSuffix(fileName, ".m4a") {
+		t.Errorf("track number prefix or extension lost: %q", fileName)
+	}
+}
+
+func TestEnsureSafeTrackPathTotalLimit(t *testing.T) {
+	setPathLimits(t, 120, 255, "bytes")
+
+	base := "/music"
+	artistDir, albumDir, discDir, fileName := EnsureSafeTrackPath(base, "Artist", strings.Repeat("Album ", 10), "Disc 1", "02. "+strings.Repeat("Song ", 20)+".m4a")
+	fullPath := filepath.Join(base, artistDir, albumDir, discDir, fileName)
+
+	if len(fullPath) > 120 {
+		t.Errorf("path is %d bytes, want <= 120: %q", len(fullPath), fullPath)
+	}
+	if discDir != "Disc 1" {
+		t.Errorf("disc dir changed to %q", discDir)
+	}
+	if !strings.HasPrefix(fileName, "02. ") || !strings.HasSuffix(fileName, ".m4a") {
+		t.Errorf("track number prefix or extension lost: %q", fileName)
+	}
+}
+
+func TestTruncateComponentAvoidsCollisions(t *testing.T) {
+	setPathLimits(t, 4096, 40, "bytes")
+
+	a := truncateComponent("01. "+strings.Repeat("x", 60)+" (Live).m4a", 40, true)
+	b := truncateComponent("01. "+strings.Repeat("x", 60)+" (Demo).m4a", 40, true)
+	if a == b {
+		t.Errorf("truncated names collide: %q", a)
+	}
+	if len(a) > 40 || len(b) > 40 {
+		t.Errorf("truncated names exceed limit: %q (%d), %q (%d)", a, len(a), b, len(b))
+	}
+}
+
+func TestTruncateComponentKeepsSingleHash(t *testing.T) {
+	setPathLimits(t, 4096, 255, "bytes")
+
+	name := "01. " + strings.Repeat("Song ", 20) + ".m4a"
+	once := truncateComponent(name, 60, true)
+	twice := truncateComponent(once, 40, true)
+	if strings.Count(twice, "~") != 1 || len(twice) > 40 {
+		t.Errorf("re-truncated name = %q", twice)
+	}
+	if once[len(once)-len("~123456.m4a"):] != twice[len(twice)-len("~123456.m4a"):] {
+		t.Errorf("hash changed: %q -> %q", once, twice)
+	}
+}
+
+func TestEnsureSafeTrackFileKeepsAlbumDir(t *testing.T) {
+	setPathLimits(t, 120, 255, "bytes")
+
+	albumPath := filepath.Join("/music", "Artist", strings.Repeat("Album ", 10))
+	discDir, fileName := EnsureSafeTrackFile(albumPath, "Disc 1", "02. "+strings.Repeat("Song ", 20)+".m4a")
+	if fullPath := filepath.Join(albumPath, discDir, fileName); len(fullPath) > 120 {
+		t.Errorf("path is %d bytes, want <= 120: %q", len(fullPath), fullPath)
+	}
+	if discDir != "Disc 1" || !strings.HasPrefix(fileName, "02. ") {
+		t.Errorf("disc dir %q, file name %q", discDir, fileName)
+	}
+}
+
+func TestTruncateComponentKeepsGraphemes(t *testing.T) {
+	setPathLimits(t, 4096, 255, "bytes")
+
+	// 每个家庭 emoji 是一个字素簇，由多个码点组成
+	family := "👨‍👩‍👧"
+	got := truncateComponent(strings.Repeat(family, 10), 60, false)
+	stem := strings.TrimSpace(got[:strings.LastIndex(got, "~")])
+	if strings.ReplaceAll(stem, family, "") != "" {
+		t.Errorf("grapheme cluster split: %q", got)
+	}
+}
+
+func TestPathUnitsUTF16(t *testing.T) {
+	setPathLimits(t, 255, 255, "utf16")
+
+	// "𝄞" 在 UTF-8 中为 4 字节，在 UTF-16 中为 2 个编码单元
+	if got := pathUnits("a𝄞"); got != 3 {
+		t.Errorf("pathUnits(utf16) = %d, want 3", got)
+	}
+}
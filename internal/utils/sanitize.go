@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"main/internal/core"
+)
+
+// windowsReservedNames 匹配 Windows 保留设备名（不区分大小写，可带扩展名），如 CON、AUX、COM1.m4a
+var windowsReservedNames = regexp.MustCompile(`(?i)^(CON|PRN|AUX|NUL|COM[0-9]|LPT[0-9])(\..*)?$`)
+
+// asciiReplacements 无法通过去除变音符号转换为 ASCII 的常见拉丁字母
+var asciiReplacements = strings.NewReplacer(
+	"ß", "ss", "Æ", "AE", "æ", "ae", "Œ", "OE", "œ", "oe", "Ø", "O", "ø", "o",
+	"Ł", "L", "ł", "l", "Đ", "D", "đ", "d", "Þ", "Th", "þ", "th", "Ð", "D", "ð", "d",
+	"‘", "'", "’", "'", "“", "'", "”", "'", "–", "-", "—", "-", "…", "...",
+)
+
+// SanitizeComponent 按 filename-profile 清理单个路径组件（歌手/专辑/碟片文件夹或文件名）
+// 配置项:
+//   - posix（默认）：替换非法字符并移除控制字符
+//   - windows：在 posix 基础上去除末尾的点和空格，并避开 CON、AUX 等保留名称
+//   - smb：在 windows 基础上去除开头空格，默认使用 NFC 规范化（Samba/NFS 共享常见问题）
+//   - ascii-transliterate：在 windows 基础上将名称转换为纯 ASCII
+func SanitizeComponent(name string) string {
+	if name == "" {
+		return name
+	}
+	profile := core.Config.FilenameProfile
+
+	name = normalizeUnicode(name, profile)
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = core.ForbiddenNames.ReplaceAllString(name, "_")
+
+	switch profile {
+	case "windows", "smb", "ascii-transliterate":
+		if profile == "ascii-transliterate" {
+			name = transliterateASCII(name)
+		}
+		if profile == "smb" {
+			name = strings.TrimLeft(name, " ")
+		}
+		name = strings.TrimRight(name, ". ")
+		if windowsReservedNames.MatchString(name) {
+			name = "_" + name
+		}
+		if name == "" {
+			name = "_"
+		}
+	}
+	return name
+}
+
+// normalizeUnicode 按 unicode-normalization 配置规范化名称
+// 未配置时 smb 配置项使用 NFC，其余配置项保持原样
+func normalizeUnicode(name, profile string) string {
+	form := strings.ToLower(core.Config.UnicodeNormalization)
+	if form == "" && profile == "smb" {
+		form = "nfc"
+	}
+	switch form {
+	case "nfc":
+		return norm.NFC.String(name)
+	case "nfd":
+		return norm.NFD.String(name)
+	}
+	return name
+}
+
+// shortExtension 匹配文件扩展名（如 .m4a、.lrc），转写后追加哈希时插在扩展名之前
+var shortExtension = regexp.MustCompile(`^\.[A-Za-z0-9]{1,5}$`)
+
+// transliterateASCII 将名称转换为纯 ASCII：去除变音符号，替换常见拉丁字母，其余字符替换为下划线
+// 被替换的字母和数字多于保留的时（如中文、日文、俄文名称），追加原名称的短哈希，
+// 避免不同歌手、专辑或曲目转写后重名
+func transliterateASCII(name string) string {
+	result := toASCII(name)
+	ext := filepath.Ext(name)
+	if !shortExtension.MatchString(ext) {
+		ext = ""
+	}
+	// 统计时不计曲目编号前缀和扩展名
+	title := strings.TrimSuffix(name, ext)
+	title = strings.TrimPrefix(title, trackNumberPrefix.FindString(title))
+	kept, lost := 0, 0
+	for _, r := range norm.NFD.String(asciiReplacements.Replace(title)) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			continue
+		}
+		if r < 0x80 {
+			kept++
+		} else {
+			lost++
+		}
+	}
+	if lost <= kept {
+		return result
+	}
+
+	stem := strings.Trim(strings.TrimSuffix(result, ext), " _")
+	if stem != "" {
+		stem += " "
+	}
+	return stem + hashSuffix(name) + ext
+}
+
+// toASCII 去除变音符号，替换常见拉丁字母，连续的其他非 ASCII 字符替换为一个下划线
+func toASCII(name string) string {
+	name = asciiReplacements.Replace(norm.NFD.String(name))
+	var b strings.Builder
+	lastUnderscore := false
+	for _, r := range name {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case r < 0x80:
+			b.WriteRune(r)
+			lastUnderscore = r == '_'
+		case !lastUnderscore:
+			b.WriteRune('_')
+			lastUnderscore = true
+		}
+	}
+	return b.String()
+}
+
+// FindExistingFile 检查文件是否已存在，返回实际存在的路径
+// 启用 normalize-existing-check 时，若精确路径不存在，会逐级按当前清理规则比较已有目录项，
+// 从而识别旧版本或其他客户端以不同规范化形式（如 NFD）写入的同名文件
+func FindExistingFile(path string) (string, bool, error) {
+	exists, err := FileExists(path)
+	if err != nil || exists || !core.Config.NormalizeExistingCheck {
+		return path, exists, err
+	}
+
+	// 找到最近的已存在祖先目录
+	var missing []string
+	dir := filepath.Clean(path)
+	for {
+		if info, statErr := os.Stat(dir); statErr == nil && info.IsDir() {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return path, false, nil
+		}
+		missing = append([]string{filepath.Base(dir)}, missing...)
+		dir = parent
+	}
+
+	// 逐级匹配缺失的路径组件
+	current := dir
+	for i, component := range missing {
+		entries, readErr := os.ReadDir(current)
+		if readErr != nil {
+			return path, false, nil
+		}
+		want := comparableName(component)
+		found := ""
+		for _, entry := range entries {
+			isLast := i == len(missing)-1
+			if entry.IsDir() == isLast {
+				continue
+			}
+			if comparableName(entry.Name()) == want {
+				found = entry.Name()
+				break
+			}
+		}
+		if found == "" {
+			return path, false, nil
+		}
+		current = filepath.Join(current, found)
+	}
+	return current, true, nil
+}
+
+// comparableName 返回用于存在性比较的名称：统一为 NFC 并应用当前清理规则
+func comparableName(name string) string {
+	return norm.NFC.String(SanitizeComponent(norm.NFC.String(name)))
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"regexp"
+	"testing"
+
+	"main/internal/core"
+)
+
+func setFilenameProfile(t *testing.T, profile, normalization string) {
+	t.Helper()
+	oldProfile, oldNorm := core.Config.FilenameProfile, core.Config.UnicodeNormalization
+	core.Config.FilenameProfile, core.Config.UnicodeNormalization = profile, normalization
+	t.Cleanup(func() {
+		core.Config.FilenameProfile, core.Config.UnicodeNormalization = oldProfile, oldNorm
+	})
+}
+
+func TestSanitizeComponentProfiles(t *testing.T) {
+	cases := []struct {
+		profile, in, want string
+	}{
+		{"posix", "AC/DC: Live?", "AC_DC_ Live_"},
+		{"posix", "Tab\there. ", "Tabhere. "},
+		{"windows", "Album... ", "Album"},
+		{"windows", "CON.m4a", "_CON.m4a"},
+		{"windows", "...", "_"},
+		{"smb", "  Café ", "Café"},
+		{"ascii-transliterate", "Beyoncé – Déjà Vu", "Beyonce - Deja Vu"},
+		{"ascii-transliterate", "Straße Ødegaard", "Strasse Odegaard"},
+		{"ascii-transliterate", "Love 愛", "Love _"},
+	}
+	for _, c := range cases {
+		setFilenameProfile(t, c.profile, "")
+		if got := SanitizeComponent(c.in); got != c.want {
+			t.Errorf("%s: SanitizeComponent(%q) = %q, want %q", c.profile, c.in, got, c.want)
+		}
+	}
+}
+
+func TestTransliterateKeepsNonLatinNamesDistinct(t *testing.T) {
+	setFilenameProfile(t, "ascii-transliterate", "")
+
+	hashed := regexp.MustCompile(`^~[0-9a-f]{6}$`)
+	a, b := SanitizeComponent("宇多田ヒカル"), SanitizeComponent("Земфира")
+	if !hashed.MatchString(a) || !hashed.MatchString(b) || a == b {
+		t.Errorf("artist folders = %q, %q", a, b)
+	}
+
+	f1, f2 := SanitizeComponent("01. 初恋.m4a"), SanitizeComponent("01. 花束.m4a")
+	file := regexp.MustCompile(`^01\. ~[0-9a-f]{6}\.m4a$`)
+	if !file.MatchString(f1) || !file.MatchString(f2) || f1 == f2 {
+		t.Errorf("track files = %q, %q", f1, f2)
+	}
+
+	if got := SanitizeComponent("BTS (방탄소년단)"); !regexp.MustCompile(`^BTS \(_\) ~[0-9a-f]{6}$`).MatchString(got) {
+		t.Errorf("mixed name = %q", got)
+	}
+	// 结果稳定，已存在检查才能识别之前下载的文件
+	if SanitizeComponent("宇多田ヒカル") != a {
+		t.Error("transliteration is not deterministic")
+	}
+}
+
+func TestSanitizeComponentNormalization(t *testing.T) {
+	setFilenameProfile(t, "posix", "nfc")
+	if got := SanitizeComponent("Café"); got != "Café" {
+		t.Errorf("nfc = %q", got)
+	}
+	setFilenameProfile(t, "posix", "nfd")
+	if got := SanitizeComponent("Café"); got != "Café" {
+		t.Errorf("nfd = %q", got)
+	}
+}
@@ -0,0 +1,304 @@
+// Package verify 使用 mp4ff 对下载的 M4A 文件进行完整性校验
+// 结构校验只读取 box 结构和采样表，不解码音频；只有结构正常但存在可疑之处时才需要解码检查
+package verify
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// Report 单个文件的校验结果
+type Report struct {
+	Path     string
+	Duration time.Duration // 按采样表计算的实际时长
+	Errors   []string      // 结构错误：文件已损坏或被截断
+	Warnings []string      // 可疑之处：结构正常但需要解码确认
+}
+
+// Corrupt 文件结构损坏
+func (r *Report) Corrupt() bool {
+	return len(r.Errors) > 0
+}
+
+// Suspicious 结构正常但存在可疑之处（如时长与目录不符）
+func (r *Report) Suspicious() bool {
+	return !r.Corrupt() && len(r.Warnings) > 0
+}
+
+// Summary 返回简短的问题描述
+func (r *Report) Summary() string {
+	if r.Corrupt() {
+		return strings.Join(r.Errors, "; ")
+	}
+	return strings.Join(r.Warnings, "; ")
+}
+
+func (r *Report) errorf(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+func (r *Report) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// durationTolerance 实际时长与目录时长允许的偏差：2 秒或 2%，取较大值
+func durationTolerance(expected time.Duration) time.Duration {
+	tolerance := expected / 50
+	if tolerance < 2*time.Second {
+		tolerance = 2 * time.Second
+	}
+	return tolerance
+}
+
+// File 校验文件结构，expected 为目录中的时长（durationInMillis），为 0 时不比较时长
+func File(path string, expected time.Duration) *Report {
+	r := &Report{Path: path}
+
+	f, err := os.Open(path)
+	if err != nil {
+		r.errorf("无法打开文件: %v", err)
+		return r
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		r.errorf("无法读取文件信息: %v", err)
+		return r
+	}
+	fileSize := uint64(stat.Size())
+
+	parsed, err := mp4.DecodeFile(f, mp4.WithDecodeMode(mp4.DecModeLazyMdat))
+	if err != nil {
+		r.errorf("MP4 结构解析失败: %v", err)
+		return r
+	}
+	if parsed.Size() > fileSize {
+		r.errorf("文件被截断（box 声明 %d 字节，实际 %d 字节）", parsed.Size(), fileSize)
+		return r
+	}
+
+	if parsed.IsFragmented() {
+		checkFragmented(r, parsed)
+	} else {
+		checkProgressive(r, parsed)
+	}
+
+	if !r.Corrupt() && expected > 0 && r.Duration > 0 {
+		diff := r.Duration - expected
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > durationTolerance(expected) {
+			r.warnf("时长 %s 与目录时长 %s 不符", r.Duration.Round(time.Second), expected.Round(time.Second))
+		}
+	}
+	return r
+}
+
+// audioTrak 返回第一条音轨及其 mdhd
+func audioTrak(r *Report, moov *mp4.MoovBox) (*mp4.TrakBox, *mp4.MdhdBox) {
+	if moov == nil {
+		r.errorf("缺少 moov")
+		return nil, nil
+	}
+	for _, trak := range moov.Traks {
+		if trak.Mdia == nil || trak.Mdia.Hdlr == nil || trak.Mdia.Hdlr.HandlerType != "soun" {
+			continue
+		}
+		if trak.Mdia.Mdhd == nil || trak.Mdia.Mdhd.Timescale == 0 {
+			r.errorf("音轨缺少有效的 mdhd")
+			return nil, nil
+		}
+		if trak.Mdia.Minf == nil || trak.Mdia.Minf.Stbl == nil || trak.Mdia.Minf.Stbl.Stsd == nil {
+			r.errorf("音轨缺少采样描述 (stsd)")
+			return nil, nil
+		}
+		return trak, trak.Mdia.Mdhd
+	}
+	r.errorf("文件中没有音轨")
+	return nil, nil
+}
+
+// mdatRange 一个 mdat 的有效负载范围 [start, end)
+type mdatRange struct {
+	start, end uint64
+}
+
+// payloadSize 返回 mdat 的负载大小（延迟解码时数据不在内存中，只能按 box 头计算）
+func payloadSize(mdat *mp4.MdatBox) uint64 {
+	return mdat.Size() - mdat.HeaderSize()
+}
+
+// checkProgressive 校验普通（非分片）文件：采样表之间的一致性，以及每个 chunk 都位于 mdat 内
+func checkProgressive(r *Report, parsed *mp4.File) {
+	trak, mdhd := audioTrak(r, parsed.Moov)
+	if trak == nil {
+		return
+	}
+	stbl := trak.Mdia.Minf.Stbl
+	if stbl.Stts == nil || stbl.Stsz == nil || stbl.Stsc == nil || (stbl.Stco == nil && stbl.Co64 == nil) {
+		r.errorf("采样表不完整（stts/stsz/stsc/stco）")
+		return
+	}
+
+	var mdats []mdatRange
+	for _, box := range parsed.Children {
+		if mdat, ok := box.(*mp4.MdatBox); ok {
+			mdats = append(mdats, mdatRange{start: mdat.PayloadAbsoluteOffset(), end: mdat.PayloadAbsoluteOffset() + payloadSize(mdat)})
+		}
+	}
+	if len(mdats) == 0 {
+		r.errorf("缺少 mdat")
+		return
+	}
+
+	if len(stbl.Stts.SampleCount) != len(stbl.Stts.SampleTimeDelta) {
+		r.errorf("stts 无效")
+		return
+	}
+	var sttsSamples, sttsDuration uint64
+	for i, count := range stbl.Stts.SampleCount {
+		sttsSamples += uint64(count)
+		sttsDuration += uint64(count) * uint64(stbl.Stts.SampleTimeDelta[i])
+	}
+	sampleCount := uint64(stbl.Stsz.SampleNumber)
+	if sampleCount == 0 {
+		r.errorf("音轨没有采样")
+		return
+	}
+	if stbl.Stsz.SampleUniformSize == 0 && uint64(len(stbl.Stsz.SampleSize)) != sampleCount {
+		r.errorf("stsz 条目数 %d 与采样数 %d 不符", len(stbl.Stsz.SampleSize), sampleCount)
+		return
+	}
+	if sttsSamples != sampleCount {
+		r.errorf("采样数不一致（stts %d, stsz %d）", sttsSamples, sampleCount)
+		return
+	}
+	r.Duration = time.Duration(float64(sttsDuration) / float64(mdhd.Timescale) * float64(time.Second))
+
+	var offsets []uint64
+	if stbl.Stco != nil {
+		for _, o := range stbl.Stco.ChunkOffset {
+			offsets = append(offsets, uint64(o))
+		}
+	} else {
+		offsets = stbl.Co64.ChunkOffset
+	}
+	if len(stbl.Stsc.Entries) == 0 || stbl.Stsc.Entries[0].FirstChunk != 1 {
+		r.errorf("stsc 无效")
+		return
+	}
+
+	sampleSize := func(nr uint64) uint64 {
+		if stbl.Stsz.SampleUniformSize != 0 {
+			return uint64(stbl.Stsz.SampleUniformSize)
+		}
+		return uint64(stbl.Stsz.SampleSize[nr])
+	}
+	var sampleNr uint64
+	for chunkIdx, offset := range offsets {
+		chunkNr := uint32(chunkIdx + 1)
+		var perChunk uint32
+		for _, entry := range stbl.Stsc.Entries {
+			if entry.FirstChunk > chunkNr {
+				break
+			}
+			perChunk = entry.SamplesPerChunk
+		}
+		var chunkSize uint64
+		for i := uint32(0); i < perChunk && sampleNr < sampleCount; i++ {
+			chunkSize += sampleSize(sampleNr)
+			sampleNr++
+		}
+		inside := false
+		for _, m := range mdats {
+			if offset >= m.start && offset+chunkSize <= m.end {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			r.errorf("第 %d 个 chunk 超出 mdat 范围（数据缺失）", chunkNr)
+			return
+		}
+	}
+	if sampleNr != sampleCount {
+		r.errorf("chunk 中的采样数 %d 少于 stsz 中的 %d", sampleNr, sampleCount)
+	}
+}
+
+// checkFragmented 校验分片文件：每个分片的采样数据都必须完整位于其 mdat 内
+func checkFragmented(r *Report, parsed *mp4.File) {
+	if parsed.Init == nil {
+		r.errorf("分片文件缺少初始化段")
+		return
+	}
+	trak, mdhd := audioTrak(r, parsed.Init.Moov)
+	if trak == nil {
+		return
+	}
+	if trak.Tkhd == nil {
+		r.errorf("音轨缺少 tkhd")
+		return
+	}
+	var trex *mp4.TrexBox
+	if parsed.Init.Moov.Mvex != nil {
+		for _, t := range parsed.Init.Moov.Mvex.Trexs {
+			if t.TrackID == trak.Tkhd.TrackID {
+				trex = t
+			}
+		}
+	}
+
+	var totalDuration uint64
+	fragments := 0
+	for _, seg := range parsed.Segments {
+		for _, frag := range seg.Fragments {
+			fragments++
+			if frag.Moof == nil || frag.Mdat == nil {
+				r.errorf("第 %d 个分片缺少 moof 或 mdat", fragments)
+				return
+			}
+			var dataSize uint64
+			for _, traf := range frag.Moof.Trafs {
+				if traf.Tfhd == nil || traf.Tfhd.TrackID != trak.Tkhd.TrackID {
+					continue
+				}
+				for _, trun := range traf.Truns {
+					totalDuration += trun.AddSampleDefaultValues(traf.Tfhd, trex)
+					for _, s := range trun.Samples {
+						dataSize += uint64(s.Size)
+					}
+				}
+			}
+			if dataSize > payloadSize(frag.Mdat) {
+				r.errorf("第 %d 个分片数据不完整（需要 %d 字节，mdat 仅 %d 字节）", fragments, dataSize, payloadSize(frag.Mdat))
+				return
+			}
+		}
+	}
+	if fragments == 0 {
+		r.errorf("分片文件中没有媒体分片")
+		return
+	}
+	r.Duration = time.Duration(float64(totalDuration) / float64(mdhd.Timescale) * float64(time.Second))
+}
+
+// Decode 使用 ffmpeg 完整解码文件，以退出状态判断是否损坏
+// -xerror 使 ffmpeg 遇到解码错误时以非零状态退出；警告等其他输出（如 -loglevel 较低时）不视为损坏
+// 解码很慢，只应在结构校验通过但结果可疑时调用
+func Decode(path string, args []string) error {
+	cmd := exec.Command("ffmpeg", append([]string{"-xerror", "-i", path}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("解码失败: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package verify
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Eyevinn/mp4ff/aac"
+	"github.com/Eyevinn/mp4ff/mp4"
+)
+
+// writeTestFile 生成一个 10 秒的分片 AAC 文件（48kHz，每个采样 1024 帧）
+func writeTestFile(t *testing.T) string {
+	t.Helper()
+	const timescale = 48000
+	init := mp4.CreateEmptyInit()
+	init.AddEmptyTrack(timescale, "audio", "und")
+	trak := init.Moov.Trak
+	if err := trak.SetAACDescriptor(aac.AAClc, timescale); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := init.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	samples := 10 * timescale / 1024
+	perFragment := 100
+	var decodeTime uint64
+	for seq := 1; samples > 0; seq++ {
+		frag, err := mp4.CreateFragment(uint32(seq), trak.Tkhd.TrackID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < perFragment && samples > 0; i++ {
+			data := make([]byte, 64)
+			frag.AddFullSample(mp4.FullSample{
+				Sample:     mp4.NewSample(mp4.SyncSampleFlags, 1024, uint32(len(data)), 0),
+				DecodeTime: decodeTime,
+				Data:       data,
+			})
+			decodeTime += 1024
+			samples--
+		}
+		if err := frag.Encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "track.m4a")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileValid(t *testing.T) {
+	path := writeTestFile(t)
+	r := File(path, 10*time.Second)
+	if r.Corrupt() || r.Suspicious() {
+		t.Fatalf("expected valid file, got %q", r.Summary())
+	}
+	if r.Duration < 9*time.Second || r.Duration > 11*time.Second {
+		t.Fatalf("unexpected duration %s", r.Duration)
+	}
+}
+
+func TestFileTruncated(t *testing.T) {
+	path := writeTestFile(t)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-100); err != nil {
+		t.Fatal(err)
+	}
+	if r := File(path, 10*time.Second); !r.Corrupt() {
+		t.Fatal("expected truncated file to be reported as corrupt")
+	}
+}
+
+func TestFileDurationMismatch(t *testing.T) {
+	path := writeTestFile(t)
+	r := File(path, 60*time.Second)
+	if r.Corrupt() {
+		t.Fatalf("unexpected structural error: %s", r.Summary())
+	}
+	if !r.Suspicious() {
+		t.Fatal("expected duration mismatch to be suspicious")
+	}
+}
+
+func TestFileNotMP4(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.m4a")
+	if err := os.WriteFile(path, []byte("not an mp4 file at all"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if r := File(path, 0); !r.Corrupt() {
+		t.Fatal("expected garbage file to be reported as corrupt")
+	}
+}
@@ -16,6 +16,8 @@ import (
 	"main/internal/core"
 	"main/internal/downloader"
 	"main/internal/logger"
+	"main/internal/metadata"
+	"main/internal/notify"
 	"main/internal/parser"
 	"main/internal/progress"
 	"main/internal/ui"
@@ -31,7 +33,8 @@ var (
 	GitCommit = "unknown" // Git commit hash
 )
 
-func handleSingleMV(urlRaw string) {
+// handleSingleMV 下载单个 MV，artistFolder 为歌手页面展开时确定的歌手文件夹格式，为空时使用配置
+func handleSingleMV(urlRaw, artistFolder string) {
 	if core.Debug_mode {
 		return
 	}
@@ -54,6 +57,19 @@ func handleSingleMV(urlRaw string) {
 		core.SharedLock.Unlock()
 		return
 	}
+	if core.ListFormats {
+		if err := downloader.PrintMVFormats(albumId, accountForMV); err != nil {
+			logger.Error("获取MV格式失败: %v", err)
+			core.SharedLock.Lock()
+			core.Counter.Error++
+			core.SharedLock.Unlock()
+			return
+		}
+		core.SharedLock.Lock()
+		core.Counter.Success++
+		core.SharedLock.Unlock()
+		return
+	}
 	if _, err := exec.LookPath("mp4decrypt"); err != nil {
 		core.SharedLock.Lock()
 		core.Counter.Error++
@@ -81,13 +97,15 @@ func handleSingleMV(urlRaw string) {
 		fmt.Printf("📅 Release Year: %s\n", releaseYear)
 	}
 
-	var artistFolder string
-	if core.Config.ArtistFolderFormat != "" {
+	if artistFolder == "" {
+		artistFolder = core.Config.ArtistFolderFormat
+	}
+	if artistFolder != "" {
 		artistFolder = strings.NewReplacer(
 			"{UrlArtistName}", core.LimitString(mvInfo.Data[0].Attributes.ArtistName),
 			"{ArtistName}", core.LimitString(mvInfo.Data[0].Attributes.ArtistName),
 			"{ArtistId}", "",
-		).Replace(core.Config.ArtistFolderFormat)
+		).Replace(artistFolder)
 	}
 	sanitizedArtistFolder := core.ForbiddenNames.ReplaceAllString(artistFolder, "_")
 
@@ -120,6 +138,14 @@ func handleSingleMV(urlRaw string) {
 			fmt.Printf("📥 MV file transfer complete!\n")
 			fmt.Printf("💾 Save path: %s\n", finalMvPath)
 
+			// Move the sidecar NFO along with the video
+			nfoPath := metadata.MusicVideoNFOPath(mvOutPath)
+			if _, statErr := os.Stat(nfoPath); statErr == nil {
+				if moveErr := downloader.SafeMoveFile(nfoPath, metadata.MusicVideoNFOPath(finalMvPath)); moveErr != nil {
+					logger.Warn("Failed to move MV NFO from cache: %v", moveErr)
+				}
+			}
+
 			// Clean up cache directory
 			mvCacheDir := filepath.Dir(mvOutPath)
 			for mvCacheDir != cachePath && mvCacheDir != "." && mvCacheDir != "/" {
@@ -151,7 +177,7 @@ func handleSingleMV(urlRaw string) {
 	core.SharedLock.Unlock()
 }
 
-func processURL(urlRaw string, wg *sync.WaitGroup, semaphore chan struct{}, currentTask int, totalTasks int, notifier *progress.ProgressNotifier) (string, string, error) {
+func processURL(urlRaw, artistFolder string, wg *sync.WaitGroup, semaphore chan struct{}, currentTask int, totalTasks int, notifier *progress.ProgressNotifier) (string, string, error) {
 	if wg != nil {
 		defer wg.Done()
 	}
@@ -168,7 +194,12 @@ func processURL(urlRaw string, wg *sync.WaitGroup, semaphore chan struct{}, curr
 	_ = albumName // 用于历史记录
 
 	if strings.Contains(urlRaw, "/music-video/") {
-		handleSingleMV(urlRaw)
+		handleSingleMV(urlRaw, artistFolder)
+		return "", "", nil
+	}
+
+	if core.ListFormats {
+		logger.Warn("--list-formats 仅支持 MV 链接，已跳过: %s", urlRaw)
 		return "", "", nil
 	}
 
@@ -214,7 +245,7 @@ func processURL(urlRaw string, wg *sync.WaitGroup, semaphore chan struct{}, curr
 		return albumId, albumName, err
 	}
 	var urlArg_i = parse.Query().Get("i")
-	err = downloader.Rip(albumId, storefront, urlArg_i, notifier)
+	err = downloader.Rip(albumId, storefront, urlArg_i, artistFolder, notifier)
 	if err != nil {
 		core.SafePrintf("专辑下载失败: %s -> %v\n", urlRaw, err)
 		return albumId, albumName, err
@@ -226,6 +257,22 @@ func processURL(urlRaw string, wg *sync.WaitGroup, semaphore chan struct{}, curr
 	}
 }
 
+// initDeveloperToken 获取开发者 token，失败时回退到配置中第一个账户的 authorization-token
+func initDeveloperToken() error {
+	token, err := api.GetToken()
+	if err != nil {
+		if len(core.Config.Accounts) > 0 && core.Config.Accounts[0].AuthorizationToken != "" && core.Config.Accounts[0].AuthorizationToken != "your-authorization-token" {
+			token = strings.Replace(core.Config.Accounts[0].AuthorizationToken, "Bearer ", "", -1)
+		} else {
+			err = fmt.Errorf("获取开发者 token 失败。")
+			notify.TokenFailure(err)
+			return err
+		}
+	}
+	core.DeveloperToken = token
+	return nil
+}
+
 // parseTxtFile 从TXT文件中解析URL列表
 func parseTxtFile(filePath string) ([]string, error) {
 	fileBytes, err := os.ReadFile(filePath)
@@ -253,8 +300,49 @@ func parseTxtFile(filePath string) ([]string, error) {
 	return urls, nil
 }
 
+// expandArtist 把歌手页面展开为其专辑和 MV 链接，同时返回代入了该歌手名称和ID的 artist-folder-format
+// 歌手文件夹随链接一起传给各个下载任务，不修改全局配置，以免影响队列中的其他任务
+func expandArtist(urlRaw string) ([]string, string, error) {
+	core.SafePrintf("🔍 正在解析歌手页面: %s\n", urlRaw)
+	artistAccount := &core.Config.Accounts[0]
+	urlArtistName, urlArtistID, err := api.GetUrlArtistName(urlRaw, artistAccount)
+	if err != nil {
+		core.SafePrintf("获取歌手名称失败 for %s: %v\n", urlRaw, err)
+		return nil, "", err
+	}
+
+	artistFolder := strings.NewReplacer(
+		"{UrlArtistName}", core.LimitString(urlArtistName),
+		"{ArtistId}", urlArtistID,
+	).Replace(core.Config.ArtistFolderFormat)
+
+	var urls []string
+	albumArgs, err := api.CheckArtist(urlRaw, artistAccount, "albums")
+	if err != nil {
+		core.SafePrintf("获取歌手专辑失败 for %s: %v\n", urlRaw, err)
+	} else {
+		urls = append(urls, albumArgs...)
+		core.SafePrintf("📀 从歌手 %s 页面添加了 %d 张专辑到队列。\n", urlArtistName, len(albumArgs))
+	}
+
+	mvArgs, err := api.CheckArtist(urlRaw, artistAccount, "music-videos")
+	if err != nil {
+		core.SafePrintf("获取歌手MV失败 for %s: %v\n", urlRaw, err)
+	} else {
+		urls = append(urls, mvArgs...)
+		core.SafePrintf("🎬 从歌手 %s 页面添加了 %d 个MV到队列。\n", urlArtistName, len(mvArgs))
+	}
+	return urls, artistFolder, nil
+}
+
+// downloadTask 待下载的链接，artistFolder 非空时为其所属歌手页面的歌手文件夹格式
+type downloadTask struct {
+	url          string
+	artistFolder string
+}
+
 func runDownloads(initialUrls []string, isBatch bool, taskFile string, notifier *progress.ProgressNotifier) {
-	var finalUrls []string
+	var finalUrls []downloadTask
 
 	// 显示输入链接统计
 	if isBatch && len(initialUrls) > 0 {
@@ -264,36 +352,15 @@ func runDownloads(initialUrls []string, isBatch bool, taskFile string, notifier
 
 	for _, urlRaw := range initialUrls {
 		if strings.Contains(urlRaw, "/artist/") {
-			core.SafePrintf("🔍 正在解析歌手页面: %s\n", urlRaw)
-			artistAccount := &core.Config.Accounts[0]
-			urlArtistName, urlArtistID, err := api.GetUrlArtistName(urlRaw, artistAccount)
+			artistUrls, artistFolder, err := expandArtist(urlRaw)
 			if err != nil {
-				core.SafePrintf("获取歌手名称失败 for %s: %v\n", urlRaw, err)
 				continue
 			}
-
-			core.Config.ArtistFolderFormat = strings.NewReplacer(
-				"{UrlArtistName}", core.LimitString(urlArtistName),
-				"{ArtistId}", urlArtistID,
-			).Replace(core.Config.ArtistFolderFormat)
-
-			albumArgs, err := api.CheckArtist(urlRaw, artistAccount, "albums")
-			if err != nil {
-				core.SafePrintf("获取歌手专辑失败 for %s: %v\n", urlRaw, err)
-			} else {
-				finalUrls = append(finalUrls, albumArgs...)
-				core.SafePrintf("📀 从歌手 %s 页面添加了 %d 张专辑到队列。\n", urlArtistName, len(albumArgs))
-			}
-
-			mvArgs, err := api.CheckArtist(urlRaw, artistAccount, "music-videos")
-			if err != nil {
-				core.SafePrintf("获取歌手MV失败 for %s: %v\n", urlRaw, err)
-			} else {
-				finalUrls = append(finalUrls, mvArgs...)
-				core.SafePrintf("🎬 从歌手 %s 页面添加了 %d 个MV到队列。\n", urlArtistName, len(mvArgs))
+			for _, artistUrl := range artistUrls {
+				finalUrls = append(finalUrls, downloadTask{url: artistUrl, artistFolder: artistFolder})
 			}
 		} else {
-			finalUrls = append(finalUrls, urlRaw)
+			finalUrls = append(finalUrls, downloadTask{url: urlRaw})
 		}
 	}
 
@@ -352,11 +419,21 @@ func runDownloads(initialUrls []string, isBatch bool, taskFile string, notifier
 		core.SafePrintf("⏱️  工作开始时间: %s\n\n", workStartTime.Format("15:04:05"))
 	}
 
-	for i, urlToProcess := range finalUrls {
+	ui.Job.StartJob(startIndex+len(finalUrls), startIndex)
+	if isBatch && core.Config.WorkRestEnabled {
+		ui.Job.SetWorkRest(time.Duration(core.Config.WorkDurationMinutes)*time.Minute, time.Duration(core.Config.RestDurationMinutes)*time.Minute)
+	} else {
+		ui.Job.SetWorkRest(0, 0)
+	}
+
+	for i, task := range finalUrls {
 		// 计算实际的任务编号（考虑 --start 参数）
 		actualTaskNum := i + 1 + startIndex // 实际编号 = 当前索引 + 1 + 跳过的数量
 
-		_, _, _ = processURL(urlToProcess, nil, nil, actualTaskNum, originalTotalTasks, notifier)
+		ui.Job.StartTask(actualTaskNum)
+		_, _, err := processURL(task.url, task.artistFolder, nil, nil, actualTaskNum, originalTotalTasks, notifier)
+		notify.CheckError(err)
+		ui.Job.FinishTask()
 
 		// 任务之间添加视觉间隔（最后一个任务不需要）
 		if isBatch && i < len(finalUrls)-1 {
@@ -389,6 +466,7 @@ func runDownloads(initialUrls []string, isBatch bool, taskFile string, notifier
 				restTicker := time.NewTicker(30 * time.Second)
 				restTimer := time.NewTimer(restDuration)
 				restStartTime := time.Now()
+				ui.Job.StartRest()
 
 				restDone := false
 				for !restDone {
@@ -410,6 +488,7 @@ func runDownloads(initialUrls []string, isBatch bool, taskFile string, notifier
 
 				// 休息结束，重新开始计时
 				workStartTime = time.Now()
+				ui.Job.EndRest()
 				core.SafePrintf("\n")
 				core.SafePrintf(strings.Repeat("=", 80) + "\n")
 				green.Printf("✅ 休息完毕，继续下载任务！\n")
@@ -421,18 +500,6 @@ func runDownloads(initialUrls []string, isBatch bool, taskFile string, notifier
 }
 
 func main() {
-	// 打印版本信息
-	cyan := color.New(color.FgCyan, color.Bold)
-	yellow := color.New(color.FgYellow)
-	fmt.Println(strings.Repeat("=", 80)) // OK: 程序启动横幅
-	cyan.Printf("🎵 Apple Music Downloader %s\n", Version)
-	yellow.Printf("📅 编译时间: %s\n", BuildTime)
-	if GitCommit != "unknown" {
-		yellow.Printf("🔖 Git提交: %s\n", GitCommit)
-	}
-	fmt.Println(strings.Repeat("=", 80)) // OK: 程序启动横幅
-	fmt.Println()                        // OK: 程序启动横幅
-
 	core.InitFlags()
 
 	pflag.Usage = func() {
@@ -458,7 +525,28 @@ func main() {
 
 	pflag.Parse()
 
-	err := core.LoadConfig(core.ConfigPath)
+	if err := core.ResolveProgressMode(); err != nil {
+		fmt.Fprintln(os.Stderr, err) // OK: logger还未初始化
+		return
+	}
+	progressOut, err := openProgressOutput()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开进度输出文件失败: %v\n", err) // OK: logger还未初始化
+		return
+	}
+
+	// 打印版本信息
+	cyan := color.New(color.FgCyan, color.Bold)
+	yellow := color.New(color.FgYellow)
+	fmt.Println(strings.Repeat("=", 80)) // OK: 程序启动横幅
+	cyan.Printf("🎵 Apple Music Downloader %s\n", Version)
+	yellow.Printf("📅 编译时间: %s\n", BuildTime)
+	if GitCommit != "unknown" {
+		yellow.Printf("🔖 Git提交: %s\n", GitCommit)
+	}
+	fmt.Println(strings.Repeat("=", 80)) // OK: 程序启动横幅
+	fmt.Println()                        // OK: 程序启动横幅
+	err = core.LoadConfig(core.ConfigPath)
 	if err != nil {
 		if os.IsNotExist(err) && core.ConfigPath == "config.yaml" {
 			// OK: logger还未初始化，必须使用fmt
@@ -483,30 +571,57 @@ func main() {
 		return
 	}
 
+	if err := core.ParseFormats(); err != nil {
+		logger.Error("%v", err)
+		return
+	}
+	if err := metadata.InitTagProfile(); err != nil {
+		logger.Error("%v", err)
+		return
+	}
+	if err := parser.InitMVFormat(); err != nil {
+		logger.Error("%v", err)
+		return
+	}
+	if err := notify.Init(); err != nil {
+		logger.Error("%v", err)
+		return
+	}
+	defer notify.Wait()
+
 	// 创建进度通知器并注册UI监听器
 	progressNotifier := progress.NewNotifier()
 	uiListener := ui.NewUIProgressListener()
 	progressNotifier.AddListener(uiListener)
+	progressNotifier.AddListener(ui.Job)
+	if progressOut != nil {
+		progressNotifier.AddListener(progress.NewJSONListener(progressOut, ui.TrackInfo))
+	}
+	if notify.Enabled() {
+		progressNotifier.AddListener(notify.NewListener(ui.TrackInfo))
+	}
 	logger.Debug("Progress notifier initialized with UI listener")
 
 	if core.OutputPath != "" {
 		core.Config.AlacSaveFolder = core.OutputPath
 		core.Config.AtmosSaveFolder = core.OutputPath
+		core.Config.AacSaveFolder = core.OutputPath
 	}
 
-	token, err := api.GetToken()
-	if err != nil {
-		if len(core.Config.Accounts) > 0 && core.Config.Accounts[0].AuthorizationToken != "" && core.Config.Accounts[0].AuthorizationToken != "your-authorization-token" {
-			token = strings.Replace(core.Config.Accounts[0].AuthorizationToken, "Bearer ", "", -1)
-		} else {
-			logger.Error("获取开发者 token 失败。")
-			return
-		}
+	args := pflag.Args()
+	if runCommand(args, progressNotifier) {
+		return
 	}
-	core.DeveloperToken = token
 
-	args := pflag.Args()
-	if len(args) == 0 {
+	if err := initDeveloperToken(); err != nil {
+		logger.Error("%v", err)
+		return
+	}
+
+	started := time.Now()
+	if core.TUI {
+		runTUI(args, progressNotifier)
+	} else if len(args) == 0 {
 		logger.Info("请输入专辑链接或TXT文件路径: ")
 		reader := bufio.NewReader(os.Stdin)
 		input, _ := reader.ReadString('\n')
@@ -580,6 +695,15 @@ func main() {
 	}
 
 	logger.Info("\n📦 已完成: %d/%d | 警告: %d | 错误: %d", core.Counter.Success, core.Counter.Total, core.Counter.Unavailable+core.Counter.NotSong, core.Counter.Error)
+	downloader.PrintRunReport()
+	notify.Send(notify.Event{
+		Type:      notify.JobDone,
+		Tracks:    core.Counter.Total,
+		Completed: core.Counter.Success,
+		Warnings:  core.Counter.Unavailable + core.Counter.NotSong,
+		Failed:    core.Counter.Error,
+		Seconds:   time.Since(started).Seconds(),
+	})
 	if core.Counter.Error > 0 {
 		logger.Warn("部分任务在执行过程中出错，请检查上面的日志记录。")
 	}
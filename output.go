@@ -0,0 +1,31 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"main/internal/core"
+	"main/internal/logger"
+
+	"github.com/fatih/color"
+)
+
+// openProgressOutput 为 --progress=json 准备事件输出：写入 --progress-file 指定的文件，
+// 或占用标准输出并把其它所有输出转到标准错误，保证标准输出中只有 JSON
+func openProgressOutput() (io.Writer, error) {
+	if core.ProgressMode != "json" {
+		return nil, nil
+	}
+	if core.ProgressFile != "" {
+		file, err := os.OpenFile(core.ProgressFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return file, nil
+	}
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	color.Output = color.Error
+	logger.SetOutput(os.Stderr)
+	return out, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"main/internal/core"
+	"main/internal/downloader"
+	"main/internal/logger"
+	"main/internal/notify"
+	"main/internal/progress"
+	"main/internal/queue"
+	"main/internal/server"
+	"main/internal/tui"
+	"main/internal/ui"
+)
+
+// runServeCommand 处理 serve 子命令：启动本地 HTTP 服务，通过 REST API 提交和管理下载任务
+// 任务队列保存在 serve-queue-file 中，重启后继续未完成的任务
+func runServeCommand(args []string, notifier *progress.ProgressNotifier) {
+	if len(args) != 0 {
+		logger.Error("用法: serve [--listen 127.0.0.1:8080]")
+		return
+	}
+	if err := initDeveloperToken(); err != nil {
+		logger.Error("%v", err)
+		return
+	}
+
+	// 服务在后台运行：不使用动态 UI，歌手页面直接展开全部专辑
+	core.DisableDynamicUI = true
+	core.Artist_select = true
+	core.Dl_select = false
+
+	queueFile := core.Config.ServeQueueFile
+	q, err := queue.Load(queueFile)
+	if err != nil {
+		logger.Error("读取任务队列失败: %v", err)
+		return
+	}
+	if n := len(q.Items()); n > 0 {
+		logger.Info("📋 从 %s 恢复了 %d 个任务", queueFile, n)
+	}
+
+	srv := server.New(q, core.Config.ServeToken, core.Listen)
+	q.OnChange(func() {
+		if err := q.Save(queueFile); err != nil {
+			logger.Warn("保存任务队列失败: %v", err)
+		}
+		srv.JobsChanged()
+	})
+	notifier.AddListener(progress.NewJSONListener(srv.Events(), ui.TrackInfo))
+	notifier.AddListener(tui.NewListener(q))
+	downloader.TrackGate = q.Gate
+	defer func() { downloader.TrackGate = nil }()
+
+	ui.Job.StartJob(0, 0)
+	go runQueue(q, notifier, notifyJobDone)
+
+	httpServer := &http.Server{Addr: core.Listen, Handler: srv.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	if core.Config.ServeToken == "" && !server.IsLoopback(core.Listen) {
+		logger.Warn("⚠️ 服务监听在 %s 且未设置 serve-token，同一网络中的任何人都可以提交任务", core.Listen)
+	}
+	logger.Info("🌐 服务已启动: http://%s/", core.Listen)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("服务启动失败: %v", err)
+		return
+	}
+	q.Close()
+	logger.Info("🌐 服务已停止，未完成的任务将在下次启动时继续")
+}
+
+// notifyJobDone serve 模式下每个任务结束时发送 job-done 通知
+func notifyJobDone(item queue.Item) {
+	ev := notify.Event{
+		Type:  notify.JobDone,
+		Album: item.Title,
+		URL:   item.URL,
+		Error: item.Error,
+	}
+	if item.Report != nil {
+		ev.Tracks = item.Report.Total
+		ev.Completed = item.Report.Success
+		ev.Warnings = item.Report.Warnings
+		ev.Failed = item.Report.Errors
+	}
+	for _, e := range item.TrackErrors {
+		ev.FailedTracks = append(ev.FailedTracks, fmt.Sprintf("%d. %s: %s", e.TrackNum, e.Name, e.Error))
+	}
+	if !item.Started.IsZero() {
+		ev.Seconds = item.Finished.Sub(item.Started).Seconds()
+	}
+	notify.Send(ev)
+}
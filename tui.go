@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"main/internal/core"
+	"main/internal/downloader"
+	"main/internal/logger"
+	"main/internal/notify"
+	"main/internal/progress"
+	"main/internal/queue"
+	"main/internal/tui"
+	"main/internal/ui"
+	"main/utils/structs"
+)
+
+// runTUI 以全屏队列管理界面运行：命令行中的链接和 TXT 文件作为初始队列，下载在后台依次进行，
+// 界面中可继续添加链接，直到用户退出
+func runTUI(args []string, notifier *progress.ProgressNotifier) {
+	// 全屏界面自己负责显示和交互：关闭动态 UI，歌手页面直接展开全部专辑
+	core.DisableDynamicUI = true
+	core.Artist_select = true
+	if core.Dl_select {
+		logger.Warn("--tui 模式下请在界面中按 s 设置要跳过的曲目，已忽略 --select")
+		core.Dl_select = false
+	}
+
+	q := queue.New()
+	for _, arg := range args {
+		if !strings.HasSuffix(strings.ToLower(arg), ".txt") {
+			q.Add(arg)
+			continue
+		}
+		if _, err := os.Stat(arg); err != nil {
+			logger.Error("错误: 文件不存在 %s", arg)
+			continue
+		}
+		urls, err := parseTxtFile(arg)
+		if err != nil {
+			logger.Error("读取文件 %s 失败: %v", arg, err)
+			continue
+		}
+		for _, url := range urls {
+			q.Add(url)
+		}
+	}
+
+	ui.Job.StartJob(0, 0)
+	updateQueued := func() {
+		queued := 0
+		for _, it := range q.Items() {
+			if it.State == queue.Pending || it.State == queue.Paused && it.Started.IsZero() {
+				queued++
+			}
+		}
+		ui.Job.SetQueued(queued)
+	}
+	updateQueued()
+	q.OnChange(updateQueued)
+
+	notifier.AddListener(tui.NewListener(q))
+	downloader.TrackGate = q.Gate
+	defer func() { downloader.TrackGate = nil }()
+
+	go runQueue(q, notifier, nil)
+	if err := tui.Run(q); err != nil {
+		logger.Error("%v", err)
+	}
+	q.Close()
+}
+
+// runQueue 依次下载队列中的条目，歌手页面展开为专辑和 MV 插入到原位置之后
+// done 非空时在每个条目下载结束后调用
+func runQueue(q *queue.Queue, notifier *progress.ProgressNotifier, done func(queue.Item)) {
+	position := 0
+	for {
+		item, ok := q.Next()
+		if !ok {
+			return
+		}
+		if strings.Contains(item.URL, "/artist/") {
+			urls, artistDir, err := expandArtist(item.URL)
+			q.Insert(item.ID, urls, artistDir)
+			q.Finish(item.ID, err)
+			continue
+		}
+
+		position++
+		core.SharedLock.Lock()
+		before := core.Counter
+		core.SharedLock.Unlock()
+		reported := downloader.RunReportEntries()
+
+		ui.Job.StartTask(position)
+		albumName, err := runQueueItem(item, position, notifier)
+		ui.Job.FinishTask()
+		if albumName != "" {
+			q.SetTitle(item.ID, albumName)
+		}
+		notify.CheckError(err)
+		q.SetReport(item.ID, itemReport(before, reported))
+		q.Finish(item.ID, err)
+		if finished, ok := q.Get(item.ID); ok && done != nil {
+			done(finished)
+		}
+	}
+}
+
+// runQueueItem 下载一个队列条目，条目指定了下载格式时临时替换 --formats 的设置
+func runQueueItem(item queue.Item, position int, notifier *progress.ProgressNotifier) (string, error) {
+	if len(item.Formats) > 0 {
+		saved := core.DownloadFormats
+		core.DownloadFormats = item.Formats
+		defer func() { core.DownloadFormats = saved }()
+	}
+	_, albumName, err := processURL(item.URL, item.ArtistDir, nil, nil, position, 0, notifier)
+	return albumName, err
+}
+
+// itemReport 根据计数器的变化和运行报告中新增的记录生成条目的报告
+func itemReport(before structs.Counter, reported []downloader.ReportEntry) queue.Report {
+	core.SharedLock.Lock()
+	after := core.Counter
+	core.SharedLock.Unlock()
+	r := queue.Report{
+		Total:    after.Total - before.Total,
+		Success:  after.Success - before.Success,
+		Warnings: after.Unavailable + after.NotSong - before.Unavailable - before.NotSong,
+		Errors:   after.Error - before.Error,
+	}
+	seen := make(map[downloader.ReportEntry]bool, len(reported))
+	for _, e := range reported {
+		seen[e] = true
+	}
+	for _, e := range downloader.RunReportEntries() {
+		if seen[e] {
+			continue
+		}
+		r.Entries = append(r.Entries, queue.ReportEntry{Section: e.Section, Track: e.Track, Detail: e.Detail})
+	}
+	return r
+}
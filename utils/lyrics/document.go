@@ -0,0 +1,407 @@
+package lyrics
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// Document 从 TTML 解析出的歌词，各输出格式都由它渲染
+type Document struct {
+	Timing           string // Word（逐字）/ Line（逐行）/ None（无时间轴）
+	Language         string // 歌词语言（xml:lang）
+	Songwriters      []string
+	Agents           []Agent // 演唱者，对唱歌曲有多个
+	Lines            []Line
+	Translations     []Variant
+	Transliterations []Variant
+}
+
+// Agent 演唱者（ttm:agent）
+type Agent struct {
+	ID   string
+	Type string // person / group / other
+	Name string // 可能为空
+}
+
+// Line 一行歌词
+type Line struct {
+	Key        string // itunes:key，用于对应译文和音译
+	Begin, End time.Duration
+	Agent      string // 演唱者 ID，没有时为空
+	Text       string // 主唱歌词（不含和声）
+	Words      []Word // 逐字时间，仅 Word 时间轴
+	Background []Word // 和声（x-bg）
+}
+
+// Word 一个带时间的字或词
+type Word struct {
+	Begin, End time.Duration
+	Text       string
+	Space      bool // 与前一个词之间有空格
+}
+
+// Variant 一种语言的译文或音译
+type Variant struct {
+	Language string
+	Lines    map[string]VariantLine // itunes:key -> 该行内容
+}
+
+// VariantLine 译文或音译中的一行
+type VariantLine struct {
+	Text  string
+	Words []Word // 逐字音译
+}
+
+// Synced 歌词是否带时间轴
+func (d *Document) Synced() bool {
+	return d.Timing != "None"
+}
+
+// BackgroundText 和声文本，没有时为空
+func (l Line) BackgroundText() string {
+	return joinWords(l.Background)
+}
+
+// Parse 解析 TTML 歌词
+func Parse(ttml string) (*Document, error) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(ttml); err != nil {
+		return nil, err
+	}
+	tt := doc.FindElement("tt")
+	if tt == nil || tt.FindElement("body") == nil {
+		return nil, errors.New("不是有效的 TTML")
+	}
+	d := &Document{
+		Timing:   tt.SelectAttrValue("itunes:timing", ""),
+		Language: tt.SelectAttrValue("xml:lang", ""),
+	}
+
+	if meta := tt.FindElement("head/metadata"); meta != nil {
+		for _, a := range meta.SelectElements("ttm:agent") {
+			agent := Agent{ID: a.SelectAttrValue("xml:id", ""), Type: a.SelectAttrValue("type", "")}
+			if name := a.SelectElement("ttm:name"); name != nil {
+				agent.Name = strings.TrimSpace(elementText(name))
+			}
+			d.Agents = append(d.Agents, agent)
+		}
+		if itunes := meta.SelectElement("iTunesMetadata"); itunes != nil {
+			for _, w := range itunes.FindElements("songwriters/songwriter") {
+				if name := strings.TrimSpace(elementText(w)); name != "" {
+					d.Songwriters = append(d.Songwriters, name)
+				}
+			}
+			var err error
+			if d.Translations, err = parseVariants(itunes.FindElements("translations/translation")); err != nil {
+				return nil, err
+			}
+			if d.Transliterations, err = parseVariants(itunes.FindElements("transliterations/transliteration")); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	synced := false
+	for _, p := range tt.FindElement("body").FindElements(".//p") {
+		line := Line{
+			Key:   p.SelectAttrValue("itunes:key", ""),
+			Agent: p.SelectAttrValue("ttm:agent", ""),
+		}
+		var err error
+		if line.Text, line.Words, line.Background, err = parseContent(p); err != nil {
+			return nil, err
+		}
+		if begin := p.SelectAttrValue("begin", ""); begin != "" {
+			if line.Begin, err = parseTTMLTime(begin); err != nil {
+				return nil, err
+			}
+			synced = true
+		} else if len(line.Words) > 0 {
+			line.Begin = line.Words[0].Begin
+			synced = true
+		}
+		if end := p.SelectAttrValue("end", ""); end != "" {
+			if line.End, err = parseTTMLTime(end); err != nil {
+				return nil, err
+			}
+		} else if len(line.Words) > 0 {
+			line.End = line.Words[len(line.Words)-1].End
+		} else {
+			line.End = line.Begin
+		}
+		if line.Text == "" && len(line.Background) == 0 {
+			continue
+		}
+		d.Lines = append(d.Lines, line)
+	}
+	if d.Timing == "" || !synced {
+		switch {
+		case !synced:
+			d.Timing = "None"
+		case len(d.Lines) > 0 && len(d.Lines[0].Words) > 0:
+			d.Timing = "Word"
+		default:
+			d.Timing = "Line"
+		}
+	}
+	return d, nil
+}
+
+// parseVariants 解析 translations / transliterations 中的各语言
+func parseVariants(elements []*etree.Element) ([]Variant, error) {
+	var variants []Variant
+	for _, e := range elements {
+		v := Variant{Language: e.SelectAttrValue("xml:lang", ""), Lines: map[string]VariantLine{}}
+		for _, text := range e.FindElements(".//text") {
+			key := text.SelectAttrValue("for", "")
+			if key == "" {
+				continue
+			}
+			content, words, _, err := parseContent(text)
+			if err != nil {
+				return nil, err
+			}
+			v.Lines[key] = VariantLine{Text: content, Words: words}
+		}
+		variants = append(variants, v)
+	}
+	return variants, nil
+}
+
+// parseContent 解析一行（p 或译文的 text）的内容：主唱文本、逐字时间和和声
+// 有 text 属性时以其为文本；否则由子节点拼接，带 begin 的 span 为逐字时间，ttm:role="x-bg" 的 span 为和声
+func parseContent(e *etree.Element) (string, []Word, []Word, error) {
+	var words, background []Word
+	var b strings.Builder
+	space := false // 上一个节点之后有空白
+	for _, child := range e.Child {
+		switch c := child.(type) {
+		case *etree.CharData:
+			trimmed := strings.TrimSpace(c.Data)
+			if trimmed == "" {
+				space = space || c.Data != ""
+				continue
+			}
+			if (space || trimmed[0] != c.Data[0]) && b.Len() > 0 {
+				b.WriteString(" ")
+			}
+			b.WriteString(trimmed)
+			space = trimmed[len(trimmed)-1] != c.Data[len(c.Data)-1]
+		case *etree.Element:
+			if c.SelectAttrValue("ttm:role", "") == "x-bg" {
+				_, bg, _, err := parseContent(c)
+				if err != nil {
+					return "", nil, nil, err
+				}
+				if len(bg) == 0 {
+					if text := strings.TrimSpace(elementText(c)); text != "" {
+						bg = []Word{{Text: text}}
+					}
+				}
+				background = append(background, bg...)
+				continue
+			}
+			text := elementText(c)
+			space = space && b.Len() > 0
+			if begin := c.SelectAttrValue("begin", ""); begin != "" {
+				w := Word{Text: text, Space: space}
+				var err error
+				if w.Begin, err = parseTTMLTime(begin); err != nil {
+					return "", nil, nil, err
+				}
+				if w.End, err = parseTTMLTime(c.SelectAttrValue("end", "")); err != nil {
+					w.End = w.Begin
+				}
+				words = append(words, w)
+			}
+			if space {
+				b.WriteString(" ")
+			}
+			b.WriteString(text)
+			space = false
+		}
+	}
+	text := strings.TrimSpace(b.String())
+	if attr := e.SelectAttr("text"); attr != nil {
+		text = attr.Value
+	}
+	return text, words, background, nil
+}
+
+// joinWords 按空格标记拼接词
+func joinWords(words []Word) string {
+	var b strings.Builder
+	for i, w := range words {
+		if i > 0 && w.Space {
+			b.WriteString(" ")
+		}
+		b.WriteString(w.Text)
+	}
+	return b.String()
+}
+
+// pickVariant 按语言选择译文或音译：完全匹配优先，其次主语言相同（zh 与 zh-Hans），都没有时取第一个
+func pickVariant(variants []Variant, language string) *Variant {
+	if len(variants) == 0 {
+		return nil
+	}
+	if language == "" {
+		return &variants[0]
+	}
+	for i := range variants {
+		if strings.EqualFold(variants[i].Language, language) {
+			return &variants[i]
+		}
+	}
+	primary := func(tag string) string {
+		tag, _, _ = strings.Cut(strings.ToLower(tag), "-")
+		return tag
+	}
+	for i := range variants {
+		if primary(variants[i].Language) == primary(language) {
+			return &variants[i]
+		}
+	}
+	return &variants[0]
+}
+
+// line 返回该行在所选语言中的内容
+func (v *Variant) line(key string) VariantLine {
+	if v == nil {
+		return VariantLine{}
+	}
+	return v.Lines[key]
+}
+
+// Render 将歌词渲染为指定格式（ttml 以外）
+func (d *Document) Render(format string, opts Options) (string, error) {
+	switch format {
+	case "lrc":
+		return d.renderLRC(opts, false), nil
+	case "lrc-enhanced":
+		return d.renderLRC(opts, true), nil
+	case "srt", "vtt":
+		if !d.Synced() {
+			return "", errors.New("歌词没有时间轴")
+		}
+		if len(d.Lines) == 0 {
+			return "", errors.New("no synchronised lyrics")
+		}
+		if format == "srt" {
+			return d.renderSRT(opts), nil
+		}
+		return d.renderVTT(opts), nil
+	default:
+		return "", fmt.Errorf("不支持的歌词格式: %s", format)
+	}
+}
+
+// lrcTime 输出 mm:ss.xx（百分之一秒，分钟可以超过 59）
+func lrcTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d.%02d", ms/60000, ms/1000%60, ms%1000/10)
+}
+
+// enhancedWords 输出带 <mm:ss.xx> 逐字时间的一行，以第一个词的时间作为行时间
+func enhancedWords(words []Word, withEnd bool) string {
+	var b strings.Builder
+	b.WriteString("[" + lrcTime(words[0].Begin) + "]")
+	for i, w := range words {
+		if i > 0 && w.Space {
+			b.WriteString(" ")
+		}
+		b.WriteString("<" + lrcTime(w.Begin) + ">" + w.Text)
+	}
+	if withEnd {
+		b.WriteString("<" + lrcTime(words[len(words)-1].End) + ">")
+	}
+	return b.String()
+}
+
+// renderLRC 输出 LRC；enhanced 时逐字歌词带 <mm:ss.xx> 逐字时间（逐行歌词与普通 LRC 相同）
+func (d *Document) renderLRC(opts Options, enhanced bool) string {
+	translation := pickVariant(d.Translations, opts.Language)
+	translit := pickVariant(d.Transliterations, opts.Language)
+	var lines []string
+	for _, l := range d.Lines {
+		trans, roman := translation.line(l.Key), translit.line(l.Key)
+		if !d.Synced() {
+			lines = append(lines, opts.lrcLines(l.Text, trans.Text, roman.Text)...)
+			continue
+		}
+		stamp := "[" + lrcTime(l.Begin) + "]"
+		withTime := func(text string) string {
+			if text == "" {
+				return ""
+			}
+			return stamp + text
+		}
+		original := withTime(l.Text)
+		romanLine := withTime(roman.Text)
+		if enhanced && d.Timing == "Word" {
+			if len(l.Words) > 0 {
+				original = enhancedWords(l.Words, true)
+			}
+			if len(roman.Words) > 0 {
+				romanLine = enhancedWords(roman.Words, false)
+			}
+		}
+		lines = append(lines, opts.lrcLines(original, withTime(trans.Text), romanLine)...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cues 每行字幕的起止时间和文本（含和声）
+func (d *Document) cues(opts Options) []cue {
+	translation := pickVariant(d.Translations, opts.Language)
+	translit := pickVariant(d.Transliterations, opts.Language)
+	var cues []cue
+	for _, l := range d.Lines {
+		text := l.Text
+		if bg := l.BackgroundText(); bg != "" {
+			text = strings.TrimSpace(text + " " + bg)
+		}
+		text = opts.cueText(text, translation.line(l.Key).Text, translit.line(l.Key).Text)
+		if text != "" {
+			cues = append(cues, cue{begin: l.Begin, end: l.End, text: text, agent: l.Agent})
+		}
+	}
+	return cues
+}
+
+// agentName 演唱者显示名称，没有名称时使用 ID
+func (d *Document) agentName(id string) string {
+	for _, a := range d.Agents {
+		if a.ID == id && a.Name != "" {
+			return a.Name
+		}
+	}
+	return id
+}
+
+func (d *Document) renderSRT(opts Options) string {
+	var b strings.Builder
+	for i, c := range d.cues(opts) {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, formatCueTime(c.begin, ","), formatCueTime(c.end, ","), c.text)
+	}
+	return b.String()
+}
+
+// renderVTT 输出 WebVTT，多位演唱者时用 <v 名称> 标注每行的演唱者
+func (d *Document) renderVTT(opts Options) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	escape := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	for _, c := range d.cues(opts) {
+		text := escape.Replace(c.text)
+		if len(d.Agents) > 1 && c.agent != "" {
+			text = "<v " + escape.Replace(d.agentName(c.agent)) + ">" + text
+		}
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n", formatCueTime(c.begin, "."), formatCueTime(c.end, "."), text)
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package lyrics
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "重新生成 testdata 中的 golden 文件")
+
+// TestRenderGolden 按 testdata/<name>.ttml 渲染各格式，与 <name>.<format>.golden 比较
+// 修改渲染逻辑后用 go test ./utils/lyrics -update 重新生成并检查差异
+func TestRenderGolden(t *testing.T) {
+	cases := []struct {
+		name    string
+		formats []string
+	}{
+		{"line", []string{"lrc", "lrc-enhanced", "srt", "vtt"}},
+		{"word", []string{"lrc", "lrc-enhanced", "srt", "vtt"}},
+		{"unsynced", []string{"lrc", "lrc-enhanced"}},
+		{"duet", []string{"lrc", "lrc-enhanced", "srt", "vtt"}},
+	}
+	for _, c := range cases {
+		ttml, err := os.ReadFile(filepath.Join("testdata", c.name+".ttml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, format := range c.formats {
+			got, err := Render(string(ttml), format, Options{})
+			if err != nil {
+				t.Errorf("%s %s: %v", c.name, format, err)
+				continue
+			}
+			golden := filepath.Join("testdata", c.name+"."+format+".golden")
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("%s %s:\n got: %q\nwant: %q", c.name, format, got, want)
+			}
+		}
+	}
+}
+
+func TestRenderUnsyncedSubtitles(t *testing.T) {
+	ttml, err := os.ReadFile(filepath.Join("testdata", "unsynced.ttml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{"srt", "vtt"} {
+		if _, err := Render(string(ttml), format, Options{}); err == nil {
+			t.Errorf("%s: expected error for unsynced lyrics", format)
+		}
+	}
+}
+
+func TestParseDocument(t *testing.T) {
+	ttml, err := os.ReadFile(filepath.Join("testdata", "duet.ttml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := Parse(string(ttml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Timing != "Word" || len(doc.Lines) != 3 || len(doc.Agents) != 3 {
+		t.Fatalf("timing %q, %d lines, %d agents", doc.Timing, len(doc.Lines), len(doc.Agents))
+	}
+	if a := doc.Agents[1]; a.ID != "v2" || a.Name != "Singer B" || a.Type != "person" {
+		t.Errorf("agent = %+v", a)
+	}
+	first := doc.Lines[0]
+	if first.Agent != "v1" || first.Text != "I sing" || len(first.Words) != 2 || first.BackgroundText() != "(ooh ooh)" {
+		t.Errorf("first line = %+v", first)
+	}
+	if last := doc.Lines[2]; last.Text != "Together" || last.Words[1].Space || last.End != 20*time.Second {
+		t.Errorf("last line = %+v", last)
+	}
+
+	ttml, err = os.ReadFile(filepath.Join("testdata", "word.ttml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc, err = Parse(string(ttml)); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Songwriters) != 1 || len(doc.Translations) != 2 || len(doc.Transliterations) != 1 {
+		t.Fatalf("songwriters %v, %d translations, %d transliterations", doc.Songwriters, len(doc.Translations), len(doc.Transliterations))
+	}
+	if v := pickVariant(doc.Translations, "zh-CN"); v.Language != "zh-Hans" || v.Lines["L1"].Text != "你好世界" {
+		t.Errorf("zh translation = %+v", v)
+	}
+	if roman := doc.Transliterations[0].Lines["L1"]; roman.Text != "konnichiwa sekai" || len(roman.Words) != 3 {
+		t.Errorf("transliteration = %+v", roman)
+	}
+}
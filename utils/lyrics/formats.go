@@ -0,0 +1,92 @@
+package lyrics
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// Formats 支持的歌词输出格式
+var Formats = []string{"lrc", "lrc-enhanced", "srt", "vtt", "ttml"}
+
+// Extension 返回格式对应的文件扩展名
+func Extension(format string) string {
+	switch format {
+	case "srt", "vtt", "ttml":
+		return "." + format
+	default:
+		return ".lrc"
+	}
+}
+
+// Render 将 TTML 歌词渲染为指定格式
+// lrc: 按行的 LRC；lrc-enhanced: 带 <mm:ss.xx> 逐字时间的 LRC（按行歌词与 lrc 相同）；
+// srt/vtt: 字幕（需要时间轴）；ttml: 原始 TTML。opts 决定译文和音译的输出方式（ttml 不受影响）
+func Render(ttml, format string, opts Options) (string, error) {
+	if format == "ttml" {
+		return ttml, nil
+	}
+	doc, err := Parse(ttml)
+	if err != nil {
+		return "", err
+	}
+	return doc.Render(format, opts)
+}
+
+// cue 一行带起止时间的字幕
+type cue struct {
+	begin, end time.Duration
+	text       string
+	agent      string
+}
+
+// elementText 拼接元素及其子元素中的全部文本
+func elementText(e *etree.Element) string {
+	var b strings.Builder
+	for _, child := range e.Child {
+		switch c := child.(type) {
+		case *etree.CharData:
+			b.WriteString(c.Data)
+		case *etree.Element:
+			b.WriteString(elementText(c))
+		}
+	}
+	return b.String()
+}
+
+// parseTTMLTime 解析 TTML 时间：h:mm:ss.fff、mm:ss.fff 或 ss.fff（小数按实际位数计算）
+func parseTTMLTime(value string) (time.Duration, error) {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "s")
+	if value == "" {
+		return 0, errors.New("缺少时间")
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("无法解析时间: %s", value)
+	}
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("无法解析时间: %s", value)
+	}
+	total := seconds
+	multiplier := 60.0
+	for i := len(parts) - 2; i >= 0; i-- {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return 0, fmt.Errorf("无法解析时间: %s", value)
+		}
+		total += float64(n) * multiplier
+		multiplier *= 60
+	}
+	return time.Duration(total*1000+0.5) * time.Millisecond, nil
+}
+
+// formatCueTime 输出 hh:mm:ss<sep>mmm
+func formatCueTime(d time.Duration, sep string) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
@@ -0,0 +1,70 @@
+package lyrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testLineTTML = `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:itunes="http://music.apple.com/lyric-ttml-internal" itunes:timing="Line"><head><metadata/></head>
+<body><div begin="1.5" end="9.2"><p begin="1.500" end="4.250" itunes:key="L1">First line</p><p begin="1:02.300" end="1:05.000" itunes:key="L2">Second line</p></div></body></tt>`
+
+func TestParseTTMLTime(t *testing.T) {
+	cases := map[string]time.Duration{
+		"1.5":         1500 * time.Millisecond,
+		"4.25":        4250 * time.Millisecond,
+		"1:02.3":      62300 * time.Millisecond,
+		"1:00:01.005": time.Hour + 1005*time.Millisecond,
+		"12s":         12 * time.Second,
+	}
+	for in, want := range cases {
+		if got, err := parseTTMLTime(in); err != nil || got != want {
+			t.Errorf("parseTTMLTime(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+}
+
+func TestRenderFormats(t *testing.T) {
+	lrc, err := Render(testLineTTML, "lrc", Options{})
+	if err != nil || !strings.HasPrefix(lrc, "[00:01.50]First line\n[01:02.30]Second line") {
+		t.Errorf("lrc = %q, %v", lrc, err)
+	}
+	srt, err := Render(testLineTTML, "srt", Options{})
+	if err != nil || !strings.HasPrefix(srt, "1\n00:00:01,500 --> 00:00:04,250\nFirst line\n\n2\n00:01:02,300 --> 00:01:05,000\nSecond line") {
+		t.Errorf("srt = %q, %v", srt, err)
+	}
+	vtt, err := Render(testLineTTML, "vtt", Options{})
+	if err != nil || !strings.HasPrefix(vtt, "WEBVTT\n\n00:00:01.500 --> 00:00:04.250\nFirst line") {
+		t.Errorf("vtt = %q, %v", vtt, err)
+	}
+	if _, err := Render(testLineTTML, "txt", Options{}); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+const testVariantTTML = `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:itunes="http://music.apple.com/lyric-ttml-internal" itunes:timing="Line"><head><metadata><iTunesMetadata xmlns="http://music.apple.com/lyric-ttml-internal">
+<translations><translation type="subtitle" xml:lang="en"><text for="L1">Hello</text></translation><translation type="subtitle" xml:lang="zh-Hans"><text for="L1">你好</text></translation></translations>
+<transliterations><transliteration xml:lang="ja-Latn"><text for="L1">konnichiwa</text></transliteration></transliterations>
+</iTunesMetadata></metadata></head>
+<body><div><p begin="1.500" end="4.250" itunes:key="L1">こんにちは</p></div></body></tt>`
+
+func TestRenderVariants(t *testing.T) {
+	cases := []struct {
+		format string
+		opts   Options
+		want   string
+	}{
+		{"lrc", Options{}, "[00:01.50]Hello\n[00:01.50]konnichiwa"},
+		{"lrc", Options{Mode: "original"}, "[00:01.50]こんにちは"},
+		{"lrc", Options{Mode: "transliteration"}, "[00:01.50]konnichiwa"},
+		{"lrc", Options{Mode: "translation", Language: "zh"}, "[00:01.50]你好"},
+		{"lrc", Options{Mode: "bilingual", Language: "zh-Hans"}, "[00:01.50]こんにちは\n[00:01.50]你好"},
+		{"lrc", Options{Mode: "stacked", Secondary: "transliteration"}, "[00:01.50]こんにちは / konnichiwa"},
+		{"srt", Options{Mode: "bilingual"}, "1\n00:00:01,500 --> 00:00:04,250\nこんにちは\nHello\n\n"},
+	}
+	for _, c := range cases {
+		if got, err := Render(testVariantTTML, c.format, c.opts); err != nil || got != c.want {
+			t.Errorf("Render(%s, %+v) = %q, %v; want %q", c.format, c.opts, got, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package lyrics
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// lastLineDuration LRC 最后一行没有结束时间，按该时长计算
+const lastLineDuration = 5 * time.Second
+
+var (
+	lrcLineTime = regexp.MustCompile(`^\[(\d+):(\d+(?:\.\d+)?)\]`)
+	lrcMetaTag  = regexp.MustCompile(`^\[[a-zA-Z#]+:.*\]$`)
+	lrcWordTime = regexp.MustCompile(`<\d+:\d+(?:\.\d+)?>`)
+)
+
+// FromLRC 把 LRC 歌词转换为按行的 TTML，使本地或第三方歌词可以输出为各种格式
+// 没有时间标签的歌词转换为无时间轴的 TTML；逐字时间 <mm:ss.xx> 会被去掉
+func FromLRC(lrc string) (string, error) {
+	type line struct {
+		begin time.Duration
+		text  string
+	}
+	var timed []line
+	var plain []string
+	for _, raw := range strings.Split(strings.ReplaceAll(lrc, "\r\n", "\n"), "\n") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" || lrcMetaTag.MatchString(raw) {
+			continue
+		}
+		var begins []time.Duration
+		for {
+			m := lrcLineTime.FindStringSubmatch(raw)
+			if m == nil {
+				break
+			}
+			minutes, _ := strconv.Atoi(m[1])
+			seconds, _ := strconv.ParseFloat(m[2], 64)
+			begins = append(begins, time.Duration(minutes)*time.Minute+time.Duration(seconds*1000+0.5)*time.Millisecond)
+			raw = raw[len(m[0]):]
+		}
+		text := strings.TrimSpace(lrcWordTime.ReplaceAllString(raw, ""))
+		if len(begins) == 0 {
+			if text != "" {
+				plain = append(plain, text)
+			}
+			continue
+		}
+		// 同一行可带多个时间标签（重复的副歌），空行只作为上一行的结束时间
+		for _, b := range begins {
+			timed = append(timed, line{begin: b, text: text})
+		}
+	}
+	if len(timed) == 0 && len(plain) == 0 {
+		return "", ErrNoLyrics
+	}
+
+	doc := etree.NewDocument()
+	tt := doc.CreateElement("tt")
+	tt.CreateAttr("xmlns", "http://www.w3.org/ns/ttml")
+	tt.CreateAttr("xmlns:itunes", "http://music.apple.com/lyric-ttml-internal")
+	div := tt.CreateElement("body").CreateElement("div")
+	if len(timed) == 0 {
+		tt.CreateAttr("itunes:timing", "None")
+		for i, text := range plain {
+			p := div.CreateElement("p")
+			p.CreateAttr("itunes:key", fmt.Sprintf("L%d", i+1))
+			p.SetText(text)
+		}
+	} else {
+		tt.CreateAttr("itunes:timing", "Line")
+		sort.SliceStable(timed, func(i, j int) bool { return timed[i].begin < timed[j].begin })
+		key := 0
+		for i, l := range timed {
+			if l.text == "" {
+				continue
+			}
+			end := l.begin + lastLineDuration
+			if i+1 < len(timed) {
+				end = timed[i+1].begin
+			}
+			key++
+			p := div.CreateElement("p")
+			p.CreateAttr("begin", formatTTMLTime(l.begin))
+			p.CreateAttr("end", formatTTMLTime(end))
+			p.CreateAttr("itunes:key", fmt.Sprintf("L%d", key))
+			p.SetText(l.text)
+		}
+		if key == 0 {
+			return "", ErrNoLyrics
+		}
+	}
+	return doc.WriteToString()
+}
+
+// formatTTMLTime 输出 m:ss.fff（分钟可以超过 59）
+func formatTTMLTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%d:%02d.%03d", ms/60000, ms/1000%60, ms%1000)
+}
@@ -5,11 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strings"
-
-	"github.com/beevik/etree"
 )
 
+// ErrNoLyrics 曲目没有歌词
+var ErrNoLyrics = errors.New("failed to get lyrics")
+
 type SongLyrics struct {
 	Data []struct {
 		Id         string `json:"id"`
@@ -27,26 +27,21 @@ type SongLyrics struct {
 	} `json:"data"`
 }
 
-func Get(storefront, songId, lrcType, language, lrcFormat, token, mediaUserToken string) (string, error) {
-	if len(mediaUserToken) < 50 {
-		return "", errors.New("MediaUserToken not set")
-	}
-
-	ttml, err := getSongLyrics(songId, storefront, token, mediaUserToken, lrcType, language)
+// Get 获取歌词并按 format 输出（lrc、lrc-enhanced、srt、vtt、ttml），opts.Language 同时作为请求语言
+func Get(storefront, songId, lrcType, format, token, mediaUserToken string, opts Options) (string, error) {
+	ttml, err := GetTTML(storefront, songId, lrcType, opts.Language, token, mediaUserToken)
 	if err != nil {
 		return "", err
 	}
+	return Render(ttml, format, opts)
+}
 
-	if lrcFormat == "ttml" {
-		return ttml, nil
-	}
-
-	lrc, err := TtmlToLrc(ttml)
-	if err != nil {
-		return "", err
+// GetTTML 获取曲目的原始 TTML 歌词，需要渲染成多种格式时只请求一次
+func GetTTML(storefront, songId, lrcType, language, token, mediaUserToken string) (string, error) {
+	if len(mediaUserToken) < 50 {
+		return "", errors.New("MediaUserToken not set")
 	}
-
-	return lrc, nil
+	return getSongLyrics(songId, storefront, token, mediaUserToken, lrcType, language)
 }
 
 func getSongLyrics(songId string, storefront string, token string, userToken string, lrcType string, language string) (string, error) {
@@ -67,17 +62,18 @@ func getSongLyrics(songId string, storefront string, token string, userToken str
 	defer do.Body.Close()
 	obj := new(SongLyrics)
 	_ = json.NewDecoder(do.Body).Decode(&obj)
-	if obj.Data != nil {
+	if len(obj.Data) > 0 {
 		if len(obj.Data[0].Attributes.Ttml) > 0 {
 			return obj.Data[0].Attributes.Ttml, nil
 		}
-		return obj.Data[0].Attributes.TtmlLocalizations, nil
-	} else {
-		return "", errors.New("failed to get lyrics")
+		if len(obj.Data[0].Attributes.TtmlLocalizations) > 0 {
+			return obj.Data[0].Attributes.TtmlLocalizations, nil
+		}
 	}
+	return "", ErrNoLyrics
 }
 
-// Use for detect if lyrics have CJK, will be replaced by transliteration if exist.
+// Use for detect if lyrics have CJK, will be replaced by transliteration if exist (lyrics-mode auto).
 func containsCJK(s string) bool {
 	for _, r := range s {
 		if (r >= 0x1100 && r <= 0x11FF) || // Hangul Jamo
@@ -121,293 +117,7 @@ func containsCJK(s string) bool {
 	return false
 }
 
-func TtmlToLrc(ttml string) (string, error) {
-	parsedTTML := etree.NewDocument()
-	err := parsedTTML.ReadFromString(ttml)
-	if err != nil {
-		return "", err
-	}
-
-	var lrcLines []string
-	timingAttr := parsedTTML.FindElement("tt").SelectAttr("itunes:timing")
-	if timingAttr != nil {
-		if timingAttr.Value == "Word" {
-			lrc, err := conventSyllableTTMLToLRC(ttml)
-			return lrc, err
-		}
-		if timingAttr.Value == "None" {
-			for _, p := range parsedTTML.FindElements("//p") {
-				line := p.Text()
-				line = strings.TrimSpace(line)
-				if line != "" {
-					lrcLines = append(lrcLines, line)
-				}
-			}
-			return strings.Join(lrcLines, "\n"), nil
-		}
-	}
-
-	for _, item := range parsedTTML.FindElement("tt").FindElement("body").ChildElements() {
-		for _, lyric := range item.ChildElements() {
-			var h, m, s, ms int
-			beginAttr := lyric.SelectAttr("begin")
-			if beginAttr == nil {
-				return "", errors.New("no synchronised lyrics")
-			}
-			beginValue := beginAttr.Value
-			if strings.Contains(beginValue, ":") {
-				_, err = fmt.Sscanf(beginValue, "%d:%d:%d.%d", &h, &m, &s, &ms)
-				if err != nil {
-					_, err = fmt.Sscanf(beginValue, "%d:%d.%d", &m, &s, &ms)
-					if err != nil {
-						_, err = fmt.Sscanf(beginValue, "%d:%d", &m, &s)
-					}
-					h = 0
-				}
-			} else {
-				_, err = fmt.Sscanf(beginValue, "%d.%d", &s, &ms)
-				h, m = 0, 0
-			}
-			if err != nil {
-				return "", err
-			}
-			m += h * 60
-			ms = ms / 10
-			var text, transText, translitText string
-			//GET trans and translit
-			if len(parsedTTML.FindElement("tt").FindElements("head")) > 0 {
-				if len(parsedTTML.FindElement("tt").FindElement("head").FindElements("metadata")) > 0 {
-					Metadata := parsedTTML.FindElement("tt").FindElement("head").FindElement("metadata")
-					if len(Metadata.FindElements("iTunesMetadata")) > 0 {
-						iTunesMetadata := Metadata.FindElement("iTunesMetadata")
-						if len(iTunesMetadata.FindElements("transliterations")) > 0 {
-							if len(iTunesMetadata.FindElement("transliterations").FindElements("transliteration")) > 0 {
-								xpath := fmt.Sprintf("text[@for='%s']", lyric.SelectAttr("itunes:key").Value)
-								translit := iTunesMetadata.FindElement("transliterations").FindElement("transliteration").FindElement(xpath)
-								if translit != nil {
-									if translit.SelectAttr("text") != nil {
-										translitText = translit.SelectAttr("text").Value
-									} else {
-										var translitTmp []string
-										for _, span := range translit.Child {
-											if c, ok := span.(*etree.CharData); ok {
-												translitTmp = append(translitTmp, c.Data)
-											} else if e, ok := span.(*etree.Element); ok {
-												translitTmp = append(translitTmp, e.Text())
-											}
-										}
-										translitText = strings.Join(translitTmp, "")
-									}
-								}
-							}
-						}
-						if len(iTunesMetadata.FindElements("translations")) > 0 {
-							if len(iTunesMetadata.FindElement("translations").FindElements("translation")) > 0 {
-								xpath := fmt.Sprintf("//text[@for='%s']", lyric.SelectAttr("itunes:key").Value)
-								trans := iTunesMetadata.FindElement("translations").FindElement("translation").FindElement(xpath)
-								if trans != nil {
-									if trans.SelectAttr("text") != nil {
-										transText = trans.SelectAttr("text").Value
-									} else {
-										var transTmp []string
-										for _, span := range trans.Child {
-											if c, ok := span.(*etree.CharData); ok {
-												transTmp = append(transTmp, c.Data)
-											} else if e, ok := span.(*etree.Element); ok {
-												transTmp = append(transTmp, e.Text())
-											}
-										}
-										transText = strings.Join(transTmp, "")
-									}
-								}
-							}
-						}
-					}
-				}
-			}
-			if lyric.SelectAttr("text") == nil {
-				var textTmp []string
-				for _, span := range lyric.Child {
-					if _, ok := span.(*etree.CharData); ok {
-						textTmp = append(textTmp, span.(*etree.CharData).Data)
-					} else {
-						textTmp = append(textTmp, span.(*etree.Element).Text())
-					}
-				}
-				text = strings.Join(textTmp, "")
-			} else {
-				text = lyric.SelectAttr("text").Value
-			}
-			if len(transText) > 0 {
-				lrcLines = append(lrcLines, fmt.Sprintf("[%02d:%02d.%02d]%s", m, s, ms, transText))
-			}
-			if len(translitText) > 0 && containsCJK(text) {
-				lrcLines = append(lrcLines, fmt.Sprintf("[%02d:%02d.%02d]%s", m, s, ms, translitText))
-			} else {
-				lrcLines = append(lrcLines, fmt.Sprintf("[%02d:%02d.%02d]%s", m, s, ms, text))
-			}
-		}
-	}
-	return strings.Join(lrcLines, "\n"), nil
-}
-
-func conventSyllableTTMLToLRC(ttml string) (string, error) {
-	parsedTTML := etree.NewDocument()
-	err := parsedTTML.ReadFromString(ttml)
-	if err != nil {
-		return "", err
-	}
-	var lrcLines []string
-	parseTime := func(timeValue string, newLine int) (string, error) {
-		var h, m, s, ms int
-		if strings.Contains(timeValue, ":") {
-			_, err = fmt.Sscanf(timeValue, "%d:%d:%d.%d", &h, &m, &s, &ms)
-			if err != nil {
-				_, err = fmt.Sscanf(timeValue, "%d:%d.%d", &m, &s, &ms)
-				h = 0
-			}
-		} else {
-			_, err = fmt.Sscanf(timeValue, "%d.%d", &s, &ms)
-			h, m = 0, 0
-		}
-		if err != nil {
-			return "", err
-		}
-		m += h * 60
-		ms = ms / 10
-		if newLine == 0 {
-			return fmt.Sprintf("[%02d:%02d.%02d]<%02d:%02d.%02d>", m, s, ms, m, s, ms), nil
-		} else if newLine == -1 {
-			return fmt.Sprintf("[%02d:%02d.%02d]", m, s, ms), nil
-		} else {
-			return fmt.Sprintf("<%02d:%02d.%02d>", m, s, ms), nil
-		}
-	}
-	divs := parsedTTML.FindElement("tt").FindElement("body").FindElements("div")
-	for _, div := range divs {
-		for _, item := range div.ChildElements() { //LINES
-			var lrcSyllables []string
-			var i int = 0
-			var endTime, translitLine, transLine string
-			for _, lyrics := range item.Child { //WORDS
-				if _, ok := lyrics.(*etree.CharData); ok { //是否为span之间的空格
-					if i > 0 {
-						lrcSyllables = append(lrcSyllables, " ")
-						continue
-					}
-					continue
-				}
-				lyric := lyrics.(*etree.Element)
-				if lyric.SelectAttr("begin") == nil {
-					continue
-				}
-				beginTime, err := parseTime(lyric.SelectAttr("begin").Value, i)
-				if err != nil {
-					return "", err
-				}
-
-				endTime, err = parseTime(lyric.SelectAttr("end").Value, 1)
-				if err != nil {
-					return "", err
-				}
-				var text string
-				if lyric.SelectAttr("text") == nil {
-					var textTmp []string
-					for _, span := range lyric.Child {
-						if _, ok := span.(*etree.CharData); ok {
-							textTmp = append(textTmp, span.(*etree.CharData).Data)
-						} else {
-							textTmp = append(textTmp, span.(*etree.Element).Text())
-						}
-					}
-					text = strings.Join(textTmp, "")
-				} else {
-					text = lyric.SelectAttr("text").Value
-				}
-				lrcSyllables = append(lrcSyllables, fmt.Sprintf("%s%s", beginTime, text))
-				if i == 0 {
-					transBeginTime, _ := parseTime(lyric.SelectAttr("begin").Value, -1)
-					sharedTimestamp := ""
-					if len(parsedTTML.FindElement("tt").FindElements("head")) > 0 {
-						if len(parsedTTML.FindElement("tt").FindElement("head").FindElements("metadata")) > 0 {
-							Metadata := parsedTTML.FindElement("tt").FindElement("head").FindElement("metadata")
-							if len(Metadata.FindElements("iTunesMetadata")) > 0 {
-								iTunesMetadata := Metadata.FindElement("iTunesMetadata")
-								if len(iTunesMetadata.FindElements("transliterations")) > 0 {
-									if len(iTunesMetadata.FindElement("transliterations").FindElements("transliteration")) > 0 {
-										xpath := fmt.Sprintf("text[@for='%s']", item.SelectAttr("itunes:key").Value)
-										trans := iTunesMetadata.FindElement("transliterations").FindElement("transliteration").FindElement(xpath)
-										// Get text content
-										var transTxtParts []string
-										var transStartTime string
-										for i, span := range trans.ChildElements() {
-											if span.Tag == "span" {
-												spanBegin := span.SelectAttrValue("begin", "")
-												spanText := span.Text()
-												if spanBegin == "" {
-													continue
-												}
-												// Get timestamp
-												timestamp, err := parseTime(spanBegin, 2)
-												if err != nil {
-													return "", err
-												}
-												if i == 0 {
-													// For [mm:ss.xx] prefix
-													transStartTime, _ = parseTime(spanBegin, -1)
-													sharedTimestamp = transStartTime
-												}
-												transTxtParts = append(transTxtParts, fmt.Sprintf("%s%s", timestamp, spanText))
-											}
-										}
-										translitLine = fmt.Sprintf("%s%s", transStartTime, strings.Join(transTxtParts, " "))
-									}
-								}
-								if len(iTunesMetadata.FindElements("translations")) > 0 {
-									if len(iTunesMetadata.FindElement("translations").FindElements("translation")) > 0 {
-										xpath := fmt.Sprintf("//text[@for='%s']", item.SelectAttr("itunes:key").Value)
-										trans := iTunesMetadata.FindElement("translations").FindElement("translation").FindElement(xpath)
-										var transTxt string
-										if trans.SelectAttr("text") == nil {
-											var textTmp []string
-											for _, span := range trans.Child {
-												if _, ok := span.(*etree.CharData); ok {
-													textTmp = append(textTmp, span.(*etree.CharData).Data)
-												} /*else {
-													textTmp = append(textTmp, span.(*etree.Element).Text())
-												}*/
-											}
-											transTxt = strings.Join(textTmp, "")
-										} else {
-											transTxt = trans.SelectAttr("text").Value
-										}
-										//fmt.Println(transTxt)
-										if sharedTimestamp != "" {
-											transLine = sharedTimestamp + transTxt
-										} else {
-											transLine = transBeginTime + transTxt
-										}
-									}
-								}
-							}
-						}
-					}
-				}
-				i += 1
-			}
-			//endTime, err := parseTime(item.SelectAttr("end").Value)
-			//if err != nil {
-			//	return "", err
-			//}
-			if len(transLine) > 0 {
-				lrcLines = append(lrcLines, transLine)
-			}
-			if len(translitLine) > 0 && containsCJK(strings.Join(lrcSyllables, "")) {
-				lrcLines = append(lrcLines, translitLine)
-			} else {
-				lrcLines = append(lrcLines, strings.Join(lrcSyllables, "")+endTime)
-			}
-		}
-	}
-	return strings.Join(lrcLines, "\n"), nil
+// TtmlToLrc 转换为 LRC：逐字歌词输出增强 LRC，其余按行输出
+func TtmlToLrc(ttml string, opts Options) (string, error) {
+	return Render(ttml, "lrc-enhanced", opts)
 }
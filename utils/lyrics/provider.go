@@ -0,0 +1,220 @@
+package lyrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Track 查找歌词所需的曲目信息
+type Track struct {
+	ID         string // Apple Music 曲目ID
+	ISRC       string
+	Artist     string
+	Title      string
+	Album      string
+	Storefront string
+	DurationMs int
+}
+
+// Provider 歌词来源
+type Provider interface {
+	// Name 来源名称，写入歌词来源标签
+	Name() string
+	// Fetch 返回曲目的 TTML 歌词，没有歌词时返回 ErrNoLyrics
+	Fetch(t Track) (string, error)
+}
+
+// FetchFirst 按顺序尝试各来源，返回第一个找到的歌词及其来源名称
+// 所有来源都没有歌词时返回 ErrNoLyrics；有来源出错时返回包含各来源错误的 error
+func FetchFirst(providers []Provider, t Track) (string, string, error) {
+	var errs []error
+	for _, p := range providers {
+		ttml, err := p.Fetch(t)
+		if err == nil {
+			return ttml, p.Name(), nil
+		}
+		if !errors.Is(err, ErrNoLyrics) {
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return "", "", errors.Join(errs...)
+	}
+	return "", "", ErrNoLyrics
+}
+
+// AppleProvider 从 Apple Music 获取歌词
+type AppleProvider struct {
+	LrcType        string // lyrics 或 syllable-lyrics
+	Language       string
+	Token          string
+	MediaUserToken string
+}
+
+func (p *AppleProvider) Name() string { return "apple" }
+
+func (p *AppleProvider) Fetch(t Track) (string, error) {
+	if t.ID == "" {
+		return "", ErrNoLyrics
+	}
+	return GetTTML(t.Storefront, t.ID, p.LrcType, p.Language, p.Token, p.MediaUserToken)
+}
+
+// forbiddenNameChars 文件名中不能出现的字符，匹配本地歌词文件名时替换为 _
+var forbiddenNameChars = regexp.MustCompile(`[/\\<>:"|?*]`)
+
+// DirProvider 从本地目录读取 .ttml / .lrc 歌词，文件名为 ISRC 或 "歌手 - 标题"（不区分大小写，可在子文件夹中）
+// 同名时 .ttml 优先
+type DirProvider struct {
+	Dir string
+
+	once  sync.Once
+	files map[string]string // 小写文件名（不含扩展名） -> 路径
+	err   error
+}
+
+func (p *DirProvider) Name() string { return "directory" }
+
+func (p *DirProvider) Fetch(t Track) (string, error) {
+	p.once.Do(p.scan)
+	if p.err != nil {
+		return "", p.err
+	}
+	var keys []string
+	if t.ISRC != "" {
+		keys = append(keys, t.ISRC)
+	}
+	if t.Artist != "" && t.Title != "" {
+		name := t.Artist + " - " + t.Title
+		keys = append(keys, name, forbiddenNameChars.ReplaceAllString(name, "_"))
+	}
+	for _, key := range keys {
+		path, ok := p.files[strings.ToLower(key)]
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if strings.EqualFold(filepath.Ext(path), ".ttml") {
+			return string(data), nil
+		}
+		return FromLRC(string(data))
+	}
+	return "", ErrNoLyrics
+}
+
+func (p *DirProvider) scan() {
+	p.files = make(map[string]string)
+	p.err = filepath.Walk(p.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if info.IsDir() || (ext != ".ttml" && ext != ".lrc") {
+			return nil
+		}
+		key := strings.ToLower(strings.TrimSuffix(info.Name(), filepath.Ext(path)))
+		if existing, ok := p.files[key]; ok && strings.EqualFold(filepath.Ext(existing), ".ttml") {
+			return nil
+		}
+		p.files[key] = path
+		return nil
+	})
+}
+
+// HTTPProvider 从返回 JSON 的 HTTP 接口获取歌词
+// URL 模板可用 {id} {isrc} {artist} {title} {album} {storefront} {duration}（秒），取值会进行 URL 编码；
+// Fields 为依次尝试的字段路径（如 syncedLyrics、data.lyrics），内容可以是 TTML 或 LRC；返回数组时取第一个元素
+type HTTPProvider struct {
+	URL     string
+	Fields  []string
+	Headers map[string]string
+	Client  *http.Client // 为空时使用 http.DefaultClient
+}
+
+func (p *HTTPProvider) Name() string { return "http" }
+
+func (p *HTTPProvider) Fetch(t Track) (string, error) {
+	replacer := strings.NewReplacer(
+		"{id}", url.QueryEscape(t.ID),
+		"{isrc}", url.QueryEscape(t.ISRC),
+		"{artist}", url.QueryEscape(t.Artist),
+		"{title}", url.QueryEscape(t.Title),
+		"{album}", url.QueryEscape(t.Album),
+		"{storefront}", url.QueryEscape(t.Storefront),
+		"{duration}", strconv.Itoa((t.DurationMs+500)/1000),
+	)
+	req, err := http.NewRequest("GET", replacer.Replace(p.URL), nil)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ErrNoLyrics
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var body interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if list, ok := body.([]interface{}); ok {
+		if len(list) == 0 {
+			return "", ErrNoLyrics
+		}
+		body = list[0]
+	}
+	for _, field := range p.Fields {
+		text, _ := jsonField(body, field).(string)
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "<") {
+			return text, nil
+		}
+		return FromLRC(text)
+	}
+	return "", ErrNoLyrics
+}
+
+// jsonField 按 a.b.0.c 形式的路径取 JSON 中的值，不存在时为 nil
+func jsonField(v interface{}, path string) interface{} {
+	for _, part := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[part]
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
@@ -0,0 +1,106 @@
+package lyrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFromLRC(t *testing.T) {
+	ttml, err := FromLRC("[ar:Artist]\n[00:01.50]First <00:02.00>line\n[00:04.25]\n[01:02.30][00:10.00]Chorus\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lrc, err := Render(ttml, "lrc", Options{})
+	if err != nil || lrc != "[00:01.50]First line\n[00:10.00]Chorus\n[01:02.30]Chorus" {
+		t.Errorf("lrc = %q, %v", lrc, err)
+	}
+	srt, err := Render(ttml, "srt", Options{})
+	if err != nil || !strings.HasPrefix(srt, "1\n00:00:01,500 --> 00:00:04,250\nFirst line\n") {
+		t.Errorf("srt = %q, %v", srt, err)
+	}
+
+	plain, err := FromLRC("Just words\nNo timing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lrc, err := Render(plain, "lrc", Options{}); err != nil || lrc != "Just words\nNo timing" {
+		t.Errorf("unsynced lrc = %q, %v", lrc, err)
+	}
+	if _, err := FromLRC("[ti:Title]\n"); !errors.Is(err, ErrNoLyrics) {
+		t.Errorf("empty LRC error = %v", err)
+	}
+}
+
+func TestDirProvider(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "USABC1234567.lrc"), []byte("[00:01.00]By ISRC"), 0644)
+	os.WriteFile(filepath.Join(sub, "AC_DC - Back in Black.LRC"), []byte("[00:01.00]By name"), 0644)
+
+	p := &DirProvider{Dir: dir}
+	cases := []struct {
+		track Track
+		want  string
+	}{
+		{Track{ISRC: "usabc1234567", Artist: "AC/DC", Title: "Back in Black"}, "[00:01.00]By ISRC"},
+		{Track{Artist: "AC/DC", Title: "Back in Black"}, "[00:01.00]By name"},
+	}
+	for _, c := range cases {
+		ttml, err := p.Fetch(c.track)
+		if err != nil {
+			t.Errorf("Fetch(%+v) error: %v", c.track, err)
+			continue
+		}
+		if lrc, _ := Render(ttml, "lrc", Options{}); lrc != c.want {
+			t.Errorf("Fetch(%+v) = %q, want %q", c.track, lrc, c.want)
+		}
+	}
+	if _, err := p.Fetch(Track{Artist: "Nobody", Title: "Nothing"}); !errors.Is(err, ErrNoLyrics) {
+		t.Errorf("missing track error = %v", err)
+	}
+}
+
+func TestHTTPProviderAndFetchFirst(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("title") {
+		case "Found":
+			if r.URL.Query().Get("duration") != "215" || r.Header.Get("X-Key") != "secret" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`[{"syncedLyrics": null, "plainLyrics": "Plain text"}]`))
+		case "Broken":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p := &HTTPProvider{
+		URL:     srv.URL + "/get?artist={artist}&title={title}&duration={duration}",
+		Fields:  []string{"syncedLyrics", "plainLyrics"},
+		Headers: map[string]string{"X-Key": "secret"},
+	}
+	ttml, source, err := FetchFirst([]Provider{&DirProvider{Dir: t.TempDir()}, p}, Track{Artist: "A B", Title: "Found", DurationMs: 214600})
+	if err != nil || source != "http" {
+		t.Fatalf("FetchFirst = %q, %v", source, err)
+	}
+	if lrc, _ := Render(ttml, "lrc", Options{}); lrc != "Plain text" {
+		t.Errorf("lrc = %q", lrc)
+	}
+	if _, _, err := FetchFirst([]Provider{p}, Track{Title: "Missing"}); !errors.Is(err, ErrNoLyrics) {
+		t.Errorf("missing error = %v", err)
+	}
+	if _, _, err := FetchFirst([]Provider{p}, Track{Title: "Broken"}); err == nil || errors.Is(err, ErrNoLyrics) {
+		t.Errorf("broken error = %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package lyrics
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Modes lyrics-mode 可选值
+// auto: 旧行为，译文行在前，含 CJK 的行用音译替换原文；original: 只输出原文；
+// transliteration / translation: 只输出音译 / 译文（该行没有时输出原文）；
+// bilingual: 原文行后再输出一行第二语言（同一时间戳）；stacked: 第二语言与原文合并为同一行
+var Modes = []string{"auto", "original", "transliteration", "translation", "bilingual", "stacked"}
+
+// Options 控制译文和音译的输出方式
+type Options struct {
+	Mode      string // 见 Modes，为空时等同 auto
+	Secondary string // bilingual / stacked 的第二语言：translation（默认）或 transliteration，缺失时使用另一种
+	Language  string // 目标语言（如 zh-Hans），用于请求的 l= 参数和选择 translations 中的条目
+}
+
+// pick 按模式选择要输出的行（已去掉空行），auto 以外的模式保证第一行为原文或其替代
+func (o Options) pick(original, translation, translit string) []string {
+	nonEmpty := func(lines ...string) []string {
+		var out []string
+		for _, l := range lines {
+			if l != "" {
+				out = append(out, l)
+			}
+		}
+		return out
+	}
+	or := func(a, b string) string {
+		if a != "" {
+			return a
+		}
+		return b
+	}
+	switch o.Mode {
+	case "original":
+		return nonEmpty(original)
+	case "translation":
+		return nonEmpty(or(translation, original))
+	case "transliteration":
+		return nonEmpty(or(translit, original))
+	case "bilingual", "stacked":
+		if o.Secondary == "transliteration" {
+			return nonEmpty(original, or(translit, translation))
+		}
+		return nonEmpty(original, or(translation, translit))
+	default:
+		if translit != "" && containsCJK(original) {
+			return nonEmpty(translation, translit)
+		}
+		return nonEmpty(translation, original)
+	}
+}
+
+var lrcTimeTag = regexp.MustCompile(`\[\d+:\d+\.\d+\]|<\d+:\d+\.\d+>`)
+
+// lrcLines 把 pick 的结果输出为 LRC 行，stacked 模式下第二行去掉时间标签后接在原文后面
+func (o Options) lrcLines(original, translation, translit string) []string {
+	lines := o.pick(original, translation, translit)
+	if o.Mode == "stacked" && len(lines) == 2 {
+		return []string{lines[0] + " / " + strings.TrimSpace(lrcTimeTag.ReplaceAllString(lines[1], ""))}
+	}
+	return lines
+}
+
+// cueText 字幕中一条的文本，bilingual / stacked 时第二语言作为第二行；auto 只输出原文
+func (o Options) cueText(original, translation, translit string) string {
+	if o.Mode == "" || o.Mode == "auto" {
+		return original
+	}
+	return strings.Join(o.pick(original, translation, translit), "\n")
+}
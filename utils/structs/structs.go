@@ -14,60 +14,139 @@ type Account struct {
 }
 
 type ConfigSet struct {
-	Accounts                []Account     `yaml:"accounts"`
-	Language                string        `yaml:"language"`
-	SaveLrcFile             bool          `yaml:"save-lrc-file"`
-	LrcType                 string        `yaml:"lrc-type"`
-	LrcFormat               string        `yaml:"lrc-format"`
-	SaveAnimatedArtwork     bool          `yaml:"save-animated-artwork"`
-	EmbyAnimatedArtwork     bool          `yaml:"emby-animated-artwork"`
-	EmbedLrc                bool          `yaml:"embed-lrc"`
-	EmbedCover              bool          `yaml:"embed-cover"`
-	SaveArtistCover         bool          `yaml:"save-artist-cover"`
-	CoverSize               string        `yaml:"cover-size"`
-	CoverFormat             string        `yaml:"cover-format"`
-	AlacSaveFolder          string        `yaml:"alac-save-folder"`
-	AtmosSaveFolder         string        `yaml:"atmos-save-folder"`
-	MVSaveFolder            string        `yaml:"mv-save-folder"`
-	AlbumFolderFormat       string        `yaml:"album-folder-format"`
-	PlaylistFolderFormat    string        `yaml:"playlist-folder-format"`
-	ArtistFolderFormat      string        `yaml:"artist-folder-format"`
-	SongFileFormat          string        `yaml:"song-file-format"`
-	ExplicitChoice          string        `yaml:"explicit-choice"`
-	CleanChoice             string        `yaml:"clean-choice"`
-	AppleMasterChoice       string        `yaml:"apple-master-choice"`
-	MaxMemoryLimit          int           `yaml:"max-memory-limit"`
-	GetM3u8Mode             string        `yaml:"get-m3u8-mode"`
-	GetM3u8FromDevice       bool          `yaml:"get-m3u8-from-device"`
-	AacType                 string        `yaml:"aac-type"`
-	AlacMax                 int           `yaml:"alac-max"`
-	AtmosMax                int           `yaml:"atmos-max"`
-	LimitMax                int           `yaml:"limit-max"`
-	UseSongInfoForPlaylist  bool          `yaml:"use-songinfo-for-playlist"`
-	DlAlbumcoverForPlaylist bool          `yaml:"dl-albumcover-for-playlist"`
-	MVAudioType             string        `yaml:"mv-audio-type"`
-	MVMax                   int           `yaml:"mv-max"`
-	AacDownloadThreads      int           `yaml:"aac_downloadthreads"`
-	LosslessDownloadThreads int           `yaml:"lossless_downloadthreads"`
-	HiresDownloadThreads    int           `yaml:"hires_downloadthreads"`
-	ChunkDownloadThreads    int           `yaml:"chunk_downloadthreads"`
-	BufferSizeKB            int           `yaml:"BufferSizeKB"`
-	NetworkReadBufferKB     int           `yaml:"NetworkReadBufferKB"`
-	MaxPathLength           int           `yaml:"max-path-length"`
-	DefaultLyricStorefront  string        `yaml:"default-lyric-storefront"`
-	DownloadVideos          bool          `yaml:"download-videos"`
-	FfmpegFix               bool          `yaml:"ffmpeg-fix"`
-	FfmpegCheckArgs         string        `yaml:"ffmpeg-check-args"`
-	FfmpegEncodeArgs        string        `yaml:"ffmpeg-encode-args"`
-	TxtDownloadThreads      int           `yaml:"txtDownloadThreads"`
-	EnableCache             bool          `yaml:"enable-cache"`
-	CacheFolder             string        `yaml:"cache-folder"`
-	BatchSize               int           `yaml:"batch-size"`               // 分批处理的批次大小，0表示不分批
-	SkipExistingValidation  bool          `yaml:"skip-existing-validation"` // 自动跳过已存在文件的校验
-	WorkRestEnabled         bool          `yaml:"work-rest-enabled"`        // 启用工作-休息循环
-	WorkDurationMinutes     int           `yaml:"work-duration-minutes"`    // 工作时长（分钟）
-	RestDurationMinutes     int           `yaml:"rest-duration-minutes"`    // 休息时长（分钟）
-	Logging                 LoggingConfig `yaml:"logging"`                  // 日志配置
+	Accounts                []Account       `yaml:"accounts"`
+	Language                string          `yaml:"language"`
+	SaveLrcFile             bool            `yaml:"save-lrc-file"`
+	LrcType                 string          `yaml:"lrc-type"`
+	LrcFormat               string          `yaml:"lrc-format"`
+	EmbedLrcFormat          string          `yaml:"embed-lrc-format"` // 内嵌歌词格式，为空时按 lrc-format 决定
+	LyricsSidecars          []LyricsSidecar `yaml:"lyrics-sidecars"`  // 同时保存的歌词文件
+	LyricsMode              string          `yaml:"lyrics-mode"`      // 译文/音译输出方式：auto/original/transliteration/translation/bilingual/stacked
+	LyricsSecondary         string          `yaml:"lyrics-secondary"` // bilingual/stacked 的第二语言：translation/transliteration
+	LyricsLanguage          string          `yaml:"lyrics-language"`  // 歌词目标语言（l= 参数和译文选择），为空时使用 language
+	LyricsProviders         []LyricsSource  `yaml:"lyrics-providers"` // 歌词来源，按顺序尝试，为空时只使用 Apple Music
+	SaveAnimatedArtwork     bool            `yaml:"save-animated-artwork"`
+	EmbyAnimatedArtwork     bool            `yaml:"emby-animated-artwork"`
+	EmbedLrc                bool            `yaml:"embed-lrc"`
+	EmbedCover              bool            `yaml:"embed-cover"`
+	SaveArtistCover         bool            `yaml:"save-artist-cover"`
+	CoverSize               string          `yaml:"cover-size"`
+	CoverFormat             string          `yaml:"cover-format"`
+	AlacSaveFolder          string          `yaml:"alac-save-folder"`
+	AtmosSaveFolder         string          `yaml:"atmos-save-folder"`
+	AacSaveFolder           string          `yaml:"aac-save-folder"` // AAC 保存目录（留空则使用 alac-save-folder）
+	MVSaveFolder            string          `yaml:"mv-save-folder"`
+	AlbumFolderFormat       string          `yaml:"album-folder-format"`
+	PlaylistFolderFormat    string          `yaml:"playlist-folder-format"`
+	ArtistFolderFormat      string          `yaml:"artist-folder-format"`
+	ArtistFolderStrategy    string          `yaml:"artist-folder-strategy"`   // 歌手文件夹策略: album-artist/primary-artist
+	CompilationArtistName   string          `yaml:"compilation-artist-name"`  // 合辑使用的歌手名（默认 Various Artists）
+	PlaylistArtistName      string          `yaml:"playlist-artist-name"`     // 播放列表使用的歌手名
+	StationArtistName       string          `yaml:"station-artist-name"`      // 电台使用的歌手名
+	FilenameProfile         string          `yaml:"filename-profile"`         // 文件名清理规则: posix/windows/smb/ascii-transliterate
+	UnicodeNormalization    string          `yaml:"unicode-normalization"`    // Unicode 规范化: none/nfc/nfd
+	NormalizeExistingCheck  bool            `yaml:"normalize-existing-check"` // 检查已存在文件时按清理规则比较名称
+	SongFileFormat          string          `yaml:"song-file-format"`
+	MultiDiscLayout         string          `yaml:"multi-disc-layout"`  // 多碟专辑布局: flat/disc-folders
+	DiscFolderFormat        string          `yaml:"disc-folder-format"` // 分碟子文件夹命名格式
+	ExplicitChoice          string          `yaml:"explicit-choice"`
+	CleanChoice             string          `yaml:"clean-choice"`
+	AppleMasterChoice       string          `yaml:"apple-master-choice"`
+	MaxMemoryLimit          int             `yaml:"max-memory-limit"`
+	GetM3u8Mode             string          `yaml:"get-m3u8-mode"`
+	GetM3u8FromDevice       bool            `yaml:"get-m3u8-from-device"`
+	AacType                 string          `yaml:"aac-type"`
+	AlacMax                 int             `yaml:"alac-max"`
+	AtmosMax                int             `yaml:"atmos-max"`
+	QualityFallback         []string        `yaml:"quality-fallback"` // 音质回退链，如 [hires-192, hires-96, lossless, aac-256]
+	QualityStrict           bool            `yaml:"quality-strict"`   // 严格模式：首选音质不可用时失败而不降级
+	LimitMax                int             `yaml:"limit-max"`
+	UseSongInfoForPlaylist  bool            `yaml:"use-songinfo-for-playlist"`
+	DlAlbumcoverForPlaylist bool            `yaml:"dl-albumcover-for-playlist"`
+	MVAudioType             string          `yaml:"mv-audio-type"`
+	MVMax                   int             `yaml:"mv-max"`
+	MVFormat                string          `yaml:"mv-format"`      // MV 格式偏好表达式，如 "hevc>avc, sdr, <=2160"
+	MVFileFormat            string          `yaml:"mv-file-format"` // MV 文件名格式，为空时为 "{VideoName} ({ReleaseYear})"
+	AacDownloadThreads      int             `yaml:"aac_downloadthreads"`
+	LosslessDownloadThreads int             `yaml:"lossless_downloadthreads"`
+	HiresDownloadThreads    int             `yaml:"hires_downloadthreads"`
+	ChunkDownloadThreads    int             `yaml:"chunk_downloadthreads"`
+	BufferSizeKB            int             `yaml:"BufferSizeKB"`
+	NetworkReadBufferKB     int             `yaml:"NetworkReadBufferKB"`
+	MaxPathLength           int             `yaml:"max-path-length"`
+	MaxComponentLength      int             `yaml:"max-component-length"` // 单个文件夹名/文件名的最大长度（默认 255）
+	PathLengthUnit          string          `yaml:"path-length-unit"`     // 路径长度计量单位: bytes/utf16，留空按系统自动选择
+	DefaultLyricStorefront  string          `yaml:"default-lyric-storefront"`
+	DownloadVideos          bool            `yaml:"download-videos"`
+	SaveNfo                 bool            `yaml:"save-nfo"`      // 生成 album.nfo / artist.nfo / MV .nfo（Jellyfin/Emby/Plex/Kodi）
+	VerifyTracks            bool            `yaml:"verify-tracks"` // 下载后校验文件结构与时长
+	FfmpegFix               bool            `yaml:"ffmpeg-fix"`
+	FfmpegCheckArgs         string          `yaml:"ffmpeg-check-args"`
+	FfmpegEncodeArgs        string          `yaml:"ffmpeg-encode-args"`
+	TxtDownloadThreads      int             `yaml:"txtDownloadThreads"`
+	EnableCache             bool            `yaml:"enable-cache"`
+	CacheFolder             string          `yaml:"cache-folder"`
+	BatchSize               int             `yaml:"batch-size"`               // 分批处理的批次大小，0表示不分批
+	SkipExistingValidation  bool            `yaml:"skip-existing-validation"` // 自动跳过已存在文件的校验
+	LibraryIndex            bool            `yaml:"library-index"`            // 按内嵌标签（曲目ID/ISRC）识别已下载曲目
+	LibraryIndexFile        string          `yaml:"library-index-file"`       // 曲库索引文件路径
+	WorkRestEnabled         bool            `yaml:"work-rest-enabled"`        // 启用工作-休息循环
+	WorkDurationMinutes     int             `yaml:"work-duration-minutes"`    // 工作时长（分钟）
+	RestDurationMinutes     int             `yaml:"rest-duration-minutes"`    // 休息时长（分钟）
+	ServeQueueFile          string          `yaml:"serve-queue-file"`         // serve 模式的任务队列文件，重启后继续未完成的任务
+	ServeToken              string          `yaml:"serve-token"`              // serve 模式的访问令牌，为空时不校验
+	Logging                 LoggingConfig   `yaml:"logging"`                  // 日志配置
+	TagProfile              string          `yaml:"tag-profile"`              // 标签映射方案: default/foobar2000/navidrome/plex 或 tag-profiles 中的自定义方案
+
+	// 自定义标签映射方案（方案名 -> 方案）
+	TagProfiles map[string]TagProfile `yaml:"tag-profiles"`
+
+	// 歌手别名映射（歌手ID或名称 -> 统一名称），避免同一歌手因拼写不同分散到多个文件夹
+	ArtistAliases map[string]string `yaml:"artist-aliases"`
+
+	// 专辑/任务完成、失败或令牌失效时的通知
+	Notifications []NotificationConfig `yaml:"notifications"`
+}
+
+// NotificationConfig 一个通知目标（notifications 的一项）
+type NotificationConfig struct {
+	Type     string            `yaml:"type"`      // webhook / command / email
+	Events   []string          `yaml:"events"`    // album-done / album-failed / job-done / token-expired，为空时为全部事件
+	Template string            `yaml:"template"`  // 消息模板（Go text/template），为空时使用默认的中文说明
+	URL      string            `yaml:"url"`       // webhook: POST 地址，未设置 template 时发送事件 JSON，否则发送模板内容
+	Headers  map[string]string `yaml:"headers"`   // webhook: 额外的请求头
+	Command  string            `yaml:"command"`   // command: 通过 shell 执行，事件信息在 AMD_* 环境变量中
+	SMTPHost string            `yaml:"smtp-host"` // email: SMTP 服务器 host:port（明文或 STARTTLS，如 587 端口）
+	SMTPUser string            `yaml:"smtp-user"`
+	SMTPPass string            `yaml:"smtp-password"`
+	From     string            `yaml:"from"`
+	To       []string          `yaml:"to"`
+	Subject  string            `yaml:"subject"` // email: 主题模板，为空时为事件的一行说明
+}
+
+// TagProfile 标签映射方案：把目录字段映射到标准标签或 freeform 标签
+type TagProfile struct {
+	Base           string            `yaml:"base"`            // 继承的预设方案，留空为 default
+	GenreSeparator *string           `yaml:"genre-separator"` // 多个流派的连接符，为空字符串时只写第一个流派
+	GenreExclude   []string          `yaml:"genre-exclude"`   // 不写入的流派（如泛指的 "Music"）
+	SortLanguage   *string           `yaml:"sort-language"`   // 排序标签使用该语言的目录名称（如 en-US），为空字符串时使用原名称
+	Fields         map[string]string `yaml:"fields"`          // 目录字段 -> 目标标签（逗号分隔，freeform:名称 为自定义标签，留空不写入）
+}
+
+// LyricsSidecar 一种歌词文件：格式和文件名模板（相对曲目所在文件夹）
+type LyricsSidecar struct {
+	Format string `yaml:"format"` // lrc / lrc-enhanced / srt / vtt / ttml
+	File   string `yaml:"file"`   // 文件名模板，须包含 {SongFileName}，为空时为 {SongFileName} 加格式扩展名
+	Mode   string `yaml:"mode"`   // 该文件的 lyrics-mode，为空时使用全局设置
+}
+
+// LyricsSource 一个歌词来源（lyrics-providers 的一项）
+type LyricsSource struct {
+	Type    string            `yaml:"type"`    // apple / directory / http
+	Path    string            `yaml:"path"`    // directory: 歌词文件夹
+	URL     string            `yaml:"url"`     // http: URL 模板
+	Fields  string            `yaml:"fields"`  // http: 歌词所在的 JSON 字段，逗号分隔，依次尝试
+	Headers map[string]string `yaml:"headers"` // http: 额外的请求头
 }
 
 // LoggingConfig 日志配置
@@ -574,6 +653,23 @@ type AutoGeneratedArtist struct {
 	} `json:"data"`
 }
 
+// AutoGeneratedArtistInfo 歌手详情（简介、流派、头像），用于 artist.nfo
+type AutoGeneratedArtistInfo struct {
+	Data []struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Name           string          `json:"name"`
+			URL            string          `json:"url"`
+			GenreNames     []string        `json:"genreNames"`
+			ArtistBio      string          `json:"artistBio"`
+			EditorialNotes *EditorialNotes `json:"editorialNotes"`
+			Artwork        struct {
+				URL string `json:"url"`
+			} `json:"artwork"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
 type AutoGeneratedMusicVideo struct {
 	Data []struct {
 		ID         string `json:"id"`